@@ -2,61 +2,106 @@ package ai
 
 import (
     "context"
+    "io"
     "os"
+    "time"
 
     openai "github.com/sashabaranov/go-openai"
+
+    "lite-cicd/config"
+    "lite-cicd/core"
+    "lite-cicd/metrics"
 )
 
+// logSystemPrompt 失败日志分析（auto_analyze）的默认系统提示词
+const logSystemPrompt = "你是一个资深的 DevOps 专家。请分析这段 CI/CD 失败日志，给出根因分析和修复建议（Markdown格式）。"
+
+// contextSystemPrompt 带上下文分析时的默认系统提示词
+const contextSystemPrompt = "你是一个资深的 DevOps 专家。用户会提供 CI/CD 任务的日志和相关文件，请按用户的要求进行分析，给出根因分析和修复建议（Markdown格式）。" +
+    "标注为已截断的文件只包含部分内容，日志保留的是末尾部分；不要臆测未提供的内容。"
+
 type AIAgent struct {
-    client *openai.Client
+    client   *openai.Client
+    settings config.LLMConfig // 全局大模型配置，任务的 ai 配置在此基础上覆盖模型参数
+    limiter  *limiter         // 所有任务共享的并发数和速率限制
+    backoff  time.Duration    // 重试的基础退避时间
 }
 
-func NewAIAgent(apiKey, baseURL string) *AIAgent {
-    config := openai.DefaultConfig(apiKey)
-    if baseURL != "" {
-        config.BaseURL = baseURL
+// NewAIAgent 按大模型配置创建客户端，settings 通常为 Config.LLMSettings() 的返回值
+func NewAIAgent(settings config.LLMConfig) *AIAgent {
+    return &AIAgent{
+        client:   openai.NewClientWithConfig(clientConfig(settings)),
+        settings: settings,
+        limiter:  newLimiter(settings.MaxConcurrency, settings.RequestsPerMinute),
+        backoff:  defaultBackoff,
     }
-    return &AIAgent{client: openai.NewClientWithConfig(config)}
 }
 
-func (a *AIAgent) AnalyzeLog(logPath string) (string, error) {
+// clientConfig 按接口类型生成客户端配置
+// azure 使用 api-key 请求头认证，azure_ad 使用 Bearer 令牌；两者的 model 都是部署名称，按原样放入请求路径
+// ollama、vllm 等兼容服务使用 OpenAI 接口格式，没有 API Key 时不发送认证头
+func clientConfig(settings config.LLMConfig) openai.ClientConfig {
+    switch settings.Provider {
+    case config.LLMProviderAzure, config.LLMProviderAzureAD:
+        cfg := openai.DefaultAzureConfig(settings.APIKey, settings.BaseURL)
+        if settings.Provider == config.LLMProviderAzureAD {
+            cfg.APIType = openai.APITypeAzureAD
+        }
+        if settings.APIVersion != "" {
+            cfg.APIVersion = settings.APIVersion
+        }
+        cfg.AzureModelMapperFunc = func(model string) string { return model }
+        return cfg
+    default:
+        cfg := openai.DefaultConfig(settings.APIKey)
+        if settings.BaseURL != "" {
+            cfg.BaseURL = settings.BaseURL
+        }
+        return cfg
+    }
+}
+
+func (a *AIAgent) AnalyzeLog(ctx context.Context, logPath string) (string, error) {
     content, err := os.ReadFile(logPath)
     if err != nil {
         return "", err
     }
 
-    // 截断日志防止 Token 溢出
-    logStr := string(content)
-    if len(logStr) > 8000 {
-        logStr = logStr[len(logStr)-8000:]
-    }
-
-    resp, err := a.client.CreateChatCompletion(
-        context.Background(),
-        openai.ChatCompletionRequest{
-            Model: openai.GPT3Dot5Turbo,
-            Messages: []openai.ChatCompletionMessage{
-                {
-                    Role:    openai.ChatMessageRoleSystem,
-                    Content: "你是一个资深的 DevOps 专家。请分析这段 CI/CD 失败日志，给出根因分析和修复建议（Markdown格式）。",
-                },
-                {
-                    Role:    openai.ChatMessageRoleUser,
-                    Content: logStr,
-                },
-            },
-        },
-    )
+    // 截断日志防止 Token 溢出，保留末尾
+    message, _ := BuildPrompt("请分析以下 CI/CD 失败日志：", map[string]string{"log": string(content)}, a.settings.MaxContextTokens)
 
+    resp, err := a.complete(ctx, a.settings, logSystemPrompt, message, nil)
     if err != nil {
         return "", err
     }
-    return resp.Choices[0].Message.Content, nil
+    return resp.content, nil
 }
 
 // AnalyzeWithContext 使用自定义上下文和Prompt进行AI分析
-func (a *AIAgent) AnalyzeWithContext(prompt string, context map[string]string) (string, error) {
-    // TODO: 实现AI分析逻辑
-    // 这里先返回占位内容
-    return "# AI分析结果\n\n此功能的具体实现待完成。\n\n## 接收到的上下文\n\n", nil
+// 模型参数为全局 llm 配置叠加 aiConfig 中的覆盖项；上下文按token预算截断后与Prompt组成用户消息（见 BuildPrompt）
+// 模型输出以流式方式逐段写入 out，请求中断时已写入的部分保留；出错时仍返回已收到的部分结果和元数据
+func (a *AIAgent) AnalyzeWithContext(ctx context.Context, prompt string, contextFiles map[string]string, aiConfig config.AIConfig, out io.Writer) (*core.Analysis, error) {
+    settings := a.settings.WithOverrides(aiConfig)
+    budget := settings.MaxContextTokens
+    if budget <= 0 {
+        budget = DefaultContextBudget
+    }
+    message, files := BuildPrompt(prompt, contextFiles, budget)
+
+    resp, err := a.complete(ctx, settings, contextSystemPrompt, message, out)
+    if resp.model == "" {
+        resp.model = settings.Model
+    }
+    analysis := &core.Analysis{
+        Content: resp.content,
+        Metadata: metrics.AIMetadata{
+            Model:            resp.model,
+            Budget:           budget,
+            PromptTokens:     resp.usage.PromptTokens,
+            CompletionTokens: resp.usage.CompletionTokens,
+            TotalTokens:      resp.usage.TotalTokens,
+            Context:          files,
+        },
+    }
+    return analysis, err
 }
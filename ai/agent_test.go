@@ -0,0 +1,392 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+
+	"lite-cicd/config"
+)
+
+// mockLLM 模拟 OpenAI 兼容接口的流式输出，记录最后收到的请求
+// failures 中的状态码依次作为前几次请求的响应，delay 为每段输出之间的间隔
+type mockLLM struct {
+	*httptest.Server
+	failures []int
+	delay    time.Duration
+
+	mu       sync.Mutex
+	request  openai.ChatCompletionRequest
+	path     string
+	query    string
+	header   http.Header
+	requests int
+	inflight int
+	peak     int
+}
+
+func newMockLLM(t *testing.T, delay time.Duration, failures ...int) *mockLLM {
+	m := &mockLLM{failures: failures, delay: delay}
+	m.Server = httptest.NewServer(http.HandlerFunc(m.serve))
+	t.Cleanup(m.Close)
+	return m
+}
+
+func (m *mockLLM) serve(w http.ResponseWriter, r *http.Request) {
+	var request openai.ChatCompletionRequest
+	json.NewDecoder(r.Body).Decode(&request)
+
+	m.mu.Lock()
+	m.request, m.path, m.query, m.header = request, r.URL.Path, r.URL.RawQuery, r.Header.Clone()
+	attempt := m.requests
+	m.requests++
+	m.inflight++
+	if m.inflight > m.peak {
+		m.peak = m.inflight
+	}
+	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		m.inflight--
+		m.mu.Unlock()
+	}()
+
+	if attempt < len(m.failures) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(m.failures[attempt])
+		fmt.Fprintf(w, `{"error":{"message":"mock error %d","type":"server_error"}}`, m.failures[attempt])
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	send := func(chunk openai.ChatCompletionStreamResponse) {
+		data, _ := json.Marshal(chunk)
+		fmt.Fprintf(w, "data: %s\n\n", data)
+		w.(http.Flusher).Flush()
+	}
+	for _, part := range []string{"## 根因\n\n", "依赖缺失"} {
+		send(openai.ChatCompletionStreamResponse{
+			Model:   request.Model + "-0125",
+			Choices: []openai.ChatCompletionStreamChoice{{Delta: openai.ChatCompletionStreamChoiceDelta{Content: part}}},
+		})
+		select {
+		case <-time.After(m.delay):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	if request.StreamOptions != nil && request.StreamOptions.IncludeUsage {
+		send(openai.ChatCompletionStreamResponse{
+			Model: request.Model + "-0125",
+			Usage: &openai.Usage{PromptTokens: 120, CompletionTokens: 30, TotalTokens: 150},
+		})
+	}
+	fmt.Fprint(w, "data: [DONE]\n\n")
+}
+
+func TestAnalyzeWithContext(t *testing.T) {
+	server := newMockLLM(t, 0)
+
+	agent := NewAIAgent(config.Config{LLMKey: "test-key", LLMBase: server.URL}.LLMSettings())
+	analysis, err := agent.AnalyzeWithContext(context.Background(), "分析失败原因", map[string]string{"log": strings.Repeat("error\n", 5000)}, config.AIConfig{MaxContextTokens: 500}, nil)
+	if err != nil {
+		t.Fatalf("分析失败: %v", err)
+	}
+
+	if server.path != "/chat/completions" {
+		t.Errorf("请求路径不正确: %s", server.path)
+	}
+	if got := server.header.Get("Authorization"); got != "Bearer test-key" {
+		t.Errorf("认证头不正确: %s", got)
+	}
+	received := server.request
+	if received.Model != config.DefaultLLMModel {
+		t.Errorf("默认模型不正确: %s", received.Model)
+	}
+	if len(received.Messages) != 2 || received.Messages[0].Content != contextSystemPrompt {
+		t.Fatalf("消息结构不正确: %+v", received.Messages)
+	}
+	if !strings.HasPrefix(received.Messages[1].Content, "分析失败原因") || !strings.Contains(received.Messages[1].Content, "### 文件: log") {
+		t.Errorf("用户消息不正确: %q", received.Messages[1].Content[:100])
+	}
+	if analysis.Content != "## 根因\n\n依赖缺失" {
+		t.Errorf("分析结果不正确: %q", analysis.Content)
+	}
+
+	meta := analysis.Metadata
+	if meta.Model != "gpt-3.5-turbo-0125" || meta.Budget != 500 {
+		t.Errorf("模型或预算记录不正确: %+v", meta)
+	}
+	if meta.PromptTokens != 120 || meta.CompletionTokens != 30 || meta.TotalTokens != 150 {
+		t.Errorf("token用量记录不正确: %+v", meta)
+	}
+	if len(meta.Context) != 1 || meta.Context[0].Keep != KeepTail {
+		t.Errorf("截断记录不正确: %+v", meta.Context)
+	}
+}
+
+func TestModelOptions(t *testing.T) {
+	temperature, zero := float32(0.7), float32(0)
+	server := newMockLLM(t, 0)
+	settings := config.Config{LLM: config.LLMConfig{
+		APIKey:       "test-key",
+		BaseURL:      server.URL,
+		Model:        "gpt-4o-mini",
+		Temperature:  &temperature,
+		MaxTokens:    800,
+		SystemPrompt: "你是测试助手",
+	}}.LLMSettings()
+	agent := NewAIAgent(settings)
+
+	t.Run("全局配置", func(t *testing.T) {
+		if _, err := agent.AnalyzeWithContext(context.Background(), "分析", nil, config.AIConfig{}, nil); err != nil {
+			t.Fatalf("分析失败: %v", err)
+		}
+		req := server.request
+		if req.Model != "gpt-4o-mini" || req.Temperature != 0.7 || req.MaxTokens != 800 {
+			t.Errorf("模型参数不正确: model=%s temperature=%g max_tokens=%d", req.Model, req.Temperature, req.MaxTokens)
+		}
+		if req.Messages[0].Content != "你是测试助手" {
+			t.Errorf("系统提示词不正确: %q", req.Messages[0].Content)
+		}
+	})
+
+	t.Run("任务覆盖", func(t *testing.T) {
+		aiConfig := config.AIConfig{Model: "gpt-4o", Temperature: &zero, SystemPrompt: "只列出失败的测试"}
+		analysis, err := agent.AnalyzeWithContext(context.Background(), "分析", nil, aiConfig, nil)
+		if err != nil {
+			t.Fatalf("分析失败: %v", err)
+		}
+		req := server.request
+		if req.Model != "gpt-4o" || req.MaxTokens != 800 || req.Messages[0].Content != "只列出失败的测试" {
+			t.Errorf("任务覆盖不正确: model=%s max_tokens=%d system=%q", req.Model, req.MaxTokens, req.Messages[0].Content)
+		}
+		if req.Temperature == 0 || req.Temperature > 0.001 {
+			t.Errorf("温度0应以最小正数发送: %g", req.Temperature)
+		}
+		if analysis.Metadata.Model != "gpt-4o-0125" {
+			t.Errorf("元数据模型不正确: %s", analysis.Metadata.Model)
+		}
+	})
+
+	t.Run("失败日志分析", func(t *testing.T) {
+		logFile := t.TempDir() + "/task.log"
+		content := strings.Repeat("compile ok\n", 20000) + "FAIL: TestBuild\n"
+		if err := os.WriteFile(logFile, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := agent.AnalyzeLog(context.Background(), logFile); err != nil {
+			t.Fatalf("分析失败: %v", err)
+		}
+		message := server.request.Messages[1].Content
+		if !strings.Contains(message, "FAIL: TestBuild") || EstimateTokens(message) > DefaultContextBudget {
+			t.Errorf("日志应按预算保留末尾: %d tokens", EstimateTokens(message))
+		}
+	})
+}
+
+func TestProviders(t *testing.T) {
+	tests := []struct {
+		name      string
+		llm       config.LLMConfig
+		path      string
+		query     string
+		authKey   string
+		authValue string
+	}{
+		{
+			name:      "ollama不需要API Key",
+			llm:       config.LLMConfig{Provider: config.LLMProviderOllama, Model: "qwen2.5:7b"},
+			path:      "/v1/chat/completions",
+			authKey:   "Authorization",
+			authValue: "",
+		},
+		{
+			name:      "vllm",
+			llm:       config.LLMConfig{Provider: config.LLMProviderVLLM, APIKey: "vllm-token", Model: "Qwen/Qwen2.5-7B-Instruct"},
+			path:      "/v1/chat/completions",
+			authKey:   "Authorization",
+			authValue: "Bearer vllm-token",
+		},
+		{
+			name:      "azure使用api-key请求头",
+			llm:       config.LLMConfig{Provider: config.LLMProviderAzure, APIKey: "azure-key", Model: "gpt-4o.prod"},
+			path:      "/openai/deployments/gpt-4o.prod/chat/completions",
+			query:     "api-version=2023-05-15",
+			authKey:   "api-key",
+			authValue: "azure-key",
+		},
+		{
+			name:      "azure_ad使用Bearer令牌",
+			llm:       config.LLMConfig{Provider: config.LLMProviderAzureAD, APIKey: "entra-token", Model: "gpt-4o", APIVersion: "2024-06-01"},
+			path:      "/openai/deployments/gpt-4o/chat/completions",
+			query:     "api-version=2024-06-01",
+			authKey:   "Authorization",
+			authValue: "Bearer entra-token",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newMockLLM(t, 0)
+			llm := tt.llm
+			llm.BaseURL = server.URL
+			if llm.Provider == config.LLMProviderOllama || llm.Provider == config.LLMProviderVLLM {
+				llm.BaseURL = server.URL + "/v1"
+			}
+			agent := NewAIAgent(config.Config{LLM: llm}.LLMSettings())
+			if _, err := agent.AnalyzeWithContext(context.Background(), "分析", map[string]string{"log": "error"}, config.AIConfig{}, nil); err != nil {
+				t.Fatalf("分析失败: %v", err)
+			}
+			if server.path != tt.path || server.query != tt.query {
+				t.Errorf("请求地址不正确: %s?%s", server.path, server.query)
+			}
+			if got := server.header.Get(tt.authKey); got != tt.authValue {
+				t.Errorf("认证头 %s 不正确: %q", tt.authKey, got)
+			}
+		})
+	}
+}
+
+func TestRetry(t *testing.T) {
+	t.Run("429和5xx按退避重试", func(t *testing.T) {
+		server := newMockLLM(t, 0, http.StatusTooManyRequests, http.StatusBadGateway)
+		agent := NewAIAgent(config.Config{LLMBase: server.URL}.LLMSettings())
+		agent.backoff = 10 * time.Millisecond
+
+		var out strings.Builder
+		analysis, err := agent.AnalyzeWithContext(context.Background(), "分析", nil, config.AIConfig{}, &out)
+		if err != nil {
+			t.Fatalf("重试后应成功: %v", err)
+		}
+		if server.requests != 3 {
+			t.Errorf("请求次数不正确: %d", server.requests)
+		}
+		if out.String() != analysis.Content || analysis.Content != "## 根因\n\n依赖缺失" {
+			t.Errorf("输出内容不正确: %q", out.String())
+		}
+	})
+
+	t.Run("超过重试次数", func(t *testing.T) {
+		retries := 1
+		server := newMockLLM(t, 0, 500, 500, 500)
+		agent := NewAIAgent(config.Config{LLMBase: server.URL, LLM: config.LLMConfig{MaxRetries: &retries}}.LLMSettings())
+		agent.backoff = 10 * time.Millisecond
+
+		if _, err := agent.AnalyzeWithContext(context.Background(), "分析", nil, config.AIConfig{}, nil); err == nil {
+			t.Fatal("应返回错误")
+		}
+		if server.requests != 2 {
+			t.Errorf("请求次数不正确: %d", server.requests)
+		}
+	})
+
+	t.Run("4xx不重试", func(t *testing.T) {
+		server := newMockLLM(t, 0, http.StatusUnauthorized)
+		agent := NewAIAgent(config.Config{LLMBase: server.URL}.LLMSettings())
+		agent.backoff = 10 * time.Millisecond
+
+		if _, err := agent.AnalyzeWithContext(context.Background(), "分析", nil, config.AIConfig{}, nil); err == nil {
+			t.Fatal("应返回错误")
+		}
+		if server.requests != 1 {
+			t.Errorf("请求次数不正确: %d", server.requests)
+		}
+	})
+}
+
+func TestBackoff(t *testing.T) {
+	for attempt, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second} {
+		delay := backoff(time.Second, attempt)
+		if delay < want/2 || delay > want {
+			t.Errorf("第%d次重试的等待时间超出范围: %v", attempt+1, delay)
+		}
+	}
+	if delay := backoff(time.Second, 20); delay > maxBackoff {
+		t.Errorf("等待时间超过上限: %v", delay)
+	}
+}
+
+func TestConcurrencyLimit(t *testing.T) {
+	server := newMockLLM(t, 50*time.Millisecond)
+	agent := NewAIAgent(config.Config{LLMBase: server.URL, LLM: config.LLMConfig{MaxConcurrency: 1}}.LLMSettings())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := agent.AnalyzeWithContext(context.Background(), "分析", nil, config.AIConfig{}, nil); err != nil {
+				t.Errorf("分析失败: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if server.peak != 1 {
+		t.Errorf("并发请求数超过限制: %d", server.peak)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	l := newLimiter(4, 600) // 每100毫秒一个请求
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		release, err := l.acquire(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		release()
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("速率限制未生效: %v", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := l.acquire(ctx); err == nil {
+		t.Error("上下文取消后应返回错误")
+	}
+}
+
+func TestStreamInterrupted(t *testing.T) {
+	server := newMockLLM(t, 2*time.Second)
+	agent := NewAIAgent(config.Config{LLMBase: server.URL}.LLMSettings())
+
+	var out strings.Builder
+	start := time.Now()
+	analysis, err := agent.AnalyzeWithContext(context.Background(), "分析", nil, config.AIConfig{Timeout: 1}, &out)
+	if err == nil || !strings.Contains(err.Error(), "请求超时") {
+		t.Fatalf("请求应超时: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 1900*time.Millisecond {
+		t.Errorf("超时未生效: %v", elapsed)
+	}
+	if out.String() != "## 根因\n\n" || analysis.Content != out.String() {
+		t.Errorf("超时前的输出应保留: %q / %q", out.String(), analysis.Content)
+	}
+	if server.requests != 1 {
+		t.Errorf("已有输出时不应重试: %d", server.requests)
+	}
+
+	t.Run("运行取消", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(200*time.Millisecond, cancel)
+		start := time.Now()
+		_, err := agent.AnalyzeWithContext(ctx, "分析", nil, config.AIConfig{}, nil)
+		if err == nil || strings.Contains(err.Error(), "请求超时") {
+			t.Errorf("运行取消后应中断请求: %v", err)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("取消未生效: %v", elapsed)
+		}
+	})
+}
@@ -0,0 +1,71 @@
+package ai
+
+import (
+    "context"
+    "sync"
+    "time"
+)
+
+// limiter 限制大模型请求的并发数和速率，同一个 AIAgent 的所有任务共享
+type limiter struct {
+    slots    chan struct{}
+    interval time.Duration // 两次请求的最小间隔，0 表示不限速
+
+    mu   sync.Mutex
+    next time.Time // 下一个请求最早的发起时间
+}
+
+// newLimiter 创建限制器，perMinute 为0时不限速
+func newLimiter(concurrency, perMinute int) *limiter {
+    if concurrency <= 0 {
+        concurrency = 1
+    }
+    l := &limiter{slots: make(chan struct{}, concurrency)}
+    if perMinute > 0 {
+        l.interval = time.Minute / time.Duration(perMinute)
+    }
+    return l
+}
+
+// acquire 等待并发名额和速率许可，返回释放并发名额的函数
+func (l *limiter) acquire(ctx context.Context) (func(), error) {
+    select {
+    case l.slots <- struct{}{}:
+    case <-ctx.Done():
+        return nil, ctx.Err()
+    }
+    if err := l.wait(ctx); err != nil {
+        <-l.slots
+        return nil, err
+    }
+    return func() { <-l.slots }, nil
+}
+
+// wait 按最小间隔排队，等待轮到自己的发起时间
+func (l *limiter) wait(ctx context.Context) error {
+    if l.interval == 0 {
+        return nil
+    }
+
+    l.mu.Lock()
+    now := time.Now()
+    at := l.next
+    if at.Before(now) {
+        at = now
+    }
+    l.next = at.Add(l.interval)
+    l.mu.Unlock()
+
+    delay := time.Until(at)
+    if delay <= 0 {
+        return nil
+    }
+    timer := time.NewTimer(delay)
+    defer timer.Stop()
+    select {
+    case <-timer.C:
+        return nil
+    case <-ctx.Done():
+        return ctx.Err()
+    }
+}
@@ -0,0 +1,223 @@
+package ai
+
+import (
+    "fmt"
+    "sort"
+    "strings"
+    "unicode/utf8"
+
+    "lite-cicd/metrics"
+)
+
+// DefaultContextBudget 上下文默认token预算（Prompt + 全部上下文文件）
+const DefaultContextBudget = 6000
+
+const (
+    headerTokens  = 48 // 每个上下文文件标题、截断说明和代码块围栏的估算开销
+    minFileTokens = 32 // 分配到的预算少于该值时不放入该文件
+    logWeight     = 2  // 日志文件分配预算时的权重，其他文件为1
+)
+
+// 截断方式，记录到运行元数据中
+const (
+    KeepFull    = "full"    // 完整保留
+    KeepTail    = "tail"    // 保留末尾（日志）
+    KeepHead    = "head"    // 保留开头（其他文件）
+    KeepOmitted = "omitted" // 预算不足，未放入
+)
+
+// EstimateTokens 估算文本的token数
+// 没有模型分词器时按经验值估算：ASCII 字符约4个一个token，中文等非ASCII字符每个约一个token
+func EstimateTokens(s string) int {
+    ascii, other := 0, 0
+    for _, r := range s {
+        if r < utf8.RuneSelf {
+            ascii++
+        } else {
+            other++
+        }
+    }
+    return (ascii+3)/4 + other
+}
+
+// isLog 判断上下文是否为日志，日志截断时保留末尾（失败信息通常在最后）
+func isLog(name string) bool {
+    return name == "log" || strings.HasSuffix(name, ".log")
+}
+
+// contextItem 待放入Prompt的上下文文件
+type contextItem struct {
+    name    string
+    content string
+    tokens  int
+    budget  int
+}
+
+// BuildPrompt 在token预算内组装用户消息
+// 预算先扣除Prompt和每个文件标题的开销，剩余部分按权重在文件间公平分配：
+// 小于平均份额的文件完整保留，省下的预算由较大的文件继续平分；日志权重更高且截断时保留末尾，其他文件保留开头
+// 返回组装后的消息和每个文件的截断情况
+func BuildPrompt(prompt string, context map[string]string, budget int) (string, []metrics.AIContextFile) {
+    if budget <= 0 {
+        budget = DefaultContextBudget
+    }
+
+    items := make([]*contextItem, 0, len(context))
+    for name, content := range context {
+        items = append(items, &contextItem{name: name, content: content, tokens: EstimateTokens(content)})
+    }
+    allocate(items, budget-EstimateTokens(prompt)-headerTokens*len(items))
+
+    // 任务日志在前，其余日志和文件按名称排序
+    sort.Slice(items, func(i, j int) bool {
+        if (items[i].name == "log") != (items[j].name == "log") {
+            return items[i].name == "log"
+        }
+        if isLog(items[i].name) != isLog(items[j].name) {
+            return isLog(items[i].name)
+        }
+        return items[i].name < items[j].name
+    })
+
+    var sb strings.Builder
+    sb.WriteString(prompt)
+    files := make([]metrics.AIContextFile, 0, len(items))
+    for _, item := range items {
+        file := metrics.AIContextFile{Name: item.name, Tokens: item.tokens}
+        switch {
+        case item.budget < minFileTokens && item.budget < item.tokens:
+            file.Keep = KeepOmitted
+        case item.budget >= item.tokens:
+            file.Keep = KeepFull
+            file.KeptTokens = item.tokens
+            writeSection(&sb, item.name, "", item.content)
+        case isLog(item.name):
+            kept, dropped := keepTail(item.content, item.budget)
+            file.Keep = KeepTail
+            file.KeptTokens = EstimateTokens(kept)
+            note := fmt.Sprintf("已截断：省略前 %d 行，保留末尾 %d 行", dropped, countLines(kept))
+            writeSection(&sb, item.name, note, kept)
+        default:
+            kept, dropped := keepHead(item.content, item.budget)
+            file.Keep = KeepHead
+            file.KeptTokens = EstimateTokens(kept)
+            note := fmt.Sprintf("已截断：保留开头 %d 行，省略后 %d 行", countLines(kept), dropped)
+            writeSection(&sb, item.name, note, kept)
+        }
+        files = append(files, file)
+    }
+
+    var omitted []string
+    for _, file := range files {
+        if file.Keep == KeepOmitted {
+            omitted = append(omitted, file.Name)
+        }
+    }
+    if len(omitted) > 0 {
+        sb.WriteString(fmt.Sprintf("\n\n（超出上下文预算，未提供的文件：%s）", strings.Join(omitted, ", ")))
+    }
+    return sb.String(), files
+}
+
+// allocate 按权重公平分配预算：按单位权重的大小从小到大处理，放得下的文件完整保留，其余文件分得剩余预算的加权份额
+func allocate(items []*contextItem, available int) {
+    if available < 0 {
+        available = 0
+    }
+    order := make([]*contextItem, len(items))
+    copy(order, items)
+    sort.Slice(order, func(i, j int) bool {
+        return order[i].tokens*weight(order[j].name) < order[j].tokens*weight(order[i].name)
+    })
+
+    totalWeight := 0
+    for _, item := range order {
+        totalWeight += weight(item.name)
+    }
+    for _, item := range order {
+        share := available * weight(item.name) / totalWeight
+        if item.tokens <= share {
+            share = item.tokens
+        }
+        item.budget = share
+        available -= share
+        totalWeight -= weight(item.name)
+    }
+}
+
+func weight(name string) int {
+    if isLog(name) {
+        return logWeight
+    }
+    return 1
+}
+
+// keepTail 保留预算内的末尾内容，从完整的行开始，返回保留的内容和省略的行数
+func keepTail(content string, budget int) (string, int) {
+    start, used := len(content), 0
+    for start > 0 {
+        r, size := utf8.DecodeLastRuneInString(content[:start])
+        used += runeTokens(r)
+        if used > budget*4 {
+            break
+        }
+        start -= size
+    }
+    // 从下一行开头保留，避免半行内容；整段只有一行时按字符截断
+    if i := strings.IndexByte(content[start:], '\n'); start > 0 && i >= 0 && i < len(content)-start-1 {
+        start += i + 1
+    }
+    return content[start:], strings.Count(content[:start], "\n")
+}
+
+// keepHead 保留预算内的开头内容，到完整的行结束，返回保留的内容和省略的行数
+func keepHead(content string, budget int) (string, int) {
+    end, used := 0, 0
+    for end < len(content) {
+        r, size := utf8.DecodeRuneInString(content[end:])
+        used += runeTokens(r)
+        if used > budget*4 {
+            break
+        }
+        end += size
+    }
+    if i := strings.LastIndexByte(content[:end], '\n'); end < len(content) && i > 0 {
+        end = i + 1
+    }
+    return content[:end], countLines(content[end:])
+}
+
+// runeTokens 字符按 EstimateTokens 规则折算的token数（乘以4，避免小数）
+func runeTokens(r rune) int {
+    if r < utf8.RuneSelf {
+        return 1
+    }
+    return 4
+}
+
+// countLines 统计行数，最后一行没有换行符时也计入
+func countLines(s string) int {
+    if s == "" {
+        return 0
+    }
+    n := strings.Count(s, "\n")
+    if !strings.HasSuffix(s, "\n") {
+        n++
+    }
+    return n
+}
+
+// writeSection 写入一个带标题的上下文文件，内容中包含代码块围栏时使用更长的围栏
+func writeSection(sb *strings.Builder, name, note, content string) {
+    fence := "```"
+    for strings.Contains(content, fence) {
+        fence += "`"
+    }
+    sb.WriteString("\n\n### 文件: " + name)
+    if note != "" {
+        sb.WriteString("（" + note + "）")
+    }
+    sb.WriteString("\n" + fence + "\n")
+    sb.WriteString(strings.TrimSuffix(content, "\n"))
+    sb.WriteString("\n" + fence)
+}
@@ -0,0 +1,128 @@
+package ai
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"lite-cicd/metrics"
+)
+
+func TestEstimateTokens(t *testing.T) {
+	if n := EstimateTokens("abcdefgh"); n != 2 {
+		t.Errorf("ASCII估算不正确: %d", n)
+	}
+	if n := EstimateTokens("构建失败"); n != 4 {
+		t.Errorf("中文估算不正确: %d", n)
+	}
+}
+
+func TestBuildPrompt(t *testing.T) {
+	lines := func(prefix string, n int) string {
+		var sb strings.Builder
+		for i := 1; i <= n; i++ {
+			sb.WriteString(fmt.Sprintf("%s line %04d\n", prefix, i))
+		}
+		return sb.String()
+	}
+	find := func(files []metrics.AIContextFile, name string) metrics.AIContextFile {
+		for _, file := range files {
+			if file.Name == name {
+				return file
+			}
+		}
+		t.Fatalf("缺少上下文记录: %s", name)
+		return metrics.AIContextFile{}
+	}
+
+	t.Run("预算充足时完整保留", func(t *testing.T) {
+		message, files := BuildPrompt("分析日志", map[string]string{"log": "ok\n", "report.json": "{}"}, 1000)
+		if !strings.HasPrefix(message, "分析日志") {
+			t.Errorf("消息应以Prompt开头: %q", message)
+		}
+		if !strings.Contains(message, "### 文件: log\n```\nok\n```") {
+			t.Errorf("缺少日志文件标题: %q", message)
+		}
+		if strings.Index(message, "文件: log") > strings.Index(message, "文件: report.json") {
+			t.Error("任务日志应排在最前")
+		}
+		for _, file := range files {
+			if file.Keep != KeepFull || file.KeptTokens != file.Tokens {
+				t.Errorf("文件不应被截断: %+v", file)
+			}
+		}
+	})
+
+	t.Run("日志保留末尾其他文件保留开头", func(t *testing.T) {
+		context := map[string]string{
+			"log":          lines("build", 2000),
+			"coverage.out": lines("cover", 2000),
+		}
+		message, files := BuildPrompt("分析", context, 1200)
+		if !strings.Contains(message, "build line 2000") || strings.Contains(message, "build line 0001") {
+			t.Error("日志应保留末尾")
+		}
+		if !strings.Contains(message, "cover line 0001") || strings.Contains(message, "cover line 2000") {
+			t.Error("其他文件应保留开头")
+		}
+		if !strings.Contains(message, "### 文件: log（已截断：省略前") {
+			t.Errorf("截断的日志缺少说明: %q", message[:200])
+		}
+		logFile, other := find(files, "log"), find(files, "coverage.out")
+		if logFile.Keep != KeepTail || other.Keep != KeepHead {
+			t.Errorf("截断方式不正确: %+v %+v", logFile, other)
+		}
+		if logFile.KeptTokens <= other.KeptTokens {
+			t.Errorf("日志应分得更多预算: %d <= %d", logFile.KeptTokens, other.KeptTokens)
+		}
+		if total := EstimateTokens(message); total > 1200 {
+			t.Errorf("超出预算: %d", total)
+		}
+	})
+
+	t.Run("小文件完整保留大文件平分剩余预算", func(t *testing.T) {
+		context := map[string]string{
+			"small.txt": "tiny\n",
+			"a.txt":     lines("a", 1000),
+			"b.txt":     lines("b", 1000),
+		}
+		_, files := BuildPrompt("", context, 1000)
+		if small := find(files, "small.txt"); small.Keep != KeepFull {
+			t.Errorf("小文件应完整保留: %+v", small)
+		}
+		a, b := find(files, "a.txt"), find(files, "b.txt")
+		if a.Keep != KeepHead || b.Keep != KeepHead {
+			t.Fatalf("大文件应被截断: %+v %+v", a, b)
+		}
+		if diff := a.KeptTokens - b.KeptTokens; diff > 10 || diff < -10 {
+			t.Errorf("同样大小的文件应平分预算: %d / %d", a.KeptTokens, b.KeptTokens)
+		}
+	})
+
+	t.Run("预算不足时省略文件", func(t *testing.T) {
+		context := map[string]string{"log": lines("build", 100)}
+		for i := 0; i < 10; i++ {
+			context[fmt.Sprintf("f%d.txt", i)] = lines("f", 100)
+		}
+		message, files := BuildPrompt("", context, 400)
+		omitted := 0
+		for _, file := range files {
+			if file.Keep == KeepOmitted {
+				omitted++
+			}
+		}
+		if omitted == 0 {
+			t.Fatal("应省略部分文件")
+		}
+		if !strings.Contains(message, "未提供的文件") {
+			t.Error("应在消息中列出未提供的文件")
+		}
+	})
+
+	t.Run("内容包含代码块时加长围栏", func(t *testing.T) {
+		message, _ := BuildPrompt("", map[string]string{"README.md": "```go\nfmt.Println()\n```\n"}, 1000)
+		if !strings.Contains(message, "````\n```go") {
+			t.Errorf("围栏未加长: %q", message)
+		}
+	})
+}
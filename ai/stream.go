@@ -0,0 +1,197 @@
+package ai
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "io"
+    "log"
+    "math"
+    "math/rand"
+    "net/http"
+    "strings"
+    "time"
+
+    openai "github.com/sashabaranov/go-openai"
+
+    "lite-cicd/config"
+)
+
+// 重试退避时间，第n次重试前等待 base*2^n 的一半到全部之间的随机时长，最长 maxBackoff
+const (
+    defaultBackoff = time.Second
+    maxBackoff     = 30 * time.Second
+)
+
+// reply 一次对话请求的结果
+type reply struct {
+    content string
+    model   string
+    usage   openai.Usage
+}
+
+// complete 以流式方式发送对话请求，收到的内容同时写入 out（可以为 nil）
+// 请求受全局并发数和速率限制；建立连接时遇到 429 或 5xx 按指数退避加随机抖动重试，已经输出内容后不再重试
+// 出错时返回已收到的部分内容，settings.SystemPrompt 为空时使用 defaultSystemPrompt
+func (a *AIAgent) complete(ctx context.Context, settings config.LLMConfig, defaultSystemPrompt, message string, out io.Writer) (reply, error) {
+    request := chatRequest(settings, defaultSystemPrompt, message)
+    retries := config.DefaultLLMRetries
+    if settings.MaxRetries != nil {
+        retries = *settings.MaxRetries
+    }
+
+    for attempt := 0; ; attempt++ {
+        release, err := a.limiter.acquire(ctx)
+        if err != nil {
+            return reply{}, err
+        }
+        result, err := a.stream(ctx, settings, request, out)
+        release()
+        if err == nil || result.content != "" || !retryable(err) || attempt >= retries {
+            return result, err
+        }
+
+        delay := backoff(a.backoff, attempt)
+        log.Printf("⚠️ 大模型请求失败，%v 后重试（%d/%d）: %v", delay.Round(time.Millisecond), attempt+1, retries, err)
+        timer := time.NewTimer(delay)
+        select {
+        case <-timer.C:
+        case <-ctx.Done():
+            timer.Stop()
+            return result, ctx.Err()
+        }
+    }
+}
+
+// stream 发送一次流式请求，超时时间为 settings.Timeout
+func (a *AIAgent) stream(ctx context.Context, settings config.LLMConfig, request openai.ChatCompletionRequest, out io.Writer) (reply, error) {
+    timeout := settings.Timeout
+    if timeout <= 0 {
+        timeout = config.DefaultLLMTimeout
+    }
+    requestCtx, cancel := context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+    defer cancel()
+
+    result := reply{model: request.Model}
+    stream, err := a.client.CreateChatCompletionStream(requestCtx, request)
+    if err != nil {
+        return result, timeoutError(ctx, requestCtx, timeout, err)
+    }
+    defer stream.Close()
+
+    var content strings.Builder
+    for {
+        resp, err := stream.Recv()
+        if errors.Is(err, io.EOF) {
+            break
+        }
+        if err != nil {
+            result.content = content.String()
+            return result, timeoutError(ctx, requestCtx, timeout, err)
+        }
+        if resp.Model != "" {
+            result.model = resp.Model
+        }
+        if resp.Usage != nil {
+            result.usage = *resp.Usage
+        }
+        for _, choice := range resp.Choices {
+            if choice.Delta.Content == "" {
+                continue
+            }
+            content.WriteString(choice.Delta.Content)
+            if out != nil {
+                if _, err := io.WriteString(out, choice.Delta.Content); err != nil {
+                    result.content = content.String()
+                    return result, fmt.Errorf("写入分析结果失败: %v", err)
+                }
+            }
+        }
+    }
+
+    result.content = content.String()
+    if result.content == "" {
+        return result, fmt.Errorf("模型未返回分析结果")
+    }
+    return result, nil
+}
+
+// chatRequest 生成对话请求
+func chatRequest(settings config.LLMConfig, defaultSystemPrompt, message string) openai.ChatCompletionRequest {
+    systemPrompt := settings.SystemPrompt
+    if systemPrompt == "" {
+        systemPrompt = defaultSystemPrompt
+    }
+    model := settings.Model
+    if model == "" {
+        model = config.DefaultLLMModel
+    }
+
+    request := openai.ChatCompletionRequest{
+        Model:     model,
+        MaxTokens: settings.MaxTokens,
+        Messages: []openai.ChatCompletionMessage{
+            {
+                Role:    openai.ChatMessageRoleSystem,
+                Content: systemPrompt,
+            },
+            {
+                Role:    openai.ChatMessageRoleUser,
+                Content: message,
+            },
+        },
+    }
+    if settings.Temperature != nil {
+        // 值为0时请求中会省略该字段，用最小正数表示0
+        request.Temperature = float32(math.Max(float64(*settings.Temperature), math.SmallestNonzeroFloat32))
+    }
+    if streamUsage(settings) {
+        request.StreamOptions = &openai.StreamOptions{IncludeUsage: true}
+    }
+    return request
+}
+
+// streamUsage 判断是否请求在流式输出的最后返回token用量
+// Azure OpenAI 从 2024-06-01 版本开始支持 stream_options，更早的版本会拒绝该参数
+func streamUsage(settings config.LLMConfig) bool {
+    switch settings.Provider {
+    case config.LLMProviderAzure, config.LLMProviderAzureAD:
+        return settings.APIVersion >= "2024-06-01"
+    }
+    return true
+}
+
+// retryable 判断错误是否可以重试：限流（429）和服务端错误（5xx）
+func retryable(err error) bool {
+    var apiErr *openai.APIError
+    if errors.As(err, &apiErr) {
+        return retryableStatus(apiErr.HTTPStatusCode)
+    }
+    var reqErr *openai.RequestError
+    if errors.As(err, &reqErr) {
+        return retryableStatus(reqErr.HTTPStatusCode)
+    }
+    return false
+}
+
+func retryableStatus(code int) bool {
+    return code == http.StatusTooManyRequests || code >= http.StatusInternalServerError
+}
+
+// backoff 返回第 attempt 次重试前的等待时间
+func backoff(base time.Duration, attempt int) time.Duration {
+    delay := maxBackoff
+    if attempt < 16 && base<<attempt < maxBackoff {
+        delay = base << attempt
+    }
+    half := delay / 2
+    return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// timeoutError 请求因 llm.timeout 超时中断时返回更明确的错误，运行取消等外部原因保持原错误
+func timeoutError(ctx, requestCtx context.Context, timeout int, err error) error {
+    if ctx.Err() == nil && errors.Is(requestCtx.Err(), context.DeadlineExceeded) {
+        return fmt.Errorf("请求超时（%d秒）: %v", timeout, err)
+    }
+    return err
+}
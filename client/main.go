@@ -1,16 +1,20 @@
 package main
 
 import (
+    "bufio"
     "bytes"
     "encoding/json"
     "flag"
     "fmt"
     "io"
     "net/http"
+    "net/url"
     "os"
+    "strconv"
     "strings"
 
     "lite-cicd/config"
+    "lite-cicd/secrets"
 )
 
 // Client 客户端结构
@@ -86,10 +90,70 @@ func (c *Client) sendCommand(command string, args map[string]interface{}) (*Comm
     return &response, nil
 }
 
+// followLogs 通过 SSE 接口持续输出任务日志，直到任务结束
+func (c *Client) followLogs(args map[string]interface{}) error {
+    params := url.Values{}
+    if taskName, ok := args["task_name"].(string); ok {
+        params.Set("task", taskName)
+    }
+    if lines, ok := args["lines"].(int); ok {
+        params.Set("lines", strconv.Itoa(lines))
+    }
+
+    httpReq, err := http.NewRequest("GET", c.serverURL+"/api/logs/stream?"+params.Encode(), nil)
+    if err != nil {
+        return fmt.Errorf("创建请求失败: %v", err)
+    }
+    httpReq.Header.Set("Accept", "text/event-stream")
+    if c.authToken != "" {
+        httpReq.Header.Set("Authorization", "Bearer "+c.authToken)
+    }
+
+    resp, err := http.DefaultClient.Do(httpReq)
+    if err != nil {
+        return fmt.Errorf("发送请求失败: %v", err)
+    }
+    defer resp.Body.Close()
+
+    if resp.StatusCode != http.StatusOK {
+        body, _ := io.ReadAll(resp.Body)
+        return fmt.Errorf("服务器错误: %s", strings.TrimSpace(string(body)))
+    }
+
+    scanner := bufio.NewScanner(resp.Body)
+    scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+    event := ""
+    for scanner.Scan() {
+        line := scanner.Text()
+        switch {
+        case strings.HasPrefix(line, "event: "):
+            event = strings.TrimPrefix(line, "event: ")
+        case strings.HasPrefix(line, "data: "):
+            data := strings.TrimPrefix(line, "data: ")
+            switch event {
+            case "run":
+                var info map[string]string
+                if json.Unmarshal([]byte(data), &info) == nil {
+                    fmt.Printf("📄 任务: %s  运行ID: %s  状态: %s\n", info["task_name"], info["run_id"], info["status"])
+                }
+            case "end":
+                fmt.Printf("✅ 任务已结束，状态: %s\n", data)
+                return nil
+            default:
+                fmt.Println(data)
+            }
+        case line == "":
+            event = ""
+        }
+    }
+    return scanner.Err()
+}
+
 func main() {
     var configFile = flag.String("config", "config.yaml", "配置文件路径")
     var server = flag.String("server", "", "服务器地址 (格式: host:port)")
     var command = flag.String("command", "", "要执行的命令")
+    var follow = flag.Bool("follow", false, "logs 命令持续跟随日志输出，直到任务结束")
     flag.Parse()
 
     if *command == "" {
@@ -118,7 +182,7 @@ func main() {
     cfg, _ := loadConfig(*configFile)
     authToken := ""
     if cfg != nil {
-        authToken = cfg.Server.AuthToken
+        authToken = resolveAuthToken(cfg)
     }
 
     client := NewClient(serverURL, authToken)
@@ -126,6 +190,14 @@ func main() {
     // 解析命令和参数
     cmd, args := parseCommand(*command, flag.Args())
 
+    if cmd == "logs" && *follow {
+        if err := client.followLogs(args); err != nil {
+            fmt.Printf("❌ 跟随日志失败: %v\n", err)
+            os.Exit(1)
+        }
+        return
+    }
+
     // 发送命令
     response, err := client.sendCommand(cmd, args)
     if err != nil {
@@ -153,13 +225,15 @@ func printUsage() {
     fmt.Println("可用命令:")
     fmt.Println("  server-up [port] [host]     - 启动服务器（可覆盖配置文件中的端口和主机）")
     fmt.Println("  server-down                 - 停止服务器")
-    fmt.Println("  run <task_name>             - 运行一次指定任务")
+    fmt.Println("  run <task> [branch] [K=V..] - 运行一次指定任务或仓库流水线，K=V 为运行参数")
     fmt.Println("  start <task_name>           - 启动指定任务（周期调度）")
     fmt.Println("  stop <task_name>            - 停止指定任务")
+    fmt.Println("  cancel <task|run_id>        - 终止正在执行的运行（指定任务名则终止该任务的所有运行）")
     fmt.Println("  status [task_name]          - 查看任务状态（不指定任务名则显示所有）")
-    fmt.Println("  logs <task_name> [lines]    - 查看任务日志")
+    fmt.Println("  logs <task|run_id> [lines]  - 查看任务日志（配合 -follow 实时跟随）")
     fmt.Println("  config                      - 查看当前配置")
     fmt.Println("  reload                      - 重新加载配置文件")
+    fmt.Println("  validate                    - 校验服务器上的配置文件")
     fmt.Println("  list                        - 列出所有可用任务")
     fmt.Println("  health                      - 检查服务器健康状态")
     fmt.Println("")
@@ -167,12 +241,15 @@ func printUsage() {
     fmt.Println("  -config string    配置文件路径 (默认: config.yaml)")
     fmt.Println("  -server string    服务器地址 (格式: host:port)")
     fmt.Println("  -command string   要执行的命令")
+    fmt.Println("  -follow           logs 命令持续跟随日志输出")
     fmt.Println("")
     fmt.Println("示例:")
     fmt.Println("  ./client -command \"run backup-database\"")
+    fmt.Println("  ./client -command \"run deploy VERSION=1.2.3 DRY_RUN=true\"")
     fmt.Println("  ./client -command \"start system-monitor\"")
     fmt.Println("  ./client -command \"status\"")
     fmt.Println("  ./client -command \"logs backup-database 100\"")
+    fmt.Println("  ./client -follow -command \"logs backup-database\"")
     fmt.Println("  ./client -command \"server-up 9090\"")
 }
 
@@ -195,7 +272,23 @@ func parseCommand(command string, args []string) (string, map[string]interface{}
         if len(parts) > 2 {
             cmdArgs["host"] = parts[2]
         }
-    case "run", "start", "stop":
+    case "run":
+        if len(parts) > 1 {
+            cmdArgs["task_name"] = parts[1]
+        }
+        // 其余部分：KEY=VALUE 为运行参数，其他为仓库分支
+        params := make(map[string]interface{})
+        for _, part := range parts[min(len(parts), 2):] {
+            if name, value, ok := strings.Cut(part, "="); ok {
+                params[name] = value
+            } else {
+                cmdArgs["branch"] = part
+            }
+        }
+        if len(params) > 0 {
+            cmdArgs["params"] = params
+        }
+    case "start", "stop", "cancel":
         if len(parts) > 1 {
             cmdArgs["task_name"] = parts[1]
         }
@@ -232,6 +325,10 @@ func printData(data interface{}) {
     switch v := data.(type) {
     case map[string]interface{}:
         for key, value := range v {
+            if queue, ok := value.(map[string]interface{}); ok && key == "queue" {
+                printQueue(queue)
+                continue
+            }
             fmt.Printf("  %s: %v\n", key, value)
         }
     case []interface{}:
@@ -243,6 +340,44 @@ func printData(data interface{}) {
     }
 }
 
+// printQueue 按阶段输出运行队列
+func printQueue(queue map[string]interface{}) {
+    fmt.Printf("  queue (最大并发: %v):\n", queue["max_concurrency"])
+    for _, state := range []string{"running", "pending", "finished"} {
+        runs, _ := queue[state].([]interface{})
+        fmt.Printf("    %s: %d\n", state, len(runs))
+        for _, item := range runs {
+            run, ok := item.(map[string]interface{})
+            if !ok {
+                continue
+            }
+            line := fmt.Sprintf("      - %v [%v] %v", run["run_id"], run["task_name"], run["enqueue_time"])
+            if status, ok := run["status"]; ok {
+                line += fmt.Sprintf(" %v", status)
+            }
+            fmt.Println(line)
+        }
+    }
+}
+
+// resolveAuthToken 读取认证令牌，支持 secret://NAME 引用
+func resolveAuthToken(cfg *config.Config) string {
+    if !strings.HasPrefix(cfg.Server.AuthToken, secrets.RefPrefix) {
+        return cfg.Server.AuthToken
+    }
+    manager, err := secrets.NewManager(cfg.Secrets)
+    if err != nil {
+        fmt.Printf("⚠️  初始化密钥管理失败: %v\n", err)
+        return ""
+    }
+    token, err := manager.ResolveRef(cfg.Server.AuthToken)
+    if err != nil {
+        fmt.Printf("⚠️  读取认证令牌失败: %v\n", err)
+        return ""
+    }
+    return token
+}
+
 func loadConfig(configFile string) (*config.Config, error) {
     cfg, err := config.LoadConfig(configFile)
     if err != nil {
@@ -13,14 +13,17 @@ func main() {
 	listCmd := flag.NewFlagSet("list", flag.ExitOnError)
 	statsCmd := flag.NewFlagSet("stats", flag.ExitOnError)
 	allCmd := flag.NewFlagSet("all", flag.ExitOnError)
+	importCmd := flag.NewFlagSet("import", flag.ExitOnError)
 
 	// latest 子命令参数
 	latestTask := latestCmd.String("task", "", "任务名称 (必需)")
 	latestLogDir := latestCmd.String("logdir", "./logs", "日志目录")
+	latestStore, latestDB := addStoreFlags(latestCmd)
 
 	// list 子命令参数
 	listTask := listCmd.String("task", "", "任务名称 (必需)")
 	listLogDir := listCmd.String("logdir", "./logs", "日志目录")
+	listStore, listDB := addStoreFlags(listCmd)
 	listHours := listCmd.Int("hours", 0, "最近多少小时")
 	listDays := listCmd.Int("days", 0, "最近多少天")
 	listLimit := listCmd.Int("limit", 20, "最多显示条数")
@@ -28,11 +31,17 @@ func main() {
 	// stats 子命令参数
 	statsTask := statsCmd.String("task", "", "任务名称 (必需)")
 	statsLogDir := statsCmd.String("logdir", "./logs", "日志目录")
+	statsStore, statsDB := addStoreFlags(statsCmd)
 	statsHours := statsCmd.Int("hours", 0, "最近多少小时")
 	statsDays := statsCmd.Int("days", 0, "最近多少天")
 
 	// all 子命令参数
 	allLogDir := allCmd.String("logdir", "./logs", "日志目录")
+	allStore, allDB := addStoreFlags(allCmd)
+
+	// import 子命令参数
+	importLogDir := importCmd.String("logdir", "./logs", "日志目录")
+	importDB := importCmd.String("db", "", "bolt 数据库文件路径 (默认: <logdir>/runs.db)")
 
 	// 检查参数
 	if len(os.Args) < 2 {
@@ -49,7 +58,7 @@ func main() {
 			latestCmd.Usage()
 			os.Exit(1)
 		}
-		handleLatest(*latestLogDir, *latestTask)
+		handleLatest(openStore(*latestStore, *latestDB, *latestLogDir), *latestTask)
 
 	case "list":
 		listCmd.Parse(os.Args[2:])
@@ -58,7 +67,7 @@ func main() {
 			listCmd.Usage()
 			os.Exit(1)
 		}
-		handleList(*listLogDir, *listTask, *listHours, *listDays, *listLimit)
+		handleList(openStore(*listStore, *listDB, *listLogDir), *listTask, *listHours, *listDays, *listLimit)
 
 	case "stats":
 		statsCmd.Parse(os.Args[2:])
@@ -67,11 +76,15 @@ func main() {
 			statsCmd.Usage()
 			os.Exit(1)
 		}
-		handleStats(*statsLogDir, *statsTask, *statsHours, *statsDays)
+		handleStats(openStore(*statsStore, *statsDB, *statsLogDir), *statsTask, *statsHours, *statsDays)
 
 	case "all":
 		allCmd.Parse(os.Args[2:])
-		handleAll(*allLogDir)
+		handleAll(openStore(*allStore, *allDB, *allLogDir))
+
+	case "import":
+		importCmd.Parse(os.Args[2:])
+		handleImport(*importLogDir, *importDB)
 
 	default:
 		fmt.Printf("❌ 未知子命令: %s\n\n", os.Args[1])
@@ -91,12 +104,15 @@ func printUsage() {
 	fmt.Println("  list     列出指定任务的历史执行记录")
 	fmt.Println("  stats    显示指定任务的统计信息")
 	fmt.Println("  all      显示所有任务的简要统计")
+	fmt.Println("  import   将日志目录中已有的 metadata.json 导入 bolt 存储")
 	fmt.Println()
 	fmt.Println("示例:")
 	fmt.Println("  metrics latest -task backup-database")
 	fmt.Println("  metrics list -task backup-database -days 7")
 	fmt.Println("  metrics stats -task backup-database -days 30")
 	fmt.Println("  metrics all")
+	fmt.Println("  metrics import -logdir ./logs")
+	fmt.Println("  metrics stats -task backup-database -store bolt")
 	fmt.Println()
 	fmt.Println("选项:")
 	fmt.Println("  -task string     任务名称 (latest/list/stats 必需)")
@@ -104,10 +120,29 @@ func printUsage() {
 	fmt.Println("  -hours int       最近多少小时 (list/stats 可选)")
 	fmt.Println("  -days int        最近多少天 (list/stats 可选)")
 	fmt.Println("  -limit int       最多显示条数 (list, 默认: 20)")
+	fmt.Println("  -store string    存储类型: json 或 bolt (默认: json)")
+	fmt.Println("  -db string       bolt 数据库文件路径 (默认: <logdir>/runs.db)")
+}
+
+// addStoreFlags 为子命令添加存储相关参数
+func addStoreFlags(fs *flag.FlagSet) (*string, *string) {
+	storeType := fs.String("store", metrics.StoreTypeJSON, "存储类型: json 或 bolt")
+	dbPath := fs.String("db", "", "bolt 数据库文件路径 (默认: <logdir>/runs.db)")
+	return storeType, dbPath
+}
+
+func openStore(storeType, dbPath, logDir string) metrics.RunStore {
+	store, err := metrics.OpenStore(storeType, dbPath, logDir)
+	if err != nil {
+		fmt.Printf("❌ 打开存储失败: %v\n", err)
+		os.Exit(1)
+	}
+	return store
 }
 
-func handleLatest(logDir, taskName string) {
-	metadata, err := metrics.GetLatestExecution(logDir, taskName)
+func handleLatest(store metrics.RunStore, taskName string) {
+	defer store.Close()
+	metadata, err := metrics.GetLatestExecution(store, taskName)
 	if err != nil {
 		fmt.Printf("❌ 获取最近执行记录失败: %v\n", err)
 		os.Exit(1)
@@ -116,8 +151,9 @@ func handleLatest(logDir, taskName string) {
 	fmt.Println(metrics.DisplayLatestExecution(metadata))
 }
 
-func handleList(logDir, taskName string, hours, days, limit int) {
-	executions, err := metrics.ListExecutions(logDir, taskName, hours, days)
+func handleList(store metrics.RunStore, taskName string, hours, days, limit int) {
+	defer store.Close()
+	executions, err := metrics.ListExecutions(store, taskName, hours, days)
 	if err != nil {
 		fmt.Printf("❌ 获取执行记录失败: %v\n", err)
 		os.Exit(1)
@@ -136,8 +172,9 @@ func handleList(logDir, taskName string, hours, days, limit int) {
 	fmt.Println(metrics.DisplayExecutionList(executions, taskName))
 }
 
-func handleStats(logDir, taskName string, hours, days int) {
-	stats, err := metrics.GetStatistics(logDir, taskName, hours, days)
+func handleStats(store metrics.RunStore, taskName string, hours, days int) {
+	defer store.Close()
+	stats, err := metrics.GetStatistics(store, taskName, hours, days)
 	if err != nil {
 		fmt.Printf("❌ 获取统计信息失败: %v\n", err)
 		os.Exit(1)
@@ -146,8 +183,9 @@ func handleStats(logDir, taskName string, hours, days int) {
 	fmt.Println(metrics.DisplayStatistics(stats, hours, days))
 }
 
-func handleAll(logDir string) {
-	allMetadata, err := metrics.ListAllMetadata(logDir)
+func handleAll(store metrics.RunStore) {
+	defer store.Close()
+	allMetadata, err := store.List(metrics.RunQuery{})
 	if err != nil {
 		fmt.Printf("❌ 获取任务列表失败: %v\n", err)
 		os.Exit(1)
@@ -160,3 +198,16 @@ func handleAll(logDir string) {
 
 	fmt.Println(metrics.DisplayAllTasksSummary(allMetadata))
 }
+
+func handleImport(logDir, dbPath string) {
+	store := openStore(metrics.StoreTypeBolt, dbPath, logDir)
+	defer store.Close()
+
+	count, err := metrics.ImportLogDir(store, logDir)
+	if err != nil {
+		fmt.Printf("❌ 导入执行记录失败: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("✅ 已导入 %d 条执行记录\n", count)
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"lite-cicd/secrets"
+	"os"
+	"strings"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		printUsage()
+		os.Exit(1)
+	}
+
+	command := os.Args[1]
+	fs := flag.NewFlagSet(command, flag.ExitOnError)
+	file := fs.String("file", "./secrets.enc", "加密密钥文件路径")
+	keyEnv := fs.String("key-env", secrets.DefaultMasterKeyEnv, "保存主密钥的环境变量")
+	name := fs.String("name", "", "密钥名称")
+	value := fs.String("value", "", "密钥值 (set 可选，为空时从标准输入读取)")
+
+	switch command {
+	case "set", "get", "list", "delete":
+		fs.Parse(os.Args[2:])
+	default:
+		printUsage()
+		os.Exit(1)
+	}
+
+	provider := secrets.NewEncryptedFileProvider(*file, *keyEnv)
+	if command != "list" && *name == "" {
+		fmt.Println("❌ 错误: 必须指定密钥名称")
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	switch command {
+	case "set":
+		v := *value
+		if v == "" {
+			var err error
+			if v, err = readValue(os.Stdin); err != nil {
+				fmt.Printf("❌ 读取密钥值失败: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		if err := provider.Set(*name, v); err != nil {
+			fmt.Printf("❌ 保存密钥失败: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("✅ 已保存密钥: %s\n", *name)
+
+	case "get":
+		v, found, err := provider.Get(*name)
+		if err != nil {
+			fmt.Printf("❌ 读取密钥失败: %v\n", err)
+			os.Exit(1)
+		}
+		if !found {
+			fmt.Printf("❌ 未找到密钥: %s\n", *name)
+			os.Exit(1)
+		}
+		fmt.Println(v)
+
+	case "list":
+		names, err := provider.List()
+		if err != nil {
+			fmt.Printf("❌ 读取密钥文件失败: %v\n", err)
+			os.Exit(1)
+		}
+		if len(names) == 0 {
+			fmt.Println("📭 暂无密钥")
+			return
+		}
+		for _, n := range names {
+			fmt.Println(n)
+		}
+
+	case "delete":
+		found, err := provider.Delete(*name)
+		if err != nil {
+			fmt.Printf("❌ 删除密钥失败: %v\n", err)
+			os.Exit(1)
+		}
+		if !found {
+			fmt.Printf("⚠️ 密钥不存在: %s\n", *name)
+			return
+		}
+		fmt.Printf("🗑️ 已删除密钥: %s\n", *name)
+	}
+}
+
+// readValue 从标准输入读取密钥值，避免密钥出现在命令行历史中
+// 终端输入时读取一行，管道输入时读取全部内容并去掉末尾换行
+func readValue(r io.Reader) (string, error) {
+	if info, err := os.Stdin.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		fmt.Fprint(os.Stderr, "请输入密钥值: ")
+		line, err := bufio.NewReader(r).ReadString('\n')
+		if err != nil && err != io.EOF {
+			return "", err
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+func printUsage() {
+	fmt.Println("SmartCI 密钥管理工具")
+	fmt.Println()
+	fmt.Println("用法:")
+	fmt.Println("  secrets <command> [options]")
+	fmt.Println()
+	fmt.Println("命令:")
+	fmt.Println("  set      加密保存密钥")
+	fmt.Println("  get      解密并输出密钥")
+	fmt.Println("  list     列出所有密钥名称")
+	fmt.Println("  delete   删除密钥")
+	fmt.Println()
+	fmt.Println("示例:")
+	fmt.Println("  export SMARTCI_MASTER_KEY=...")
+	fmt.Println("  echo -n \"$TOKEN\" | secrets set -name DEPLOY_TOKEN")
+	fmt.Println("  secrets get -name DEPLOY_TOKEN")
+	fmt.Println("  secrets list -file ./secrets.enc")
+	fmt.Println("  secrets delete -name DEPLOY_TOKEN")
+	fmt.Println()
+	fmt.Println("选项:")
+	fmt.Println("  -file string      加密密钥文件路径 (默认: ./secrets.enc)")
+	fmt.Println("  -key-env string   保存主密钥的环境变量 (默认: SMARTCI_MASTER_KEY)")
+	fmt.Println("  -name string      密钥名称 (set/get/delete 必需)")
+	fmt.Println("  -value string     密钥值 (set 可选，为空时从标准输入读取)")
+}
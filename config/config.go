@@ -6,19 +6,64 @@ type Config struct {
     Server    ServerConfig      `yaml:"server"`     // 服务器配置
     OAuth     []OAuthConfig     `yaml:"oauth"`      // OAuth配置
     Webhooks  []WebhookConfig   `yaml:"webhooks"`   // Webhook配置
-    LLMKey    string            `yaml:"llm_key"`    // 大模型 API Key
-    LLMBase   string            `yaml:"llm_base"`   // 大模型 Base URL
+    LLMKey    string            `yaml:"llm_key"`    // 大模型 API Key（兼容旧配置，未配置 llm.api_key 时使用）
+    LLMBase   string            `yaml:"llm_base"`   // 大模型 Base URL（兼容旧配置，未配置 llm.base_url 时使用）
+    LLM       LLMConfig         `yaml:"llm"`        // 大模型接口和模型参数
     Schedule  string            `yaml:"schedule"`   // 全局定时
     Repos     []RepoConfig      `yaml:"repos"`      // 仓库配置
     BashTasks []BashTaskConfig  `yaml:"bash_tasks"` // Bash任务配置
+    Store     StoreConfig       `yaml:"store"`      // 执行记录存储配置
+    Secrets   SecretsConfig     `yaml:"secrets"`    // 密钥管理配置
+    ContainerPolicy ContainerPolicy `yaml:"container_policy"` // 仓库容器配置的上限和允许范围
+}
+
+// LLMConfig 大模型配置
+// 模型参数（model、temperature、max_tokens、timeout、system_prompt、max_context_tokens）可以在任务的 ai 配置中覆盖
+type LLMConfig struct {
+    Provider          string   `yaml:"provider"`            // 接口类型：openai（默认）, azure, azure_ad, ollama, vllm；ollama、vllm 及其他兼容服务使用 OpenAI 接口格式
+    APIKey            string   `yaml:"api_key"`             // API Key（azure_ad 为 Entra ID 访问令牌），为空时使用 llm_key；本地服务可以不配置
+    BaseURL           string   `yaml:"base_url"`            // 接口地址，为空时 openai 使用 llm_base，ollama、vllm 使用本机默认地址；azure 为资源地址
+    APIVersion        string   `yaml:"api_version"`         // Azure OpenAI 的 API 版本，默认 2023-05-15
+    Model             string   `yaml:"model"`               // 模型名称，azure 为部署名称；openai 默认 gpt-3.5-turbo
+    Temperature       *float32 `yaml:"temperature"`         // 采样温度（0-2），未配置时使用服务端默认值
+    MaxTokens         int      `yaml:"max_tokens"`          // 生成内容的最大token数，默认不限制
+    Timeout           int      `yaml:"timeout"`             // 单次请求超时时间（秒，含流式输出），默认120
+    SystemPrompt      string   `yaml:"system_prompt"`       // 系统提示词，为空时使用内置的 DevOps 分析提示词
+    MaxContextTokens  int      `yaml:"max_context_tokens"`  // Prompt和上下文的token预算，默认6000
+    MaxRetries        *int     `yaml:"max_retries"`         // 429 和 5xx 错误的最大重试次数（指数退避加随机抖动），默认3，0表示不重试
+    MaxConcurrency    int      `yaml:"max_concurrency"`     // 同时进行的大模型请求数，所有任务共享，默认2
+    RequestsPerMinute int      `yaml:"requests_per_minute"` // 每分钟最多发起的请求数（含重试），默认不限制
+}
+
+// SecretsConfig 密钥管理配置
+// 按 providers 顺序查找密钥，未配置时默认只使用 env 提供者
+type SecretsConfig struct {
+    Providers []SecretProviderConfig `yaml:"providers"` // 密钥提供者列表
+}
+
+// SecretProviderConfig 密钥提供者配置
+type SecretProviderConfig struct {
+    Type   string `yaml:"type"`    // 提供者类型：encrypted（主密钥加密的本地文件）, env（环境变量）, file（目录下每个文件一个密钥）
+    Path   string `yaml:"path"`    // encrypted：加密文件路径；file：密钥目录，默认 /run/secrets
+    Prefix string `yaml:"prefix"`  // env：环境变量前缀，默认 SMARTCI_SECRET_
+    KeyEnv string `yaml:"key_env"` // encrypted：保存主密钥的环境变量，默认 SMARTCI_MASTER_KEY
+}
+
+// StoreConfig 执行记录存储配置
+type StoreConfig struct {
+    Type string `yaml:"type"` // 存储类型：json（默认，每个任务目录下的metadata.json）, bolt（嵌入式数据库）
+    Path string `yaml:"path"` // bolt 数据库文件路径，默认 ./logs/runs.db
 }
 
 // ServerConfig 服务器配置
 type ServerConfig struct {
-    Host      string   `yaml:"host"`       // 服务器主机
-    Port      int      `yaml:"port"`       // 服务器端口
-    AuthToken string   `yaml:"auth_token"` // 认证令牌
-    TLS       TLSConfig `yaml:"tls"`       // TLS配置
+    Host           string    `yaml:"host"`            // 服务器主机
+    Port           int       `yaml:"port"`            // 服务器端口
+    AuthToken      string    `yaml:"auth_token"`      // 认证令牌
+    TLS            TLSConfig `yaml:"tls"`             // TLS配置
+    MaxConcurrency int       `yaml:"max_concurrency"` // 全局最大并发运行数，默认4
+    WatchConfig    bool      `yaml:"watch_config"`    // 配置文件变更时自动重新加载
+    ExternalURL    string    `yaml:"external_url"`    // 服务对外访问地址，用于生成回报状态中的运行链接
 }
 
 // TLSConfig TLS配置
@@ -29,26 +74,103 @@ type TLSConfig struct {
 }
 
 type RepoConfig struct {
-    Name        string   `yaml:"name"`
-    URL         string   `yaml:"url"`
-    Branches    []string `yaml:"branches"`
-    Dockerfile  string   `yaml:"dockerfile"`
-    TestCmd     string   `yaml:"test_cmd"`
-    AutoAnalyze bool     `yaml:"auto_analyze"` // 是否开启 AI 自动失败分析（已废弃，使用AI配置）
-    AI          AIConfig `yaml:"ai"`           // AI能力配置
+    Name        string            `yaml:"name"`
+    URL         string            `yaml:"url"`
+    Branches    []string          `yaml:"branches"`
+    Dockerfile  string            `yaml:"dockerfile"`
+    TestCmd     string            `yaml:"test_cmd"`
+    AutoAnalyze bool              `yaml:"auto_analyze"` // 是否开启 AI 自动失败分析（已废弃，使用AI配置）
+    AI          AIConfig          `yaml:"ai"`           // AI能力配置
+    Pipeline    PipelineConfig    `yaml:"pipeline"`     // 多阶段流水线，配置后替代 Dockerfile + TestCmd
+    Concurrency string            `yaml:"concurrency"`  // 并发策略：allow（默认）, skip, queue, cancel-previous
+    Secrets     []string          `yaml:"secrets"`      // 注入到构建环境的密钥名称，同名环境变量
+    Env         map[string]string `yaml:"env"`          // 构建环境变量
+    Params      []ParamConfig     `yaml:"params"`       // 运行参数定义，参数值以同名环境变量注入
+    Container   ContainerConfig   `yaml:"container"`    // 测试容器和流水线步骤容器的资源限制和安全选项
+}
+
+// ContainerConfig 容器资源限制和安全选项，未配置的资源限制使用 container_policy 中的上限
+// 用于仓库的测试容器、流水线的容器步骤和配置了 image 的Bash任务
+type ContainerConfig struct {
+    CPUs      float64  `yaml:"cpus"`       // CPU核数，如 1.5，默认不限制
+    Memory    string   `yaml:"memory"`     // 内存上限，如 512m、2g，默认不限制
+    PidsLimit int64    `yaml:"pids_limit"` // 最大进程数，默认不限制
+    Network   string   `yaml:"network"`    // 网络模式：bridge（默认）, none, host 或自定义网络名
+    ReadOnly  bool     `yaml:"read_only"`  // 根文件系统只读
+    User      string   `yaml:"user"`       // 运行用户，如 1000:1000，默认使用镜像的用户
+    Tmpfs     []string `yaml:"tmpfs"`      // 挂载的 tmpfs，格式 路径[:选项]，如 /tmp:size=64m
+    Volumes   []string `yaml:"volumes"`    // 挂载的宿主机目录或命名卷，格式 源:容器路径[:ro]
+}
+
+// SandboxConfig Bash任务在服务器上执行时的 Linux 沙箱配置
+// 任务在独立的 PID 和挂载命名空间中运行，除工作目录和 writable 外文件系统只读；资源限制使用 cgroup v2
+type SandboxConfig struct {
+    Enabled   bool     `yaml:"enabled"`    // 是否启用沙箱
+    CPUs      float64  `yaml:"cpus"`       // CPU核数，如 1.5，默认不限制
+    Memory    string   `yaml:"memory"`     // 内存上限，如 512m、2g，默认不限制
+    PidsLimit int64    `yaml:"pids_limit"` // 最大进程数，默认不限制
+    Writable  []string `yaml:"writable"`   // 工作目录之外可写的目录，必须是绝对路径
+}
+
+// ContainerPolicy 全局容器策略，限制仓库和Bash任务的 container 配置
+type ContainerPolicy struct {
+    MaxCPUs    float64  `yaml:"max_cpus"`    // CPU核数上限，仓库未配置 cpus 时使用该值
+    MaxMemory  string   `yaml:"max_memory"`  // 内存上限，仓库未配置 memory 时使用该值
+    MaxPids    int64    `yaml:"max_pids"`    // 最大进程数上限，仓库未配置 pids_limit 时使用该值
+    Networks   []string `yaml:"networks"`    // 允许的网络模式，默认只允许 bridge 和 none
+    Volumes    []string `yaml:"volumes"`     // 允许挂载的宿主机目录（含子目录）和命名卷，默认不允许挂载
+    ForbidRoot bool     `yaml:"forbid_root"` // 禁止以 root 运行，仓库必须配置非 root 的 user
+}
+
+// PipelineConfig 多阶段流水线配置
+type PipelineConfig struct {
+    Stages []StageConfig `yaml:"stages"` // 阶段列表，按 needs 组成有向无环图
+}
+
+// StageConfig 流水线阶段配置
+type StageConfig struct {
+    Name  string       `yaml:"name"`  // 阶段名称，如 build, lint, test, package, deploy
+    Needs []string     `yaml:"needs"` // 依赖的阶段，全部成功后才会执行
+    Steps []StepConfig `yaml:"steps"` // 阶段内按顺序执行的步骤
+}
+
+// StepConfig 流水线步骤配置
+type StepConfig struct {
+    Name    string `yaml:"name"`    // 步骤名称
+    Image   string `yaml:"image"`   // 运行镜像，为空则在宿主机上执行
+    Command string `yaml:"command"` // 执行的命令
+    Timeout int    `yaml:"timeout"` // 超时时间（秒），默认1800
 }
 
 // BashTaskConfig 定义Bash任务配置
 type BashTaskConfig struct {
-    Name        string   `yaml:"name"`         // 任务名称
-    Description string   `yaml:"description"`  // 任务描述
-    Schedule    string   `yaml:"schedule"`     // Cron表达式，如 "0 */2 * * *"
-    Command     string   `yaml:"command"`      // Bash命令（内联）
-    ScriptFile  string   `yaml:"script_file"`  // Bash脚本文件路径
-    WorkingDir  string   `yaml:"working_dir"`  // 工作目录，可选
-    Timeout     int      `yaml:"timeout"`      // 超时时间（秒），默认300
-    AutoAnalyze bool     `yaml:"auto_analyze"` // 是否开启 AI 自动失败分析（已废弃，使用AI配置）
-    AI          AIConfig `yaml:"ai"`           // AI能力配置
+    Name        string            `yaml:"name"`         // 任务名称
+    Description string            `yaml:"description"`  // 任务描述
+    Schedule    string            `yaml:"schedule"`     // Cron表达式，如 "0 */2 * * *"
+    Command     string            `yaml:"command"`      // Bash命令（内联）
+    ScriptFile  string            `yaml:"script_file"`  // Bash脚本文件路径
+    WorkingDir  string            `yaml:"working_dir"`  // 工作目录，可选；配置 image 时挂载到容器的 /workspace
+    Image       string            `yaml:"image"`        // 运行镜像，配置后在容器中执行，为空则在服务器上执行
+    Container   ContainerConfig   `yaml:"container"`    // 容器的资源限制和安全选项，仅在配置 image 时有效
+    Sandbox     SandboxConfig     `yaml:"sandbox"`      // 在服务器上执行时的沙箱配置，不能与 image 同时使用
+    Timeout     int               `yaml:"timeout"`      // 超时时间（秒），默认300
+    AutoAnalyze bool              `yaml:"auto_analyze"` // 是否开启 AI 自动失败分析（已废弃，使用AI配置）
+    AI          AIConfig          `yaml:"ai"`           // AI能力配置
+    Concurrency string            `yaml:"concurrency"`  // 并发策略：allow（默认）, skip, queue, cancel-previous
+    Secrets     []string          `yaml:"secrets"`      // 注入到任务环境的密钥名称，同名环境变量
+    Env         map[string]string `yaml:"env"`          // 任务环境变量
+    Params      []ParamConfig     `yaml:"params"`       // 运行参数定义，参数值以同名环境变量注入
+}
+
+// ParamConfig 运行参数定义
+// 手动运行时可以传入参数值，未传入时使用默认值；定时和Webhook触发的运行使用默认值
+type ParamConfig struct {
+    Name        string   `yaml:"name"`        // 参数名称，同时作为环境变量名
+    Type        string   `yaml:"type"`        // 参数类型：string（默认）, int, bool, choice
+    Default     string   `yaml:"default"`     // 默认值
+    Required    bool     `yaml:"required"`    // 是否必须提供（有默认值时视为已提供）
+    Choices     []string `yaml:"choices"`     // choice 类型的可选值
+    Description string   `yaml:"description"` // 参数说明
 }
 
 // OAuthConfig OAuth配置
@@ -58,6 +180,7 @@ type OAuthConfig struct {
     ClientSecret string   `yaml:"client_secret"` // OAuth客户端密钥
     RedirectURL  string   `yaml:"redirect_url"`  // OAuth回调URL
     Scopes       []string `yaml:"scopes"`        // OAuth权限范围
+    BaseURL      string   `yaml:"base_url"`      // 自建实例地址（gitlab, gitea, forgejo, bitbucket），为空时使用官方地址
 }
 
 // WebhookConfig Webhook配置
@@ -69,17 +192,39 @@ type WebhookConfig struct {
     Events    []string          `yaml:"events"`    // 监听的事件类型
     Actions   []WebhookAction   `yaml:"actions"`   // 触发的动作
     Filters   WebhookFilter     `yaml:"filters"`   // 过滤条件
+    Report    ReportConfig      `yaml:"report"`    // 运行状态回报
+}
+
+// ReportConfig 向代码托管平台回报webhook触发的运行状态
+// 运行加入队列时回报 pending，结束后回报 success、failure 或取消；PR事件还可以发布运行结果评论
+type ReportConfig struct {
+    Enabled bool          `yaml:"enabled"`  // 是否回报提交状态
+    Token   string        `yaml:"token"`    // 访问令牌，为空时使用同名OAuth提供商授权获得的令牌
+    Context string        `yaml:"context"`  // 状态名称前缀，默认 smart-ci，完整名称为 <context>/<任务名>
+    API     string        `yaml:"api"`      // GitHub 使用的接口：statuses（默认）或 checks（需要 GitHub App 令牌）
+    BaseURL string        `yaml:"base_url"` // GitHub 为 API 地址；GitLab、Gitea 为实例地址，默认使用同名OAuth配置的 base_url
+    Comment CommentConfig `yaml:"comment"`  // PR评论，可以不回报提交状态单独启用
+}
+
+// CommentConfig PR事件触发的运行结束后，在PR上发布运行结果评论（失败步骤、日志末尾和AI分析）
+// 每个任务在同一个PR上只保留一条评论，再次运行时更新该评论
+type CommentConfig struct {
+    Enabled     bool `yaml:"enabled"`      // 是否发布PR评论
+    OnlyFailure bool `yaml:"only_failure"` // 只在运行失败时发布，之后运行成功时只更新已有评论
+    LogLines    int  `yaml:"log_lines"`    // 评论中的日志末尾行数，默认30
 }
 
 // WebhookAction webhook触发的动作
 type WebhookAction struct {
-    Type       string            `yaml:"type"`        // 动作类型：command, script, task
+    Type       string            `yaml:"type"`        // 动作类型：command, script, task, pipeline
     Command    string            `yaml:"command"`     // shell命令
     Script     string            `yaml:"script"`      // shell脚本路径
     Task       string            `yaml:"task"`        // 已配置的任务名称
+    Repo       string            `yaml:"repo"`        // 已配置的仓库名称（pipeline），为空时按事件中的仓库匹配
     WorkingDir string            `yaml:"working_dir"` // 工作目录
     Timeout    int               `yaml:"timeout"`     // 超时时间（秒）
-    Env        map[string]string `yaml:"env"`         // 环境变量
+    Env        map[string]string `yaml:"env"`         // 环境变量（command, script）
+    Params     map[string]string `yaml:"params"`      // 传给任务或流水线的参数值（task, pipeline）
 }
 
 // WebhookFilter webhook过滤条件
@@ -91,8 +236,14 @@ type WebhookFilter struct {
 
 // AIConfig AI能力配置
 type AIConfig struct {
-    Enabled    bool     `yaml:"enabled"`     // 是否启用AI
-    Context    []string `yaml:"context"`     // 上下文配置（预定义类型如"log"，或路径通配符如"*.log"）
-    Prompt     string   `yaml:"prompt"`      // AI Prompt
-    OutputFile string   `yaml:"output_file"` // 输出文件路径（相对于任务目录），为空则使用默认文件
+    Enabled          bool     `yaml:"enabled"`            // 是否启用AI
+    Context          []string `yaml:"context"`            // 上下文配置（预定义类型如"log"，或路径通配符如"*.log"）
+    Prompt           string   `yaml:"prompt"`             // AI Prompt
+    OutputFile       string   `yaml:"output_file"`        // 输出文件路径（相对于任务目录），为空则使用默认文件
+    MaxContextTokens int      `yaml:"max_context_tokens"` // Prompt和上下文的token预算，超出时截断上下文，默认使用 llm.max_context_tokens
+    Model            string   `yaml:"model"`              // 覆盖 llm.model
+    Temperature      *float32 `yaml:"temperature"`        // 覆盖 llm.temperature
+    MaxTokens        int      `yaml:"max_tokens"`         // 覆盖 llm.max_tokens
+    Timeout          int      `yaml:"timeout"`            // 覆盖 llm.timeout（秒）
+    SystemPrompt     string   `yaml:"system_prompt"`      // 覆盖 llm.system_prompt
 }
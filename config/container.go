@@ -0,0 +1,135 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// 容器网络模式
+const (
+	NetworkBridge = "bridge" // Docker 默认网桥
+	NetworkNone   = "none"   // 无网络
+	NetworkHost   = "host"   // 使用宿主机网络
+)
+
+// defaultNetworks 未配置 container_policy.networks 时允许的网络模式
+var defaultNetworks = []string{NetworkBridge, NetworkNone}
+
+// IsZero 判断是否未配置任何容器选项
+func (c ContainerConfig) IsZero() bool {
+	return c.CPUs == 0 && c.Memory == "" && c.PidsLimit == 0 && c.Network == "" && !c.ReadOnly &&
+		c.User == "" && len(c.Tmpfs) == 0 && len(c.Volumes) == 0
+}
+
+// Apply 返回应用策略后的容器配置：仓库未配置的资源限制使用策略中的上限
+func (p ContainerPolicy) Apply(c ContainerConfig) ContainerConfig {
+	if c.CPUs == 0 {
+		c.CPUs = p.MaxCPUs
+	}
+	if c.Memory == "" {
+		c.Memory = p.MaxMemory
+	}
+	if c.PidsLimit == 0 {
+		c.PidsLimit = p.MaxPids
+	}
+	return c
+}
+
+// AllowsNetwork 判断策略是否允许使用该网络模式，空字符串表示默认的 bridge
+func (p ContainerPolicy) AllowsNetwork(network string) bool {
+	if network == "" {
+		network = NetworkBridge
+	}
+	allowed := p.Networks
+	if len(allowed) == 0 {
+		allowed = defaultNetworks
+	}
+	return contains(allowed, network)
+}
+
+// AllowsVolume 判断策略是否允许挂载该来源：宿主机目录需要位于允许的目录下，命名卷需要同名
+func (p ContainerPolicy) AllowsVolume(source string) bool {
+	for _, allowed := range p.Volumes {
+		if !filepath.IsAbs(source) {
+			if allowed == source {
+				return true
+			}
+			continue
+		}
+		if !filepath.IsAbs(allowed) {
+			continue
+		}
+		rel, err := filepath.Rel(filepath.Clean(allowed), filepath.Clean(source))
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// memoryUnits 内存大小的单位
+var memoryUnits = map[byte]int64{'k': 1 << 10, 'm': 1 << 20, 'g': 1 << 30}
+
+// ParseMemory 解析内存大小，支持 k、m、g 单位（不区分大小写，可带后缀 b），不带单位时为字节数，如 512m、2g、1024kb
+func ParseMemory(s string) (int64, error) {
+	text := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(s)), "b")
+	multiplier := int64(1)
+	if n := len(text); n > 0 {
+		if unit, ok := memoryUnits[text[n-1]]; ok {
+			multiplier = unit
+			text = text[:n-1]
+		}
+	}
+
+	value, err := strconv.ParseInt(text, 10, 64)
+	if err != nil || value <= 0 {
+		return 0, fmt.Errorf("无效的内存大小 %q，格式如 512m、2g", s)
+	}
+	if value > math.MaxInt64/multiplier {
+		return 0, fmt.Errorf("内存大小超出范围: %q", s)
+	}
+	return value * multiplier, nil
+}
+
+// ParseVolume 解析挂载配置 源:容器路径[:ro|rw]，容器路径必须是绝对路径
+func ParseVolume(spec string) (source, target string, readOnly bool, err error) {
+	parts := strings.Split(spec, ":")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" {
+		return "", "", false, fmt.Errorf("无效的挂载 %q，格式为 源:容器路径[:ro]", spec)
+	}
+	source, target = parts[0], parts[1]
+	if !filepath.IsAbs(source) && strings.ContainsAny(source, `/\`) {
+		return "", "", false, fmt.Errorf("挂载的宿主机目录必须是绝对路径: %q", source)
+	}
+	if !strings.HasPrefix(target, "/") {
+		return "", "", false, fmt.Errorf("挂载的容器路径必须是绝对路径: %q", target)
+	}
+	if len(parts) == 3 {
+		switch parts[2] {
+		case "ro":
+			readOnly = true
+		case "rw":
+		default:
+			return "", "", false, fmt.Errorf("未知的挂载模式 %q，可选: ro, rw", parts[2])
+		}
+	}
+	return source, target, readOnly, nil
+}
+
+// ParseTmpfs 解析 tmpfs 配置 路径[:选项]
+func ParseTmpfs(spec string) (target, options string, err error) {
+	target, options, _ = strings.Cut(spec, ":")
+	if !strings.HasPrefix(target, "/") {
+		return "", "", fmt.Errorf("tmpfs 路径必须是绝对路径: %q", target)
+	}
+	return target, options, nil
+}
+
+// RootUser 判断运行用户是否为 root，空字符串表示使用镜像的默认用户（通常为 root）
+func RootUser(user string) bool {
+	name, _, _ := strings.Cut(user, ":")
+	return name == "" || name == "root" || name == "0"
+}
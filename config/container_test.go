@@ -0,0 +1,122 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseMemory(t *testing.T) {
+	for input, want := range map[string]int64{
+		"512m":   512 << 20,
+		"2G":     2 << 30,
+		"1024kb": 1 << 20,
+		"4096":   4096,
+		"100b":   100,
+	} {
+		got, err := ParseMemory(input)
+		if err != nil || got != want {
+			t.Errorf("ParseMemory(%q) = %d, %v，期望 %d", input, got, err, want)
+		}
+	}
+	for _, input := range []string{"", "m", "-1g", "1.5g", "10t"} {
+		if _, err := ParseMemory(input); err == nil {
+			t.Errorf("ParseMemory(%q) 应该返回错误", input)
+		}
+	}
+}
+
+func TestContainerPolicy(t *testing.T) {
+	policy := ContainerPolicy{
+		MaxCPUs:   2,
+		MaxMemory: "1g",
+		Volumes:   []string{"/srv/cache", "go-mod"},
+	}
+
+	t.Run("未配置的资源限制使用上限", func(t *testing.T) {
+		c := policy.Apply(ContainerConfig{CPUs: 0.5})
+		if c.CPUs != 0.5 || c.Memory != "1g" || c.PidsLimit != 0 {
+			t.Errorf("应用策略结果不正确: %+v", c)
+		}
+	})
+
+	t.Run("网络模式", func(t *testing.T) {
+		if !policy.AllowsNetwork("") || !policy.AllowsNetwork(NetworkNone) {
+			t.Error("默认应允许 bridge 和 none")
+		}
+		if policy.AllowsNetwork(NetworkHost) {
+			t.Error("默认不应允许 host 网络")
+		}
+		if !(ContainerPolicy{Networks: []string{NetworkHost}}).AllowsNetwork(NetworkHost) {
+			t.Error("配置了 host 时应允许")
+		}
+	})
+
+	t.Run("挂载来源", func(t *testing.T) {
+		for source, want := range map[string]bool{
+			"/srv/cache":           true,
+			"/srv/cache/npm":       true,
+			"/srv/cache/../etc":    false,
+			"/srv/cache-other":     false,
+			"go-mod":               true,
+			"other-volume":         false,
+			"/var/run/docker.sock": false,
+		} {
+			if got := policy.AllowsVolume(source); got != want {
+				t.Errorf("AllowsVolume(%q) = %v，期望 %v", source, got, want)
+			}
+		}
+	})
+}
+
+func TestValidateBytes_Container(t *testing.T) {
+	data := `container_policy:
+  max_cpus: 2
+  max_memory: "1g"
+  volumes: ["/srv/cache"]
+  forbid_root: true
+repos:
+  - name: "app"
+    url: "https://example.com/app.git"
+    branches: ["main"]
+    container:
+      cpus: 4
+      memory: "512m"
+      network: "host"
+      user: "1000"
+      tmpfs: ["tmp"]
+      volumes:
+        - "/srv/cache/go:/go/pkg"
+        - "/etc:/host-etc:ro"
+  - name: "root"
+    url: "https://example.com/root.git"
+    branches: ["main"]
+    container:
+      memory: "2g"
+bash_tasks:
+  - name: "cleanup"
+    command: "true"
+    container:
+      network: "none"
+`
+	err := ValidateBytes([]byte(data))
+	if err == nil {
+		t.Fatal("预期校验失败，但校验通过")
+	}
+	errs := err.(ValidationErrors)
+	for _, want := range []string{
+		`第11行 repos[0].container.cpus: 超过 container_policy.max_cpus 的上限: 4 > 2`,
+		`第13行 repos[0].container.network: container_policy 不允许使用网络模式 "host"`,
+		`第15行 repos[0].container.tmpfs[0]: tmpfs 路径必须是绝对路径: "tmp"`,
+		`第18行 repos[0].container.volumes[1]: container_policy.volumes 不允许挂载 "/etc"`,
+		`repos[1].container.memory: 超过 container_policy.max_memory 的上限: 2g > 1g`,
+		`repos[1].container.user: container_policy 禁止以 root 运行`,
+		`第28行 bash_tasks[0].container: 只有配置 image 时才能使用容器配置`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("未找到错误 %q，实际错误:\n%v", want, err)
+		}
+	}
+	if len(errs) != 7 {
+		t.Errorf("错误数量不正确，实际错误:\n%v", err)
+	}
+}
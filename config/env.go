@@ -0,0 +1,259 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvFileName 与配置文件同目录的环境变量文件
+const EnvFileName = ".env"
+
+// EnvLookup 查找环境变量
+type EnvLookup func(name string) (string, bool)
+
+// envLookupFor 返回配置文件使用的变量查找函数：优先使用进程环境变量，其次是同目录的 .env 文件
+func envLookupFor(filename string) (EnvLookup, error) {
+	fileVars, err := LoadEnvFile(filepath.Join(filepath.Dir(filename), EnvFileName))
+	if err != nil {
+		return nil, err
+	}
+	return func(name string) (string, bool) {
+		if value, ok := os.LookupEnv(name); ok {
+			return value, true
+		}
+		value, ok := fileVars[name]
+		return value, ok
+	}, nil
+}
+
+// LoadEnvFile 读取 .env 文件，支持 KEY=VALUE、export 前缀、# 注释以及单双引号
+// 文件不存在时返回空结果
+func LoadEnvFile(filename string) (map[string]string, error) {
+	vars := make(map[string]string)
+
+	f, err := os.Open(filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return vars, nil
+		}
+		return nil, fmt.Errorf("读取环境变量文件失败: %v", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		key, value, found := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !found || !validEnvName(key) {
+			return nil, fmt.Errorf("环境变量文件 %s 第%d行格式错误", filename, lineNo)
+		}
+
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		} else if i := strings.Index(value, " #"); i >= 0 {
+			// 未加引号的值允许行尾注释
+			value = strings.TrimSpace(value[:i])
+		}
+		vars[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取环境变量文件失败: %v", err)
+	}
+	return vars, nil
+}
+
+// envError 变量展开错误
+type envError struct {
+	Line    int
+	Name    string
+	Message string // ${VAR:?message} 的自定义信息，为空表示变量未设置
+}
+
+// ExpandEnv 展开字符串中的 ${VAR}、${VAR:-default}、${VAR:?message}
+// $${...} 表示字面量 ${...}；变量名不合法或使用了其他 shell 运算符的表达式保持原样
+func ExpandEnv(s string, lookup EnvLookup) (string, error) {
+	result, errs := expandString(s, lookup)
+	if len(errs) > 0 {
+		return result, formatEnvErrors(errs)
+	}
+	return result, nil
+}
+
+// expandString 展开变量并返回全部未解析的变量
+func expandString(s string, lookup EnvLookup) (string, []envError) {
+	if !strings.Contains(s, "${") {
+		return s, nil
+	}
+
+	var (
+		sb   strings.Builder
+		errs []envError
+	)
+	for i := 0; i < len(s); {
+		// 转义：$${ 输出字面量 ${
+		if strings.HasPrefix(s[i:], "$${") {
+			sb.WriteString("${")
+			i += 3
+			continue
+		}
+		if !strings.HasPrefix(s[i:], "${") {
+			sb.WriteByte(s[i])
+			i++
+			continue
+		}
+
+		end := matchingBrace(s, i+2)
+		if end < 0 {
+			sb.WriteString(s[i:])
+			break
+		}
+
+		value, exprErrs, ok := expandExpr(s[i+2:end], lookup)
+		if ok {
+			sb.WriteString(value)
+			errs = append(errs, exprErrs...)
+		} else {
+			sb.WriteString(s[i : end+1])
+		}
+		i = end + 1
+	}
+	return sb.String(), errs
+}
+
+// expandExpr 展开 ${} 内的表达式，ok 为 false 表示不是支持的变量表达式
+func expandExpr(expr string, lookup EnvLookup) (string, []envError, bool) {
+	name, op, arg := expr, "", ""
+	if i := strings.Index(expr, ":"); i >= 0 {
+		name = expr[:i]
+		rest := expr[i+1:]
+		if len(rest) == 0 || (rest[0] != '-' && rest[0] != '?') {
+			return "", nil, false
+		}
+		op, arg = rest[:1], rest[1:]
+	}
+	if !validEnvName(name) {
+		return "", nil, false
+	}
+
+	value, found := lookup(name)
+	switch op {
+	case "-":
+		if !found || value == "" {
+			expanded, errs := expandString(arg, lookup)
+			return expanded, errs, true
+		}
+	case "?":
+		if !found || value == "" {
+			message := arg
+			if message == "" {
+				message = "未设置或为空"
+			}
+			return "", []envError{{Name: name, Message: message}}, true
+		}
+	default:
+		if !found {
+			return "", []envError{{Name: name}}, true
+		}
+	}
+	return value, nil, true
+}
+
+// expandNode 原地展开YAML节点树中所有标量值（不包括映射的键）
+// 未加引号的标量展开后重新推断类型，以便 port: ${PORT:-8080} 解码为整数
+func expandNode(node *yaml.Node, lookup EnvLookup) []envError {
+	var errs []envError
+	switch node.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, child := range node.Content {
+			errs = append(errs, expandNode(child, lookup)...)
+		}
+	case yaml.MappingNode:
+		for i := 1; i < len(node.Content); i += 2 {
+			errs = append(errs, expandNode(node.Content[i], lookup)...)
+		}
+	case yaml.ScalarNode:
+		if !strings.Contains(node.Value, "${") {
+			return nil
+		}
+		value, scalarErrs := expandString(node.Value, lookup)
+		for i := range scalarErrs {
+			scalarErrs[i].Line = node.Line
+		}
+		errs = append(errs, scalarErrs...)
+		node.Value = value
+		if node.Style == 0 {
+			node.Tag = ""
+		}
+	}
+	return errs
+}
+
+// formatEnvErrors 汇总变量展开错误
+func formatEnvErrors(errs []envError) error {
+	var missing, messages []string
+	seen := make(map[string]bool)
+	for _, e := range errs {
+		if e.Message != "" {
+			messages = append(messages, fmt.Sprintf("%s: %s", e.Name, e.Message))
+			continue
+		}
+		if !seen[e.Name] {
+			seen[e.Name] = true
+			missing = append(missing, e.Name)
+		}
+	}
+	sort.Strings(missing)
+
+	if len(missing) > 0 {
+		messages = append([]string{fmt.Sprintf("未解析的环境变量: %s", strings.Join(missing, ", "))}, messages...)
+	}
+	return fmt.Errorf("%s", strings.Join(messages, "; "))
+}
+
+// matchingBrace 返回与 start 前的 { 匹配的 } 下标，支持嵌套的 ${...}
+func matchingBrace(s string, start int) int {
+	depth := 1
+	for i := start; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// validEnvName 变量名只能包含字母、数字和下划线，且不能以数字开头
+func validEnvName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, c := range name {
+		switch {
+		case c == '_', c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z':
+		case c >= '0' && c <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,95 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExpandEnv(t *testing.T) {
+	vars := map[string]string{
+		"HOST":  "ci.example.com",
+		"EMPTY": "",
+	}
+	lookup := func(name string) (string, bool) {
+		value, ok := vars[name]
+		return value, ok
+	}
+
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"https://${HOST}/hook", "https://ci.example.com/hook"},
+		{"${PORT:-8080}", "8080"},
+		{"${EMPTY:-fallback}", "fallback"},
+		{"${MISSING:-${HOST}}", "ci.example.com"},
+		{"echo $${GITHUB_REF}", "echo ${GITHUB_REF}"},
+		{"echo $$ ${HOST}", "echo $$ ci.example.com"},
+		{"echo ${#list[@]} ${file%%.*}", "echo ${#list[@]} ${file%%.*}"},
+		{"no variables", "no variables"},
+	}
+	for _, tt := range tests {
+		got, err := ExpandEnv(tt.input, lookup)
+		if err != nil {
+			t.Errorf("展开 %q 失败: %v", tt.input, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("展开 %q: got %q, want %q", tt.input, got, tt.want)
+		}
+	}
+
+	_, err := ExpandEnv("${TOKEN} ${SECRET} ${TOKEN}", lookup)
+	if err == nil || !strings.Contains(err.Error(), "未解析的环境变量: SECRET, TOKEN") {
+		t.Errorf("应该列出全部未解析的变量，实际: %v", err)
+	}
+
+	_, err = ExpandEnv("${EMPTY:?必须设置部署密钥}", lookup)
+	if err == nil || !strings.Contains(err.Error(), "EMPTY: 必须设置部署密钥") {
+		t.Errorf("应该返回自定义错误信息，实际: %v", err)
+	}
+}
+
+func TestLoadConfig_Env(t *testing.T) {
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "config.yaml")
+	os.WriteFile(configFile, []byte(`server:
+  port: ${SMARTCI_TEST_PORT:-9090}
+  auth_token: "${SMARTCI_TEST_TOKEN}"
+bash_tasks:
+  - name: "report"
+    command: "echo $${HOME} ${SMARTCI_TEST_GREETING}"
+`), 0644)
+	os.WriteFile(filepath.Join(dir, EnvFileName), []byte(`# 测试用变量
+export SMARTCI_TEST_TOKEN="from-env-file"
+SMARTCI_TEST_GREETING=hello # 行尾注释
+`), 0644)
+
+	// 进程环境变量优先于 .env 文件
+	t.Setenv("SMARTCI_TEST_GREETING", "hi")
+
+	cfg, err := LoadConfig(configFile)
+	if err != nil {
+		t.Fatalf("加载配置失败: %v", err)
+	}
+	if cfg.Server.Port != 9090 {
+		t.Errorf("端口应该使用默认值9090，实际为 %d", cfg.Server.Port)
+	}
+	if cfg.Server.AuthToken != "from-env-file" {
+		t.Errorf("应该从 .env 读取令牌，实际为 %q", cfg.Server.AuthToken)
+	}
+	if cfg.BashTasks[0].Command != "echo ${HOME} hi" {
+		t.Errorf("命令展开结果不正确: %q", cfg.BashTasks[0].Command)
+	}
+
+	// 未解析的变量导致加载失败
+	os.WriteFile(configFile, []byte("llm_key: \"${SMARTCI_TEST_UNSET_KEY}\"\n"), 0644)
+	if _, err := LoadConfig(configFile); err == nil || !strings.Contains(err.Error(), "SMARTCI_TEST_UNSET_KEY") {
+		t.Errorf("未解析的变量应该导致加载失败，实际: %v", err)
+	}
+	if err := ValidateFile(configFile); err == nil || !strings.Contains(err.Error(), "第1行") {
+		t.Errorf("校验应该报告未解析变量所在行，实际: %v", err)
+	}
+}
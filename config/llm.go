@@ -0,0 +1,83 @@
+package config
+
+// 大模型接口类型
+const (
+	LLMProviderOpenAI  = "openai"   // OpenAI 及兼容服务，Bearer 认证
+	LLMProviderAzure   = "azure"    // Azure OpenAI，api-key 请求头认证
+	LLMProviderAzureAD = "azure_ad" // Azure OpenAI，Entra ID 访问令牌（Bearer）认证
+	LLMProviderOllama  = "ollama"   // Ollama 的 OpenAI 兼容接口
+	LLMProviderVLLM    = "vllm"     // vLLM 的 OpenAI 兼容接口
+)
+
+// 大模型默认值
+const (
+	DefaultLLMModel       = "gpt-3.5-turbo"
+	DefaultLLMTimeout     = 120 // 秒
+	DefaultLLMRetries     = 3
+	DefaultLLMConcurrency = 2
+	DefaultOllamaURL      = "http://localhost:11434/v1"
+	DefaultVLLMURL        = "http://localhost:8000/v1"
+)
+
+// LLMSettings 返回生效的大模型配置：未配置的 llm 字段使用旧的 llm_key、llm_base 或默认值
+func (c Config) LLMSettings() LLMConfig {
+	llm := c.LLM
+	if llm.Provider == "" {
+		llm.Provider = LLMProviderOpenAI
+	}
+	if llm.APIKey == "" {
+		llm.APIKey = c.LLMKey
+	}
+	if llm.BaseURL == "" {
+		switch llm.Provider {
+		case LLMProviderOllama:
+			llm.BaseURL = DefaultOllamaURL
+		case LLMProviderVLLM:
+			llm.BaseURL = DefaultVLLMURL
+		case LLMProviderOpenAI:
+			llm.BaseURL = c.LLMBase
+		}
+	}
+	if llm.Model == "" && llm.Provider == LLMProviderOpenAI {
+		llm.Model = DefaultLLMModel
+	}
+	if llm.Timeout <= 0 {
+		llm.Timeout = DefaultLLMTimeout
+	}
+	if llm.MaxRetries == nil {
+		retries := DefaultLLMRetries
+		llm.MaxRetries = &retries
+	}
+	if llm.MaxConcurrency <= 0 {
+		llm.MaxConcurrency = DefaultLLMConcurrency
+	}
+	return llm
+}
+
+// Configured 判断是否可以调用大模型：配置了 API Key，或使用不需要认证的本地服务
+func (l LLMConfig) Configured() bool {
+	return l.APIKey != "" || l.Provider == LLMProviderOllama || l.Provider == LLMProviderVLLM
+}
+
+// WithOverrides 返回叠加任务 ai 配置中模型参数后的配置，任务未配置的参数保持不变
+func (l LLMConfig) WithOverrides(ai AIConfig) LLMConfig {
+	if ai.Model != "" {
+		l.Model = ai.Model
+	}
+	if ai.Temperature != nil {
+		l.Temperature = ai.Temperature
+	}
+	if ai.MaxTokens > 0 {
+		l.MaxTokens = ai.MaxTokens
+	}
+	if ai.Timeout > 0 {
+		l.Timeout = ai.Timeout
+	}
+	if ai.SystemPrompt != "" {
+		l.SystemPrompt = ai.SystemPrompt
+	}
+	if ai.MaxContextTokens > 0 {
+		l.MaxContextTokens = ai.MaxContextTokens
+	}
+	return l
+}
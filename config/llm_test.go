@@ -0,0 +1,43 @@
+package config
+
+import "testing"
+
+func TestLLMSettings(t *testing.T) {
+	t.Run("兼容旧配置", func(t *testing.T) {
+		llm := Config{LLMKey: "key", LLMBase: "https://llm.example.com/v1"}.LLMSettings()
+		if llm.Provider != LLMProviderOpenAI || llm.APIKey != "key" || llm.BaseURL != "https://llm.example.com/v1" {
+			t.Errorf("旧配置未生效: %+v", llm)
+		}
+		if llm.Model != DefaultLLMModel || llm.Timeout != DefaultLLMTimeout {
+			t.Errorf("默认值不正确: %+v", llm)
+		}
+		if !llm.Configured() {
+			t.Error("配置了 API Key 时应视为已配置")
+		}
+	})
+
+	t.Run("本地服务", func(t *testing.T) {
+		llm := Config{LLMBase: "https://api.openai.com/v1", LLM: LLMConfig{Provider: LLMProviderOllama, Model: "llama3"}}.LLMSettings()
+		if llm.BaseURL != DefaultOllamaURL || llm.Model != "llama3" {
+			t.Errorf("ollama 默认地址不正确: %+v", llm)
+		}
+		if !llm.Configured() {
+			t.Error("本地服务不需要 API Key")
+		}
+	})
+
+	t.Run("任务覆盖", func(t *testing.T) {
+		temperature := float32(0.2)
+		base := Config{LLM: LLMConfig{Model: "gpt-4o-mini", MaxTokens: 500, SystemPrompt: "全局"}}.LLMSettings()
+		llm := base.WithOverrides(AIConfig{Model: "gpt-4o", Temperature: &temperature, Timeout: 30})
+		if llm.Model != "gpt-4o" || *llm.Temperature != 0.2 || llm.Timeout != 30 {
+			t.Errorf("任务覆盖未生效: %+v", llm)
+		}
+		if llm.MaxTokens != 500 || llm.SystemPrompt != "全局" {
+			t.Errorf("任务未配置的参数应保持不变: %+v", llm)
+		}
+		if base.Model != "gpt-4o-mini" {
+			t.Error("覆盖不应修改全局配置")
+		}
+	})
+}
@@ -22,13 +22,18 @@ func LoadConfig(filename string) (Config, error) {
 			return cfg, err
 		}
 		
-		err = yaml.Unmarshal(data, &cfg)
+		root, err := parseConfigNode(filename, data)
 		if err != nil {
 			return cfg, err
 		}
+		if root.Kind != 0 {
+			if err := root.Decode(&cfg); err != nil {
+				return cfg, err
+			}
+		}
 	}
 	
-	// 从环境变量覆盖配置
+	// 从环境变量覆盖配置（兼容未在配置文件中引用变量的旧用法）
 	if llmKey := os.Getenv("OPENAI_API_KEY"); llmKey != "" {
 		cfg.LLMKey = llmKey
 	}
@@ -39,6 +44,23 @@ func LoadConfig(filename string) (Config, error) {
 	return cfg, nil
 }
 
+// parseConfigNode 解析配置文件并展开其中的环境变量引用
+func parseConfigNode(filename string, data []byte) (*yaml.Node, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, err
+	}
+
+	lookup, err := envLookupFor(filename)
+	if err != nil {
+		return nil, err
+	}
+	if errs := expandNode(&root, lookup); len(errs) > 0 {
+		return nil, formatEnvErrors(errs)
+	}
+	return &root, nil
+}
+
 // SaveConfig 保存配置到YAML文件
 func SaveConfig(cfg Config, filename string) error {
 	data, err := yaml.Marshal(cfg)
@@ -0,0 +1,93 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 运行参数类型
+const (
+	ParamString = "string"
+	ParamInt    = "int"
+	ParamBool   = "bool"
+	ParamChoice = "choice"
+)
+
+var validParamTypes = []string{ParamString, ParamInt, ParamBool, ParamChoice}
+
+// ResolveParams 按参数定义校验传入的参数值并补全默认值，返回规范化后的参数
+// 未定义的参数、类型不符的值和缺少的必需参数都会报错
+func ResolveParams(defs []ParamConfig, values map[string]string) (map[string]string, error) {
+	defined := make(map[string]bool, len(defs))
+	for _, def := range defs {
+		defined[def.Name] = true
+	}
+
+	var errs, unknown []string
+	for name := range values {
+		if !defined[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		errs = append(errs, fmt.Sprintf("未定义的参数: %s", strings.Join(unknown, ", ")))
+	}
+
+	params := make(map[string]string, len(defs))
+	for _, def := range defs {
+		value, provided := values[def.Name]
+		if !provided {
+			value, provided = def.Default, def.Default != ""
+		}
+		if !provided {
+			if def.Required {
+				errs = append(errs, fmt.Sprintf("缺少必需参数: %s", def.Name))
+			}
+			continue
+		}
+
+		normalized, err := normalizeParam(def, value)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("参数 %s: %v", def.Name, err))
+			continue
+		}
+		params[def.Name] = normalized
+	}
+
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return params, nil
+}
+
+// normalizeParam 按参数类型校验并规范化参数值，bool 统一为 true/false
+func normalizeParam(def ParamConfig, value string) (string, error) {
+	switch def.Type {
+	case "", ParamString:
+		return value, nil
+	case ParamInt:
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			return "", fmt.Errorf("%q 不是整数", value)
+		}
+		return strconv.Itoa(n), nil
+	case ParamBool:
+		switch strings.ToLower(strings.TrimSpace(value)) {
+		case "true", "1", "yes", "on":
+			return "true", nil
+		case "false", "0", "no", "off":
+			return "false", nil
+		}
+		return "", fmt.Errorf("%q 不是布尔值", value)
+	case ParamChoice:
+		if !contains(def.Choices, value) {
+			return "", fmt.Errorf("%q 不在可选值中: %s", value, strings.Join(def.Choices, ", "))
+		}
+		return value, nil
+	default:
+		return "", fmt.Errorf("未知的参数类型 %q", def.Type)
+	}
+}
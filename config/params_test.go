@@ -0,0 +1,43 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestResolveParams(t *testing.T) {
+	defs := []ParamConfig{
+		{Name: "VERSION", Required: true},
+		{Name: "REPLICAS", Type: ParamInt, Default: "2"},
+		{Name: "DRY_RUN", Type: ParamBool, Default: "false"},
+		{Name: "TARGET", Type: ParamChoice, Choices: []string{"staging", "production"}},
+	}
+
+	t.Run("默认值与规范化", func(t *testing.T) {
+		params, err := ResolveParams(defs, map[string]string{"VERSION": "1.2.3", "DRY_RUN": "yes"})
+		if err != nil {
+			t.Fatalf("解析参数失败: %v", err)
+		}
+		want := map[string]string{"VERSION": "1.2.3", "REPLICAS": "2", "DRY_RUN": "true"}
+		if len(params) != len(want) {
+			t.Fatalf("参数数量不正确: %v", params)
+		}
+		for name, value := range want {
+			if params[name] != value {
+				t.Errorf("参数 %s: got %q, want %q", name, params[name], value)
+			}
+		}
+	})
+
+	t.Run("无效参数", func(t *testing.T) {
+		_, err := ResolveParams(defs, map[string]string{"REPLICAS": "two", "TARGET": "dev", "EXTRA": "1"})
+		if err == nil {
+			t.Fatal("预期解析失败，但解析成功")
+		}
+		for _, want := range []string{"未定义的参数: EXTRA", "缺少必需参数: VERSION", "参数 REPLICAS", "参数 TARGET"} {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("错误信息中缺少 %q: %v", want, err)
+			}
+		}
+	})
+}
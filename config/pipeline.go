@@ -0,0 +1,82 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Enabled 是否配置了多阶段流水线
+func (p PipelineConfig) Enabled() bool {
+	return len(p.Stages) > 0
+}
+
+// Validate 校验流水线定义：阶段和步骤名称唯一、依赖存在且不存在循环依赖
+func (p PipelineConfig) Validate() error {
+	stages := make(map[string]StageConfig, len(p.Stages))
+	for _, stage := range p.Stages {
+		if stage.Name == "" {
+			return fmt.Errorf("流水线阶段缺少名称")
+		}
+		if _, exists := stages[stage.Name]; exists {
+			return fmt.Errorf("流水线阶段名称重复: %s", stage.Name)
+		}
+		if len(stage.Steps) == 0 {
+			return fmt.Errorf("流水线阶段 '%s' 没有配置步骤", stage.Name)
+		}
+
+		steps := make(map[string]bool, len(stage.Steps))
+		for _, step := range stage.Steps {
+			if step.Name == "" {
+				return fmt.Errorf("流水线阶段 '%s' 中存在未命名的步骤", stage.Name)
+			}
+			if steps[step.Name] {
+				return fmt.Errorf("流水线阶段 '%s' 中步骤名称重复: %s", stage.Name, step.Name)
+			}
+			if strings.TrimSpace(step.Command) == "" {
+				return fmt.Errorf("流水线步骤 '%s/%s' 没有配置命令", stage.Name, step.Name)
+			}
+			steps[step.Name] = true
+		}
+		stages[stage.Name] = stage
+	}
+
+	for _, stage := range p.Stages {
+		for _, need := range stage.Needs {
+			if _, exists := stages[need]; !exists {
+				return fmt.Errorf("流水线阶段 '%s' 依赖了不存在的阶段: %s", stage.Name, need)
+			}
+		}
+	}
+
+	// 深度优先遍历检测循环依赖
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int, len(stages))
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch state[name] {
+		case visiting:
+			return fmt.Errorf("流水线存在循环依赖: %s", strings.Join(append(path, name), " -> "))
+		case visited:
+			return nil
+		}
+		state[name] = visiting
+		for _, need := range stages[name].Needs {
+			if err := visit(need, append(path, name)); err != nil {
+				return err
+			}
+		}
+		state[name] = visited
+		return nil
+	}
+	for _, stage := range p.Stages {
+		if err := visit(stage.Name, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,82 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// templateFuncs Webhook动作模板可用的函数
+var templateFuncs = template.FuncMap{
+	// quote 将值转义为单引号包裹的shell字符串，避免payload中的内容被shell解释
+	"quote": func(value interface{}) string {
+		s := ""
+		if value != nil {
+			s = fmt.Sprint(value)
+		}
+		return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+	},
+}
+
+// noValue text/template 对不存在的字段输出的占位文本
+const noValue = "<no value>"
+
+func parseTemplate(text string) (*template.Template, error) {
+	return template.New("action").Funcs(templateFuncs).Parse(text)
+}
+
+// RenderTemplate 使用 data 展开 Go 模板，如 {{ .ref }}、{{ .repository.full_name }}
+// 不含模板标记的文本原样返回，不存在的字段展开为空字符串
+func RenderTemplate(text string, data interface{}) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+	tmpl, err := parseTemplate(text)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return "", err
+	}
+	return strings.ReplaceAll(sb.String(), noValue, ""), nil
+}
+
+// Render 使用 data 展开动作中的模板字段：command、working_dir、env 和 params 的值
+func (a WebhookAction) Render(data interface{}) (WebhookAction, error) {
+	rendered := a
+	var err error
+	if rendered.Command, err = RenderTemplate(a.Command, data); err != nil {
+		return a, fmt.Errorf("展开 command 模板失败: %v", err)
+	}
+	if rendered.WorkingDir, err = RenderTemplate(a.WorkingDir, data); err != nil {
+		return a, fmt.Errorf("展开 working_dir 模板失败: %v", err)
+	}
+	if rendered.Env, err = renderValues(a.Env, data); err != nil {
+		return a, fmt.Errorf("展开 env 模板失败: %v", err)
+	}
+	if rendered.Params, err = renderValues(a.Params, data); err != nil {
+		return a, fmt.Errorf("展开 params 模板失败: %v", err)
+	}
+	return rendered, nil
+}
+
+func renderValues(values map[string]string, data interface{}) (map[string]string, error) {
+	if values == nil {
+		return nil, nil
+	}
+	rendered := make(map[string]string, len(values))
+	for name, value := range values {
+		v, err := RenderTemplate(value, data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		rendered[name] = v
+	}
+	return rendered, nil
+}
+
+// isTemplate 值中是否包含模板标记，包含时只能在运行时校验
+func isTemplate(value string) bool {
+	return strings.Contains(value, "{{")
+}
@@ -0,0 +1,54 @@
+package config
+
+import "testing"
+
+func TestWebhookActionRender(t *testing.T) {
+	payload := map[string]interface{}{
+		"ref":        "refs/heads/main",
+		"repository": map[string]interface{}{"full_name": "user/repo"},
+		"head_commit": map[string]interface{}{
+			"message": "fix: it's done",
+		},
+	}
+
+	t.Run("展开模板字段", func(t *testing.T) {
+		action := WebhookAction{
+			Type:       "command",
+			Command:    "echo {{ .repository.full_name }}@{{ .ref }} {{ .pull_request.number }}",
+			WorkingDir: "/src/{{ .repository.full_name }}",
+			Env:        map[string]string{"MESSAGE": "{{ .head_commit.message }}", "STATIC": "x"},
+		}
+		rendered, err := action.Render(payload)
+		if err != nil {
+			t.Fatalf("展开模板失败: %v", err)
+		}
+		if rendered.Command != "echo user/repo@refs/heads/main " {
+			t.Errorf("command 展开不正确，不存在的字段应为空: %q", rendered.Command)
+		}
+		if rendered.WorkingDir != "/src/user/repo" {
+			t.Errorf("working_dir 展开不正确: %q", rendered.WorkingDir)
+		}
+		if rendered.Env["MESSAGE"] != "fix: it's done" || rendered.Env["STATIC"] != "x" {
+			t.Errorf("env 展开不正确: %v", rendered.Env)
+		}
+		if action.Env["MESSAGE"] != "{{ .head_commit.message }}" {
+			t.Errorf("展开模板不应该修改原动作: %v", action.Env)
+		}
+	})
+
+	t.Run("shell转义", func(t *testing.T) {
+		out, err := RenderTemplate("echo {{ quote .head_commit.message }}", payload)
+		if err != nil {
+			t.Fatalf("展开模板失败: %v", err)
+		}
+		if out != `echo 'fix: it'\''s done'` {
+			t.Errorf("quote 转义不正确: %s", out)
+		}
+	})
+
+	t.Run("无效模板", func(t *testing.T) {
+		if _, err := (WebhookAction{Command: "echo {{ .ref"}).Render(payload); err == nil {
+			t.Error("预期展开失败，但展开成功")
+		}
+	})
+}
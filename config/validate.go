@@ -0,0 +1,771 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/robfig/cron/v3"
+	"gopkg.in/yaml.v3"
+)
+
+// 已支持的取值
+var (
+	validStoreTypes   = []string{"json", "bolt"}
+	validConcurrency  = []string{"allow", "skip", "queue", "cancel-previous"}
+	validActionTypes  = []string{"command", "script", "task", "pipeline"}
+	supportedProvider = []string{"github", "gitlab", "gitea", "forgejo", "bitbucket"}
+	validSecretTypes  = []string{"encrypted", "env", "file"}
+	reportProviders   = []string{"github", "gitlab", "gitea", "forgejo"}
+	validReportAPIs   = []string{"statuses", "checks"}
+	validLLMProviders = []string{LLMProviderOpenAI, LLMProviderAzure, LLMProviderAzureAD, LLMProviderOllama, LLMProviderVLLM}
+)
+
+// ValidationError 单条校验错误，Line 为配置文件中的行号（未知时为0）
+type ValidationError struct {
+	Line    int    `json:"line"`
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e ValidationError) Error() string {
+	msg := e.Message
+	if e.Field != "" {
+		msg = e.Field + ": " + msg
+	}
+	if e.Line > 0 {
+		return fmt.Sprintf("第%d行 %s", e.Line, msg)
+	}
+	return msg
+}
+
+// ValidationErrors 全部校验错误
+type ValidationErrors []ValidationError
+
+func (errs ValidationErrors) Error() string {
+	lines := make([]string, 0, len(errs))
+	for _, err := range errs {
+		lines = append(lines, err.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ValidateFile 读取并严格校验配置文件，返回带行号的全部错误
+// 未知字段、YAML语法错误和未解析的环境变量同样视为校验失败
+func ValidateFile(filename string) error {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return fmt.Errorf("读取配置文件失败: %v", err)
+	}
+	lookup, err := envLookupFor(filename)
+	if err != nil {
+		return err
+	}
+	return validateData(data, lookup)
+}
+
+// ValidateBytes 严格校验YAML格式的配置内容，变量从进程环境变量中查找
+func ValidateBytes(data []byte) error {
+	return validateData(data, os.LookupEnv)
+}
+
+func validateData(data []byte, lookup EnvLookup) error {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return fmt.Errorf("解析配置文件失败: %v", err)
+	}
+
+	v := &validator{root: &root}
+
+	for _, e := range expandNode(&root, lookup) {
+		msg := fmt.Sprintf("未解析的环境变量: %s", e.Name)
+		if e.Message != "" {
+			msg = fmt.Sprintf("环境变量 %s %s", e.Name, e.Message)
+		}
+		v.errs = append(v.errs, ValidationError{Line: e.Line, Message: msg})
+	}
+
+	// 未知字段只能在原始内容上检查，展开后的节点树不支持 KnownFields
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&Config{}); err != nil && !errors.Is(err, io.EOF) {
+		if typeErr, ok := err.(*yaml.TypeError); ok {
+			for _, msg := range typeErr.Errors {
+				if strings.Contains(msg, "not found in type") {
+					v.errs = append(v.errs, parseDecodeError(msg))
+				}
+			}
+		}
+	}
+
+	// 类型不匹配会逐条返回，其余字段仍会被解码，继续做语义校验
+	var cfg Config
+	if root.Kind != 0 {
+		if err := root.Decode(&cfg); err != nil {
+			typeErr, ok := err.(*yaml.TypeError)
+			if !ok {
+				return fmt.Errorf("解析配置文件失败: %v", err)
+			}
+			for _, msg := range typeErr.Errors {
+				v.errs = append(v.errs, parseDecodeError(msg))
+			}
+		}
+	}
+
+	v.validate(cfg)
+	if len(v.errs) > 0 {
+		return v.errs
+	}
+	return nil
+}
+
+// Validate 校验已加载的配置，错误不包含行号
+func Validate(cfg Config) error {
+	v := &validator{}
+	v.validate(cfg)
+	if len(v.errs) > 0 {
+		return v.errs
+	}
+	return nil
+}
+
+// validator 收集校验错误，root 非空时用于定位行号
+type validator struct {
+	root *yaml.Node
+	errs ValidationErrors
+}
+
+// addf 记录一条错误，path 为字段路径，元素为字符串键或整数下标
+func (v *validator) addf(path []interface{}, format string, args ...interface{}) {
+	v.errs = append(v.errs, ValidationError{
+		Line:    v.line(path),
+		Field:   formatPath(path),
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+func (v *validator) validate(cfg Config) {
+	v.validateServer(cfg.Server)
+
+	if cfg.Schedule != "" {
+		if err := validateSchedule(cfg.Schedule); err != nil {
+			v.addf(path("schedule"), "调度表达式无效: %v", err)
+		}
+	}
+	if cfg.Store.Type != "" && !contains(validStoreTypes, cfg.Store.Type) {
+		v.addf(path("store", "type"), "未知的存储类型 %q，可选: %s", cfg.Store.Type, strings.Join(validStoreTypes, ", "))
+	}
+
+	v.validateSecrets(cfg.Secrets)
+	v.validateLLM(cfg.LLM)
+	v.validateContainerPolicy(cfg.ContainerPolicy)
+
+	repoNames := make(map[string]int)
+	for i, repo := range cfg.Repos {
+		v.validateRepo(i, repo, repoNames, cfg.Schedule != "", cfg.ContainerPolicy)
+	}
+
+	taskNames := make(map[string]int)
+	for i, task := range cfg.BashTasks {
+		v.validateBashTask(i, task, taskNames, cfg.ContainerPolicy)
+	}
+
+	providers := make(map[string]int)
+	for i, oauthCfg := range cfg.OAuth {
+		p := path("oauth", i)
+		if oauthCfg.Name == "" {
+			v.addf(p, "缺少 name")
+			continue
+		}
+		if prev, exists := providers[oauthCfg.Name]; exists {
+			v.addf(append(p, "name"), "OAuth提供商重复，与 oauth[%d] 同名", prev)
+		}
+		providers[oauthCfg.Name] = i
+		if !contains(supportedProvider, oauthCfg.Name) {
+			v.addf(append(p, "name"), "不支持的OAuth提供商 %q，可选: %s", oauthCfg.Name, strings.Join(supportedProvider, ", "))
+		}
+		if oauthCfg.Name == "forgejo" && oauthCfg.BaseURL == "" {
+			v.addf(p, "forgejo 提供商必须配置实例地址 base_url")
+		}
+		if oauthCfg.BaseURL != "" && !validHTTPURL(oauthCfg.BaseURL) {
+			v.addf(append(p, "base_url"), "无效的地址 %q，必须是 http(s) 地址", oauthCfg.BaseURL)
+		}
+	}
+
+	webhookNames := make(map[string]int)
+	webhookPaths := make(map[string]int)
+	for i, webhookCfg := range cfg.Webhooks {
+		v.validateWebhook(i, webhookCfg, webhookNames, webhookPaths, providers, taskNames, repoNames, cfg)
+	}
+}
+
+func (v *validator) validateServer(server ServerConfig) {
+	if server.Port < 0 || server.Port > 65535 {
+		v.addf(path("server", "port"), "端口超出范围: %d", server.Port)
+	}
+	if server.MaxConcurrency < 0 {
+		v.addf(path("server", "max_concurrency"), "不能为负数")
+	}
+	if server.ExternalURL != "" && !validHTTPURL(server.ExternalURL) {
+		v.addf(path("server", "external_url"), "无效的地址 %q，必须是 http(s) 地址", server.ExternalURL)
+	}
+	if server.TLS.Enabled {
+		if server.TLS.CertFile == "" {
+			v.addf(path("server", "tls", "cert_file"), "启用TLS时必须配置证书文件")
+		}
+		if server.TLS.KeyFile == "" {
+			v.addf(path("server", "tls", "key_file"), "启用TLS时必须配置私钥文件")
+		}
+	}
+}
+
+func (v *validator) validateRepo(i int, repo RepoConfig, names map[string]int, scheduled bool, policy ContainerPolicy) {
+	p := path("repos", i)
+	if repo.Name == "" {
+		v.addf(p, "缺少 name")
+	} else {
+		if prev, exists := names[repo.Name]; exists {
+			v.addf(append(p, "name"), "仓库名称重复，与 repos[%d] 同名", prev)
+		}
+		names[repo.Name] = i
+	}
+	if repo.URL == "" {
+		v.addf(p, "缺少 url")
+	}
+	if len(repo.Branches) == 0 {
+		v.addf(p, "至少需要配置一个分支 branches")
+	}
+	if repo.Pipeline.Enabled() {
+		if err := repo.Pipeline.Validate(); err != nil {
+			v.addf(append(p, "pipeline"), "%v", err)
+		}
+	}
+	if repo.Concurrency != "" && !contains(validConcurrency, repo.Concurrency) {
+		v.addf(append(p, "concurrency"), "未知的并发策略 %q，可选: %s", repo.Concurrency, strings.Join(validConcurrency, ", "))
+	}
+	v.validateSecretNames(append(p, "secrets"), repo.Secrets)
+	v.validateEnv(append(p, "env"), repo.Env)
+	v.validateParams(append(p, "params"), repo.Params)
+	v.validateAI(append(p, "ai"), repo.AI)
+	v.validateContainer(append(p, "container"), repo.Container, policy)
+	if scheduled {
+		v.validateScheduledParams(append(p, "params"), repo.Params, "全局定时调度")
+	}
+}
+
+func (v *validator) validateContainerPolicy(policy ContainerPolicy) {
+	p := path("container_policy")
+	if policy.MaxCPUs < 0 {
+		v.addf(append(p, "max_cpus"), "不能为负数")
+	}
+	if policy.MaxMemory != "" {
+		if _, err := ParseMemory(policy.MaxMemory); err != nil {
+			v.addf(append(p, "max_memory"), "%v", err)
+		}
+	}
+	if policy.MaxPids < 0 {
+		v.addf(append(p, "max_pids"), "不能为负数")
+	}
+	for i, volume := range policy.Volumes {
+		if volume == "" {
+			v.addf(append(p, "volumes", i), "不能为空")
+		}
+	}
+}
+
+// validateContainer 校验仓库的容器配置，资源限制不能超过 container_policy 的上限，网络和挂载必须在策略允许的范围内
+func (v *validator) validateContainer(p []interface{}, c ContainerConfig, policy ContainerPolicy) {
+	if c.CPUs < 0 {
+		v.addf(append(p, "cpus"), "不能为负数")
+	} else if policy.MaxCPUs > 0 && c.CPUs > policy.MaxCPUs {
+		v.addf(append(p, "cpus"), "超过 container_policy.max_cpus 的上限: %g > %g", c.CPUs, policy.MaxCPUs)
+	}
+	if c.Memory != "" {
+		memory, err := ParseMemory(c.Memory)
+		if err != nil {
+			v.addf(append(p, "memory"), "%v", err)
+		} else if limit, err := ParseMemory(policy.MaxMemory); err == nil && memory > limit {
+			v.addf(append(p, "memory"), "超过 container_policy.max_memory 的上限: %s > %s", c.Memory, policy.MaxMemory)
+		}
+	}
+	if c.PidsLimit < 0 {
+		v.addf(append(p, "pids_limit"), "不能为负数")
+	} else if policy.MaxPids > 0 && c.PidsLimit > policy.MaxPids {
+		v.addf(append(p, "pids_limit"), "超过 container_policy.max_pids 的上限: %d > %d", c.PidsLimit, policy.MaxPids)
+	}
+	if !policy.AllowsNetwork(c.Network) {
+		v.addf(append(p, "network"), "container_policy 不允许使用网络模式 %q", c.Network)
+	}
+	if policy.ForbidRoot && RootUser(c.User) {
+		v.addf(append(p, "user"), "container_policy 禁止以 root 运行，必须配置非 root 的 user")
+	}
+	for i, spec := range c.Tmpfs {
+		if _, _, err := ParseTmpfs(spec); err != nil {
+			v.addf(append(p, "tmpfs", i), "%v", err)
+		}
+	}
+	for i, spec := range c.Volumes {
+		source, _, _, err := ParseVolume(spec)
+		if err != nil {
+			v.addf(append(p, "volumes", i), "%v", err)
+		} else if !policy.AllowsVolume(source) {
+			v.addf(append(p, "volumes", i), "container_policy.volumes 不允许挂载 %q", source)
+		}
+	}
+}
+
+func (v *validator) validateBashTask(i int, task BashTaskConfig, names map[string]int, policy ContainerPolicy) {
+	p := path("bash_tasks", i)
+	if task.Name == "" {
+		v.addf(p, "缺少 name")
+	} else {
+		if prev, exists := names[task.Name]; exists {
+			v.addf(append(p, "name"), "Bash任务名称重复，与 bash_tasks[%d] 同名", prev)
+		}
+		names[task.Name] = i
+	}
+	switch {
+	case task.Command == "" && task.ScriptFile == "":
+		v.addf(p, "必须配置 command 或 script_file")
+	case task.Command != "" && task.ScriptFile != "":
+		v.addf(p, "command 和 script_file 只能配置一个")
+	}
+	if task.Schedule != "" {
+		if err := validateSchedule(task.Schedule); err != nil {
+			v.addf(append(p, "schedule"), "调度表达式无效: %v", err)
+		}
+	}
+	if task.Timeout < 0 {
+		v.addf(append(p, "timeout"), "不能为负数")
+	}
+	if task.Concurrency != "" && !contains(validConcurrency, task.Concurrency) {
+		v.addf(append(p, "concurrency"), "未知的并发策略 %q，可选: %s", task.Concurrency, strings.Join(validConcurrency, ", "))
+	}
+	v.validateSecretNames(append(p, "secrets"), task.Secrets)
+	v.validateEnv(append(p, "env"), task.Env)
+	v.validateParams(append(p, "params"), task.Params)
+	v.validateAI(append(p, "ai"), task.AI)
+	if task.Image != "" {
+		v.validateContainer(append(p, "container"), task.Container, policy)
+	} else if !task.Container.IsZero() {
+		v.addf(append(p, "container"), "只有配置 image 时才能使用容器配置")
+	}
+	if task.Sandbox.Enabled && task.Image != "" {
+		v.addf(append(p, "sandbox"), "沙箱用于在服务器上执行的任务，不能与 image 同时配置")
+	}
+	v.validateSandbox(append(p, "sandbox"), task.Sandbox)
+	if task.Schedule != "" {
+		v.validateScheduledParams(append(p, "params"), task.Params, "定时调度")
+	}
+}
+
+// validateSandbox 校验Bash任务的沙箱配置，可写目录必须是绝对路径
+func (v *validator) validateSandbox(p []interface{}, sandbox SandboxConfig) {
+	if sandbox.CPUs < 0 {
+		v.addf(append(p, "cpus"), "不能为负数")
+	}
+	if sandbox.Memory != "" {
+		if _, err := ParseMemory(sandbox.Memory); err != nil {
+			v.addf(append(p, "memory"), "%v", err)
+		}
+	}
+	if sandbox.PidsLimit < 0 {
+		v.addf(append(p, "pids_limit"), "不能为负数")
+	}
+	for i, dir := range sandbox.Writable {
+		if !filepath.IsAbs(dir) {
+			v.addf(append(p, "writable", i), "可写目录必须是绝对路径: %q", dir)
+		}
+	}
+}
+
+// validateLLM azure 必须配置资源地址和部署名称，本地服务没有默认模型
+func (v *validator) validateLLM(llm LLMConfig) {
+	p := path("llm")
+	if llm.Provider != "" && !contains(validLLMProviders, llm.Provider) {
+		v.addf(append(p, "provider"), "未知的接口类型 %q，可选: %s", llm.Provider, strings.Join(validLLMProviders, ", "))
+	}
+	if llm.BaseURL != "" && !validHTTPURL(llm.BaseURL) {
+		v.addf(append(p, "base_url"), "无效的地址 %q，必须是 http(s) 地址", llm.BaseURL)
+	}
+	switch llm.Provider {
+	case LLMProviderAzure, LLMProviderAzureAD:
+		if llm.BaseURL == "" {
+			v.addf(p, "%s 必须配置资源地址 base_url", llm.Provider)
+		}
+		if llm.Model == "" {
+			v.addf(p, "%s 必须配置部署名称 model", llm.Provider)
+		}
+	case LLMProviderOllama, LLMProviderVLLM:
+		if llm.Model == "" {
+			v.addf(p, "%s 必须配置 model", llm.Provider)
+		}
+	}
+	v.validateModelParams(p, llm.Temperature, llm.MaxTokens, llm.Timeout, llm.MaxContextTokens)
+	if llm.MaxRetries != nil && *llm.MaxRetries < 0 {
+		v.addf(append(p, "max_retries"), "不能为负数")
+	}
+	if llm.MaxConcurrency < 0 {
+		v.addf(append(p, "max_concurrency"), "不能为负数")
+	}
+	if llm.RequestsPerMinute < 0 {
+		v.addf(append(p, "requests_per_minute"), "不能为负数")
+	}
+}
+
+func (v *validator) validateAI(p []interface{}, ai AIConfig) {
+	v.validateModelParams(p, ai.Temperature, ai.MaxTokens, ai.Timeout, ai.MaxContextTokens)
+}
+
+func (v *validator) validateModelParams(p []interface{}, temperature *float32, maxTokens, timeout, maxContextTokens int) {
+	if temperature != nil && (*temperature < 0 || *temperature > 2) {
+		v.addf(append(p, "temperature"), "超出范围 0-2: %g", *temperature)
+	}
+	if maxTokens < 0 {
+		v.addf(append(p, "max_tokens"), "不能为负数")
+	}
+	if timeout < 0 {
+		v.addf(append(p, "timeout"), "不能为负数")
+	}
+	if maxContextTokens < 0 {
+		v.addf(append(p, "max_context_tokens"), "不能为负数")
+	}
+}
+
+func (v *validator) validateEnv(p []interface{}, env map[string]string) {
+	for name := range env {
+		if !validEnvName(name) {
+			v.addf(p, "环境变量名不合法: %q", name)
+		}
+	}
+}
+
+// validateParams 参数以同名环境变量注入，名称必须是合法的环境变量名，默认值必须符合参数类型
+func (v *validator) validateParams(p []interface{}, params []ParamConfig) {
+	names := make(map[string]int)
+	for j, param := range params {
+		pp := append(append([]interface{}{}, p...), j)
+		switch {
+		case param.Name == "":
+			v.addf(pp, "缺少 name")
+		case !validEnvName(param.Name):
+			v.addf(append(pp, "name"), "参数名称 %q 不是合法的环境变量名", param.Name)
+		default:
+			if prev, exists := names[param.Name]; exists {
+				v.addf(append(pp, "name"), "参数名称重复，与 params[%d] 同名", prev)
+			}
+			names[param.Name] = j
+		}
+
+		if param.Type != "" && !contains(validParamTypes, param.Type) {
+			v.addf(append(pp, "type"), "未知的参数类型 %q，可选: %s", param.Type, strings.Join(validParamTypes, ", "))
+			continue
+		}
+		if param.Type == ParamChoice && len(param.Choices) == 0 {
+			v.addf(pp, "choice 类型的参数必须配置 choices")
+			continue
+		}
+		if param.Default != "" {
+			if _, err := normalizeParam(param, param.Default); err != nil {
+				v.addf(append(pp, "default"), "默认值无效: %v", err)
+			}
+		}
+	}
+}
+
+// validateScheduledParams 定时调度运行时不传参数，必需参数必须配置默认值
+func (v *validator) validateScheduledParams(p []interface{}, params []ParamConfig, trigger string) {
+	for j, param := range params {
+		if param.Required && param.Default == "" {
+			v.addf(append(append([]interface{}{}, p...), j), "%s运行时不传参数，必需参数 %s 必须配置 default", trigger, param.Name)
+		}
+	}
+}
+
+func (v *validator) validateSecrets(secrets SecretsConfig) {
+	for i, provider := range secrets.Providers {
+		p := path("secrets", "providers", i)
+		switch provider.Type {
+		case "encrypted":
+			if provider.Path == "" {
+				v.addf(p, "encrypted 类型的密钥提供者必须配置 path")
+			}
+		case "env", "file":
+		default:
+			v.addf(append(p, "type"), "未知的密钥提供者类型 %q，可选: %s", provider.Type, strings.Join(validSecretTypes, ", "))
+		}
+	}
+}
+
+// validateSecretNames 密钥以同名环境变量注入，名称必须是合法的环境变量名
+func (v *validator) validateSecretNames(p []interface{}, names []string) {
+	for j, name := range names {
+		if !validEnvName(name) {
+			v.addf(append(p, j), "密钥名称 %q 不是合法的环境变量名", name)
+		}
+	}
+}
+
+func (v *validator) validateWebhook(i int, webhookCfg WebhookConfig, names, paths, providers, tasks, repos map[string]int, cfg Config) {
+	p := path("webhooks", i)
+	if webhookCfg.Name == "" {
+		v.addf(p, "缺少 name")
+	} else {
+		if prev, exists := names[webhookCfg.Name]; exists {
+			v.addf(append(p, "name"), "Webhook名称重复，与 webhooks[%d] 同名", prev)
+		}
+		names[webhookCfg.Name] = i
+	}
+
+	switch {
+	case webhookCfg.Path == "":
+		v.addf(p, "缺少 path")
+	case !strings.HasPrefix(webhookCfg.Path, "/"):
+		v.addf(append(p, "path"), "路径必须以 / 开头: %s", webhookCfg.Path)
+	default:
+		if prev, exists := paths[webhookCfg.Path]; exists {
+			v.addf(append(p, "path"), "Webhook路径重复，与 webhooks[%d] 相同", prev)
+		}
+		paths[webhookCfg.Path] = i
+	}
+
+	// 签名校验依赖对应的OAuth提供商，未配置时签名不会被验证
+	if webhookCfg.Secret != "" {
+		if _, exists := providers[webhookCfg.Provider]; !exists {
+			v.addf(append(p, "provider"), "配置了 secret 但提供商 %q 未在 oauth 中配置，签名校验不会生效", webhookCfg.Provider)
+		}
+	}
+
+	if len(webhookCfg.Actions) == 0 {
+		v.addf(p, "至少需要配置一个动作 actions")
+	}
+	for j, action := range webhookCfg.Actions {
+		ap := append(path("webhooks", i, "actions"), j)
+		switch action.Type {
+		case "command":
+			if action.Command == "" {
+				v.addf(ap, "command 类型的动作必须配置 command")
+			}
+		case "script":
+			if action.Script == "" {
+				v.addf(ap, "script 类型的动作必须配置 script")
+			}
+		case "task":
+			if action.Task == "" {
+				v.addf(ap, "task 类型的动作必须配置 task")
+			} else if idx, exists := tasks[action.Task]; !exists {
+				v.addf(append(ap, "task"), "引用的Bash任务不存在: %s", action.Task)
+			} else {
+				v.validateActionParams(append(ap, "params"), cfg.BashTasks[idx].Params, action.Params)
+			}
+			if len(action.Env) > 0 {
+				v.addf(append(ap, "env"), "task 类型的动作不支持 env，请在任务上配置 env 或通过 params 传递参数")
+			}
+		case "pipeline":
+			// 未指定 repo 时按事件中的仓库匹配，参数在运行时校验
+			if action.Repo != "" {
+				if idx, exists := repos[action.Repo]; !exists {
+					v.addf(append(ap, "repo"), "引用的仓库不存在: %s", action.Repo)
+				} else {
+					v.validateActionParams(append(ap, "params"), cfg.Repos[idx].Params, action.Params)
+				}
+			}
+			if len(action.Env) > 0 {
+				v.addf(append(ap, "env"), "pipeline 类型的动作不支持 env，请在仓库上配置 env 或通过 params 传递参数")
+			}
+		case "":
+			v.addf(ap, "缺少 type")
+		default:
+			v.addf(append(ap, "type"), "未知的动作类型 %q，可选: %s", action.Type, strings.Join(validActionTypes, ", "))
+		}
+		if action.Timeout < 0 {
+			v.addf(append(ap, "timeout"), "不能为负数")
+		}
+		if action.Type != "task" && action.Type != "pipeline" && len(action.Params) > 0 {
+			v.addf(append(ap, "params"), "只有 task 和 pipeline 类型的动作支持 params")
+		}
+		if action.Type != "pipeline" && action.Repo != "" {
+			v.addf(append(ap, "repo"), "只有 pipeline 类型的动作支持 repo")
+		}
+		v.validateEnv(append(ap, "env"), action.Env)
+		v.validateTemplate(append(ap, "command"), action.Command)
+		v.validateTemplate(append(ap, "working_dir"), action.WorkingDir)
+		for name, value := range action.Env {
+			v.validateTemplate(append(ap, "env", name), value)
+		}
+		for name, value := range action.Params {
+			v.validateTemplate(append(ap, "params", name), value)
+		}
+	}
+
+	v.validateReport(append(p, "report"), webhookCfg, providers)
+}
+
+// validateReport 校验运行状态回报和PR评论，未配置令牌时需要同名的OAuth提供商授权
+func (v *validator) validateReport(p []interface{}, webhookCfg WebhookConfig, providers map[string]int) {
+	report := webhookCfg.Report
+	if !report.Enabled && !report.Comment.Enabled {
+		return
+	}
+	if !contains(reportProviders, webhookCfg.Provider) {
+		v.addf(p, "提供商 %q 不支持回报提交状态，可选: %s", webhookCfg.Provider, strings.Join(reportProviders, ", "))
+	}
+	if report.Token == "" {
+		if _, exists := providers[webhookCfg.Provider]; !exists {
+			v.addf(append(p, "token"), "未配置 token 且提供商 %q 未在 oauth 中配置，无法获取访问令牌", webhookCfg.Provider)
+		}
+	}
+	if report.API != "" {
+		if !contains(validReportAPIs, report.API) {
+			v.addf(append(p, "api"), "未知的接口 %q，可选: %s", report.API, strings.Join(validReportAPIs, ", "))
+		} else if webhookCfg.Provider != "github" {
+			v.addf(append(p, "api"), "只有 github 提供商支持选择接口")
+		}
+	}
+	if report.BaseURL != "" && !validHTTPURL(report.BaseURL) {
+		v.addf(append(p, "base_url"), "无效的地址 %q，必须是 http(s) 地址", report.BaseURL)
+	}
+	if report.Comment.LogLines < 0 {
+		v.addf(append(p, "comment", "log_lines"), "不能为负数")
+	}
+}
+
+// validateActionParams 校验动作传给任务的参数，含模板的值在运行时展开后才能校验类型
+func (v *validator) validateActionParams(p []interface{}, defs []ParamConfig, params map[string]string) {
+	templated := make(map[string]bool)
+	static := make(map[string]string)
+	for name, value := range params {
+		if isTemplate(value) {
+			templated[name] = true
+		} else {
+			static[name] = value
+		}
+	}
+
+	var remaining []ParamConfig
+	for _, def := range defs {
+		if templated[def.Name] {
+			delete(templated, def.Name)
+			continue
+		}
+		remaining = append(remaining, def)
+	}
+	for name := range templated {
+		static[name] = ""
+	}
+	if _, err := ResolveParams(remaining, static); err != nil {
+		v.addf(p, "%v", err)
+	}
+}
+
+func (v *validator) validateTemplate(p []interface{}, text string) {
+	if !isTemplate(text) {
+		return
+	}
+	if _, err := parseTemplate(text); err != nil {
+		v.addf(p, "模板无效: %v", err)
+	}
+}
+
+// line 按字段路径在YAML节点树中查找行号，路径不存在时返回最近的上级节点行号
+func (v *validator) line(path []interface{}) int {
+	if v.root == nil {
+		return 0
+	}
+
+	node := v.root
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+	line := node.Line
+
+	for _, elem := range path {
+		var next *yaml.Node
+		switch key := elem.(type) {
+		case string:
+			if node.Kind == yaml.MappingNode {
+				for k := 0; k+1 < len(node.Content); k += 2 {
+					if node.Content[k].Value == key {
+						// 标量取键所在行，集合取值的起始行
+						next = node.Content[k+1]
+						if next.Kind == yaml.ScalarNode {
+							line = node.Content[k].Line
+						}
+						break
+					}
+				}
+			}
+		case int:
+			if node.Kind == yaml.SequenceNode && key < len(node.Content) {
+				// 序列元素取元素所在行
+				next = node.Content[key]
+				line = next.Line
+			}
+		}
+		if next == nil {
+			break
+		}
+		node = next
+		if node.Kind != yaml.ScalarNode {
+			line = node.Line
+		}
+	}
+	return line
+}
+
+// parseDecodeError 将 yaml 解码错误（"line N: ..."）转换为校验错误
+func parseDecodeError(msg string) ValidationError {
+	var line int
+	if _, err := fmt.Sscanf(msg, "line %d:", &line); err == nil {
+		msg = strings.TrimSpace(msg[strings.Index(msg, ":")+1:])
+	}
+	return ValidationError{Line: line, Message: msg}
+}
+
+// validateSchedule 使用与调度器相同的解析规则校验cron表达式
+func validateSchedule(spec string) error {
+	_, err := cron.ParseStandard(spec)
+	return err
+}
+
+// path 构造字段路径
+func path(elems ...interface{}) []interface{} {
+	return elems
+}
+
+// formatPath 将字段路径格式化为 bash_tasks[0].schedule 形式
+func formatPath(path []interface{}) string {
+	var sb strings.Builder
+	for _, elem := range path {
+		switch key := elem.(type) {
+		case string:
+			if sb.Len() > 0 {
+				sb.WriteString(".")
+			}
+			sb.WriteString(key)
+		case int:
+			sb.WriteString(fmt.Sprintf("[%d]", key))
+		}
+	}
+	return sb.String()
+}
+
+// validHTTPURL 判断是否为带主机名的 http(s) 地址
+func validHTTPURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
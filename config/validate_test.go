@@ -0,0 +1,332 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+const invalidConfig = `server:
+  port: 8080
+schedule: "every hour"
+repos:
+  - name: "api"
+    url: "https://example.com/api.git"
+    concurrency: "parallel"
+bash_tasks:
+  - name: "backup"
+    command: "echo backup"
+    schedule: "0 2 * * *"
+  - name: "backup"
+    schedul: "0 3 * * *"
+webhooks:
+  - name: "push"
+    path: "/hooks/push"
+    actions:
+      - type: "task"
+        task: "deploy"
+      - type: "notify"
+  - name: "push-copy"
+    path: "/hooks/push"
+    actions:
+      - type: "command"
+`
+
+func TestValidateBytes(t *testing.T) {
+	err := ValidateBytes([]byte(invalidConfig))
+	if err == nil {
+		t.Fatal("预期校验失败，但校验通过")
+	}
+	errs, ok := err.(ValidationErrors)
+	if !ok {
+		t.Fatalf("应该返回 ValidationErrors，实际: %T %v", err, err)
+	}
+
+	expected := []struct {
+		line int
+		text string
+	}{
+		{3, "schedule: 调度表达式无效"},
+		{5, "repos[0]: 至少需要配置一个分支"},
+		{7, "repos[0].concurrency: 未知的并发策略"},
+		{12, "bash_tasks[1].name: Bash任务名称重复"},
+		{12, "bash_tasks[1]: 必须配置 command 或 script_file"},
+		{13, "field schedul not found"},
+		{19, "webhooks[0].actions[0].task: 引用的Bash任务不存在: deploy"},
+		{20, "webhooks[0].actions[1].type: 未知的动作类型"},
+		{22, "webhooks[1].path: Webhook路径重复"},
+		{24, "webhooks[1].actions[0]: command 类型的动作必须配置 command"},
+	}
+
+	for _, want := range expected {
+		found := false
+		for _, e := range errs {
+			if e.Line == want.line && strings.Contains(e.Error(), want.text) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("未找到第%d行的错误 %q，实际错误:\n%v", want.line, want.text, err)
+		}
+	}
+}
+
+func TestValidateBytes_Valid(t *testing.T) {
+	valid := `schedule: "@every 1h"
+bash_tasks:
+  - name: "backup"
+    command: "echo backup"
+    schedule: "0 2 * * *"
+    concurrency: "skip"
+webhooks:
+  - name: "manual"
+    path: "/webhook/manual"
+    actions:
+      - type: "task"
+        task: "backup"
+`
+	if err := ValidateBytes([]byte(valid)); err != nil {
+		t.Errorf("合法配置校验失败: %v", err)
+	}
+
+	if err := ValidateBytes([]byte("repos: [")); err == nil {
+		t.Error("YAML语法错误应该校验失败")
+	}
+}
+
+func TestValidateBytes_Params(t *testing.T) {
+	data := `bash_tasks:
+  - name: "deploy"
+    command: "./deploy.sh"
+    schedule: "0 2 * * *"
+    env:
+      DEPLOY_ENV: "staging"
+      "bad-name": "x"
+    params:
+      - name: "VERSION"
+        required: true
+      - name: "REPLICAS"
+        type: "int"
+        default: abc
+      - name: "TARGET"
+        type: "choice"
+webhooks:
+  - name: "release"
+    path: "/hooks/release"
+    actions:
+      - type: "task"
+        task: "deploy"
+        params:
+          VERSION: "1.2.3"
+          UNKNOWN: "x"
+`
+	err := ValidateBytes([]byte(data))
+	if err == nil {
+		t.Fatal("预期校验失败，但校验通过")
+	}
+	for _, want := range []string{
+		`第6行 bash_tasks[0].env: 环境变量名不合法: "bad-name"`,
+		`第9行 bash_tasks[0].params[0]: 定时调度运行时不传参数，必需参数 VERSION 必须配置 default`,
+		`第13行 bash_tasks[0].params[1].default: 默认值无效`,
+		`第14行 bash_tasks[0].params[2]: choice 类型的参数必须配置 choices`,
+		`第23行 webhooks[0].actions[0].params: 未定义的参数: UNKNOWN`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("未找到错误 %q，实际错误:\n%v", want, err)
+		}
+	}
+}
+
+func TestValidateBytes_Templates(t *testing.T) {
+	data := `bash_tasks:
+  - name: "release"
+    command: "./release.sh"
+    params:
+      - name: "VERSION"
+        required: true
+      - name: "REPLICAS"
+        type: "int"
+webhooks:
+  - name: "release"
+    path: "/hooks/release"
+    actions:
+      - type: "task"
+        task: "release"
+        params:
+          VERSION: "{{ .release.tag_name }}"
+          REPLICAS: "{{ .replicas }}"
+      - type: "command"
+        command: "echo {{ .ref"
+`
+	err := ValidateBytes([]byte(data))
+	if err == nil {
+		t.Fatal("预期校验失败，但校验通过")
+	}
+	errs := err.(ValidationErrors)
+	if len(errs) != 1 {
+		t.Fatalf("含模板的参数值应在运行时校验，只应报告无效模板，实际:\n%v", err)
+	}
+	if want := "第19行 webhooks[0].actions[1].command: 模板无效"; !strings.Contains(errs[0].Error(), want) {
+		t.Errorf("未找到错误 %q，实际错误:\n%v", want, err)
+	}
+}
+
+func TestValidateBytes_PipelineAction(t *testing.T) {
+	data := `repos:
+  - name: "backend"
+    url: "https://github.com/user/backend"
+    branches: ["main"]
+webhooks:
+  - name: "push"
+    path: "/hooks/push"
+    actions:
+      - type: "pipeline"
+      - type: "pipeline"
+        repo: "backend"
+      - type: "pipeline"
+        repo: "frontend"
+        env:
+          KEY: "value"
+      - type: "command"
+        command: "true"
+        repo: "backend"
+`
+	err := ValidateBytes([]byte(data))
+	if err == nil {
+		t.Fatal("预期校验失败，但校验通过")
+	}
+	errs := err.(ValidationErrors)
+	for _, want := range []string{
+		`第13行 webhooks[0].actions[2].repo: 引用的仓库不存在: frontend`,
+		`第15行 webhooks[0].actions[2].env: pipeline 类型的动作不支持 env`,
+		`第18行 webhooks[0].actions[3].repo: 只有 pipeline 类型的动作支持 repo`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("未找到错误 %q，实际错误:\n%v", want, err)
+		}
+	}
+	if len(errs) != 3 {
+		t.Errorf("错误数量不正确，实际错误:\n%v", err)
+	}
+}
+
+func TestValidateBytes_Report(t *testing.T) {
+	data := `server:
+  external_url: "ci.example.com"
+oauth:
+  - name: "gitlab"
+    client_id: "id"
+webhooks:
+  - name: "github"
+    path: "/hooks/github"
+    provider: "github"
+    report:
+      enabled: true
+      api: "checks"
+    actions:
+      - type: "command"
+        command: "true"
+  - name: "gitlab"
+    path: "/hooks/gitlab"
+    provider: "gitlab"
+    report:
+      enabled: true
+      api: "checks"
+    actions:
+      - type: "command"
+        command: "true"
+  - name: "bitbucket"
+    path: "/hooks/bitbucket"
+    provider: "bitbucket"
+    report:
+      enabled: true
+      token: "token"
+    actions:
+      - type: "command"
+        command: "true"
+`
+	err := ValidateBytes([]byte(data))
+	if err == nil {
+		t.Fatal("预期校验失败，但校验通过")
+	}
+	errs := err.(ValidationErrors)
+	for _, want := range []string{
+		`第2行 server.external_url: 无效的地址 "ci.example.com"`,
+		`第11行 webhooks[0].report.token: 未配置 token 且提供商 "github" 未在 oauth 中配置`,
+		`第21行 webhooks[1].report.api: 只有 github 提供商支持选择接口`,
+		`第29行 webhooks[2].report: 提供商 "bitbucket" 不支持回报提交状态`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("未找到错误 %q，实际错误:\n%v", want, err)
+		}
+	}
+	if len(errs) != 4 {
+		t.Errorf("错误数量不正确，实际错误:\n%v", err)
+	}
+}
+
+func TestValidateBytes_LLM(t *testing.T) {
+	data := `llm:
+  provider: "azure"
+  base_url: "https://example.openai.azure.com"
+  temperature: 2.5
+bash_tasks:
+  - name: "test"
+    command: "true"
+    ai:
+      enabled: true
+      max_tokens: -1
+`
+	err := ValidateBytes([]byte(data))
+	if err == nil {
+		t.Fatal("预期校验失败，但校验通过")
+	}
+	errs := err.(ValidationErrors)
+	for _, want := range []string{
+		`第2行 llm: azure 必须配置部署名称 model`,
+		`第4行 llm.temperature: 超出范围 0-2: 2.5`,
+		`第10行 bash_tasks[0].ai.max_tokens: 不能为负数`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("未找到错误 %q，实际错误:\n%v", want, err)
+		}
+	}
+	if len(errs) != 3 {
+		t.Errorf("错误数量不正确，实际错误:\n%v", err)
+	}
+}
+
+func TestValidateBytes_Sandbox(t *testing.T) {
+	data := `bash_tasks:
+  - name: "backup"
+    command: "true"
+    sandbox:
+      enabled: true
+      cpus: -1
+      memory: "lots"
+      writable: ["data"]
+  - name: "image"
+    command: "true"
+    image: "alpine:3.20"
+    sandbox:
+      enabled: true
+`
+	err := ValidateBytes([]byte(data))
+	if err == nil {
+		t.Fatal("预期校验失败，但校验通过")
+	}
+	errs := err.(ValidationErrors)
+	for _, want := range []string{
+		`第6行 bash_tasks[0].sandbox.cpus: 不能为负数`,
+		`第7行 bash_tasks[0].sandbox.memory: 无效的内存大小 "lots"`,
+		`第8行 bash_tasks[0].sandbox.writable[0]: 可写目录必须是绝对路径: "data"`,
+		`第13行 bash_tasks[1].sandbox: 沙箱用于在服务器上执行的任务，不能与 image 同时配置`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("未找到错误 %q，实际错误:\n%v", want, err)
+		}
+	}
+	if len(errs) != 4 {
+		t.Errorf("错误数量不正确，实际错误:\n%v", err)
+	}
+}
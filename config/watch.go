@@ -0,0 +1,53 @@
+package config
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+)
+
+// DefaultWatchInterval 配置文件轮询间隔
+const DefaultWatchInterval = 2 * time.Second
+
+// Watch 轮询配置文件的修改时间和大小，文件变化并稳定一个轮询周期后调用 onChange
+// 文件暂时不存在（编辑器保存时先删除再写入）不会触发回调
+func Watch(ctx context.Context, filename string, interval time.Duration, onChange func()) {
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+
+	last, _ := os.Stat(filename)
+	changed := false
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		info, err := os.Stat(filename)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				log.Printf("⚠️ 检查配置文件失败: %v", err)
+			}
+			continue
+		}
+
+		if last == nil || !info.ModTime().Equal(last.ModTime()) || info.Size() != last.Size() {
+			// 等待下一个周期确认文件已写完
+			last = info
+			changed = true
+			continue
+		}
+
+		if changed {
+			changed = false
+			onChange()
+		}
+	}
+}
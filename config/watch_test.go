@@ -0,0 +1,44 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWatch(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(configFile, []byte("schedule: \"@every 1h\"\n"), 0644)
+
+	var reloads atomic.Int32
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go Watch(ctx, configFile, 20*time.Millisecond, func() {
+		reloads.Add(1)
+	})
+
+	// 文件未变化时不触发
+	time.Sleep(100 * time.Millisecond)
+	if reloads.Load() != 0 {
+		t.Fatalf("文件未变化时不应触发重新加载")
+	}
+
+	os.WriteFile(configFile, []byte("schedule: \"@every 30m\"\n"), 0644)
+	deadline := time.Now().Add(2 * time.Second)
+	for reloads.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if reloads.Load() != 1 {
+		t.Fatalf("文件变化后应该触发1次重新加载，实际触发%d次", reloads.Load())
+	}
+
+	// 文件被删除时不触发
+	os.Remove(configFile)
+	time.Sleep(100 * time.Millisecond)
+	if reloads.Load() != 1 {
+		t.Errorf("文件删除时不应触发重新加载，实际触发%d次", reloads.Load())
+	}
+}
@@ -2,15 +2,20 @@ package core
 
 import (
     "context"
+    "io"
     "lite-cicd/config"
+    "lite-cicd/metrics"
 )
 
 // TaskResult 任务执行结果
 type TaskResult struct {
-    TaskID  string // 任务ID
-    TaskDir string // 任务目录
-    LogFile string // 日志文件路径
-    Error   error  // 执行错误
+    TaskID        string // 任务ID
+    TaskDir       string // 任务目录
+    LogFile       string // 日志文件路径
+    Error         error  // 执行错误
+    ExitCode      int    // 命令（bash命令或测试容器）的退出码，命令未执行或被信号终止时为 -1
+    OOMKilled     bool   // 测试容器是否因内存不足被终止
+    FailureReason string // 失败原因，见 metrics.Failure* 常量，成功或取消时为空
 }
 
 // Executor 定义构建能力的接口，方便扩展非 Docker 环境
@@ -25,7 +30,14 @@ type BashExecutor interface {
 
 // Agent 定义 AI 能力接口
 type Agent interface {
-    AnalyzeLog(logContent string) (string, error)
-    // AnalyzeWithContext 使用自定义上下文和Prompt进行AI分析
-    AnalyzeWithContext(prompt string, context map[string]string) (string, error)
+    AnalyzeLog(ctx context.Context, logPath string) (string, error)
+    // AnalyzeWithContext 使用自定义上下文和Prompt进行AI分析，aiConfig 中的模型参数和上下文token预算覆盖全局配置
+    // 分析结果在生成过程中逐段写入 out；出错时返回已生成的部分结果和错误
+    AnalyzeWithContext(ctx context.Context, prompt string, contextFiles map[string]string, aiConfig config.AIConfig, out io.Writer) (*Analysis, error)
+}
+
+// Analysis AI分析结果
+type Analysis struct {
+    Content  string             // 分析结果（Markdown）
+    Metadata metrics.AIMetadata // 模型、token用量和上下文截断情况，记录到运行元数据
 }
@@ -0,0 +1,123 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// 日志跟随时的轮询间隔
+const followPollInterval = 500 * time.Millisecond
+
+// TailLines 读取文件最后 n 行，同时返回读取结束时的文件偏移量，便于继续跟随
+func TailLines(path string, n int) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, fmt.Errorf("打开日志文件失败: %v", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return "", 0, fmt.Errorf("读取日志文件信息失败: %v", err)
+	}
+	size := info.Size()
+	if n <= 0 || size == 0 {
+		return "", size, nil
+	}
+
+	// 从文件末尾按块向前读取，直到包含足够的换行符
+	const chunkSize = 8192
+	var (
+		buf    []byte
+		offset = size
+	)
+	for offset > 0 {
+		readSize := int64(chunkSize)
+		if offset < readSize {
+			readSize = offset
+		}
+		offset -= readSize
+
+		chunk := make([]byte, readSize)
+		if _, err := f.ReadAt(chunk, offset); err != nil && err != io.EOF {
+			return "", 0, fmt.Errorf("读取日志文件失败: %v", err)
+		}
+		buf = append(chunk, buf...)
+
+		// 末尾换行不计入行数
+		if bytes.Count(bytes.TrimSuffix(buf, []byte("\n")), []byte("\n")) >= n {
+			break
+		}
+	}
+
+	content := bytes.TrimSuffix(buf, []byte("\n"))
+	lines := bytes.Split(content, []byte("\n"))
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return string(bytes.Join(lines, []byte("\n"))), size, nil
+}
+
+// FollowLog 从 offset 开始持续读取日志文件新增的内容并交给 emit，
+// 直到 done 返回 true 且文件内容已全部读完，或 ctx 被取消
+// 日志文件尚未创建时会等待其出现
+func FollowLog(ctx context.Context, path string, offset int64, emit func([]byte) error, done func() bool) error {
+	ticker := time.NewTicker(followPollInterval)
+	defer ticker.Stop()
+
+	var f *os.File
+	defer func() {
+		if f != nil {
+			f.Close()
+		}
+	}()
+
+	buf := make([]byte, 32*1024)
+	for {
+		// 先判断是否结束，再读取剩余内容，避免遗漏结束前最后写入的日志
+		finished := done()
+
+		if f == nil {
+			opened, err := os.Open(path)
+			if err == nil {
+				f = opened
+				if _, err := f.Seek(offset, io.SeekStart); err != nil {
+					return fmt.Errorf("定位日志文件失败: %v", err)
+				}
+			} else if !os.IsNotExist(err) {
+				return fmt.Errorf("打开日志文件失败: %v", err)
+			}
+		}
+
+		if f != nil {
+			for {
+				n, err := f.Read(buf)
+				if n > 0 {
+					if emitErr := emit(buf[:n]); emitErr != nil {
+						return emitErr
+					}
+				}
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					return fmt.Errorf("读取日志文件失败: %v", err)
+				}
+			}
+		}
+
+		if finished {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package core
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTailLines(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "task.log")
+
+	var sb strings.Builder
+	for i := 1; i <= 5000; i++ {
+		sb.WriteString("line ")
+		sb.WriteString(strings.Repeat("x", i%7))
+		sb.WriteString("\n")
+	}
+	sb.WriteString("last line\n")
+	os.WriteFile(logFile, []byte(sb.String()), 0644)
+
+	content, offset, err := TailLines(logFile, 3)
+	if err != nil {
+		t.Fatalf("读取日志失败: %v", err)
+	}
+
+	lines := strings.Split(content, "\n")
+	if len(lines) != 3 {
+		t.Fatalf("应该返回3行，实际返回%d行: %q", len(lines), content)
+	}
+	if lines[2] != "last line" {
+		t.Errorf("最后一行不正确: %q", lines[2])
+	}
+	if offset != int64(sb.Len()) {
+		t.Errorf("偏移量不正确: got %d, want %d", offset, sb.Len())
+	}
+
+	// 行数超过文件总行数时返回全部内容
+	short := filepath.Join(t.TempDir(), "short.log")
+	os.WriteFile(short, []byte("a\nb"), 0644)
+	content, _, _ = TailLines(short, 10)
+	if content != "a\nb" {
+		t.Errorf("短文件内容不正确: %q", content)
+	}
+}
+
+func TestFollowLog(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "task.log")
+
+	var finished atomic.Bool
+	go func() {
+		// 日志文件稍后才创建，并分多次写入
+		time.Sleep(200 * time.Millisecond)
+		f, _ := os.Create(logFile)
+		f.WriteString("step 1\n")
+		time.Sleep(600 * time.Millisecond)
+		f.WriteString("step 2\n")
+		f.Close()
+		finished.Store(true)
+	}()
+
+	var received strings.Builder
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := FollowLog(ctx, logFile, 0, func(data []byte) error {
+		received.Write(data)
+		return nil
+	}, finished.Load)
+	if err != nil {
+		t.Fatalf("跟随日志失败: %v", err)
+	}
+
+	if received.String() != "step 1\nstep 2\n" {
+		t.Errorf("跟随的日志内容不正确: %q", received.String())
+	}
+}
@@ -0,0 +1,86 @@
+package core
+
+import "context"
+
+// RunInfo 单次运行的上下文信息，由调度方生成并通过 context 传递给执行器
+type RunInfo struct {
+	ID      string            // 运行ID，同时作为任务ID和任务目录名
+	Params  map[string]string // 本次运行的参数值，已按参数定义校验并补全默认值
+	Trigger *Trigger          // 触发本次运行的外部事件，手动和定时触发时为 nil
+}
+
+// PayloadFileEnv 指向事件内容文件的环境变量
+const PayloadFileEnv = "SMARTCI_PAYLOAD_FILE"
+
+// Trigger 触发运行的外部事件，如webhook
+type Trigger struct {
+	Event   string            // 事件类型，如 push、pull_request
+	Commit  string            // 事件对应的提交SHA，仓库流水线检出该提交
+	Env     map[string]string // 注入任务的事件环境变量，如 SMARTCI_BRANCH
+	Payload []byte            // 原始事件内容，执行时写入任务目录
+}
+
+type runInfoKey struct{}
+
+// WithRunInfo 将运行信息附加到 context
+func WithRunInfo(ctx context.Context, info *RunInfo) context.Context {
+	return context.WithValue(ctx, runInfoKey{}, info)
+}
+
+// RunInfoFrom 从 context 中读取运行信息，未设置时返回 nil
+func RunInfoFrom(ctx context.Context) *RunInfo {
+	info, _ := ctx.Value(runInfoKey{}).(*RunInfo)
+	return info
+}
+
+// RunID 返回 context 中预先分配的运行ID，未分配时生成新的ID
+func RunID(ctx context.Context) string {
+	if info := RunInfoFrom(ctx); info != nil && info.ID != "" {
+		return info.ID
+	}
+	return GenerateTaskID()
+}
+
+// WithParams 将运行参数附加到 context 中的运行信息，不修改原有的运行信息
+func WithParams(ctx context.Context, params map[string]string) context.Context {
+	var info RunInfo
+	if existing := RunInfoFrom(ctx); existing != nil {
+		info = *existing
+	}
+	info.Params = params
+	return WithRunInfo(ctx, &info)
+}
+
+// WithTrigger 将触发事件附加到 context 中的运行信息，不修改原有的运行信息
+func WithTrigger(ctx context.Context, trigger *Trigger) context.Context {
+	var info RunInfo
+	if existing := RunInfoFrom(ctx); existing != nil {
+		info = *existing
+	}
+	info.Trigger = trigger
+	return WithRunInfo(ctx, &info)
+}
+
+// RunParams 返回 context 中的运行参数，未设置时返回 nil
+func RunParams(ctx context.Context) map[string]string {
+	if info := RunInfoFrom(ctx); info != nil {
+		return info.Params
+	}
+	return nil
+}
+
+// RunTrigger 返回 context 中的触发事件，未设置时返回 nil
+func RunTrigger(ctx context.Context) *Trigger {
+	if info := RunInfoFrom(ctx); info != nil {
+		return info.Trigger
+	}
+	return nil
+}
+
+// RunCommit 返回触发事件指定的提交SHA，未指定时返回空字符串
+func RunCommit(ctx context.Context) string {
+	if trigger := RunTrigger(ctx); trigger != nil {
+		return trigger.Commit
+	}
+	return ""
+}
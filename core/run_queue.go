@@ -0,0 +1,397 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// 任务并发策略
+const (
+	ConcurrencyAllow          = "allow"           // 允许同一任务并行运行（默认）
+	ConcurrencySkip           = "skip"            // 同一任务已在排队或运行时跳过本次触发
+	ConcurrencyQueue          = "queue"           // 排队等待同一任务的上一次运行结束
+	ConcurrencyCancelPrevious = "cancel-previous" // 取消同一任务未完成的运行后再执行
+)
+
+// 运行在队列中的阶段
+const (
+	RunStatePending  = "pending"
+	RunStateRunning  = "running"
+	RunStateFinished = "finished"
+)
+
+// DefaultMaxConcurrency 未配置时的全局最大并发运行数
+const DefaultMaxConcurrency = 4
+
+// 队列保留的已结束运行数量
+const maxFinishedRuns = 50
+
+// ErrRunSkipped 按 skip 策略跳过触发时返回
+var ErrRunSkipped = errors.New("任务已在排队或运行中，本次触发已跳过")
+
+// RunFunc 队列中实际执行的运行函数，ctx 携带运行信息并在取消时结束
+type RunFunc func(ctx context.Context) error
+
+// ValidConcurrencyPolicy 判断并发策略是否合法，空字符串等同于 allow
+func ValidConcurrencyPolicy(policy string) bool {
+	switch policy {
+	case "", ConcurrencyAllow, ConcurrencySkip, ConcurrencyQueue, ConcurrencyCancelPrevious:
+		return true
+	}
+	return false
+}
+
+// QueuedRun 队列中的一次运行
+type QueuedRun struct {
+	ID          string
+	TaskName    string
+	Kind        string // bash 或 repo
+	Policy      string
+	State       string // pending/running/finished
+	Status      string // 结束状态: success/failure/cancelled/skipped
+	Error       string
+	EnqueueTime time.Time
+	StartTime   time.Time
+	EndTime     time.Time
+
+	fn     RunFunc
+	ctx    context.Context
+	cancel context.CancelFunc
+	err    error
+	done   chan struct{}
+}
+
+// Wait 等待运行结束并返回运行函数的错误
+func (r *QueuedRun) Wait() error {
+	<-r.done
+	return r.err
+}
+
+// serial 该运行是否需要与同一任务的其他运行串行执行
+func (r *QueuedRun) serial() bool {
+	return r.Policy == ConcurrencyQueue || r.Policy == ConcurrencyCancelPrevious
+}
+
+// RunSnapshot 运行状态快照，用于状态查询
+type RunSnapshot struct {
+	ID          string `json:"run_id"`
+	TaskName    string `json:"task_name"`
+	Kind        string `json:"kind"`
+	Policy      string `json:"policy"`
+	State       string `json:"state"`
+	Status      string `json:"status,omitempty"`
+	Error       string `json:"error,omitempty"`
+	EnqueueTime string `json:"enqueue_time"`
+	StartTime   string `json:"start_time,omitempty"`
+	EndTime     string `json:"end_time,omitempty"`
+}
+
+// QueueSnapshot 队列状态快照
+type QueueSnapshot struct {
+	MaxConcurrency int           `json:"max_concurrency"`
+	Pending        []RunSnapshot `json:"pending"`
+	Running        []RunSnapshot `json:"running"`
+	Finished       []RunSnapshot `json:"finished"`
+}
+
+// RunQueue 全局运行队列，限制最大并发数并按任务并发策略调度
+type RunQueue struct {
+	mu             sync.Mutex
+	maxConcurrency int
+	pending        []*QueuedRun
+	running        []*QueuedRun
+	finished       []*QueuedRun // 最近结束的运行，按结束时间倒序
+	closed         bool
+}
+
+// NewRunQueue 创建运行队列，maxConcurrency <= 0 时使用默认值
+func NewRunQueue(maxConcurrency int) *RunQueue {
+	if maxConcurrency <= 0 {
+		maxConcurrency = DefaultMaxConcurrency
+	}
+	return &RunQueue{maxConcurrency: maxConcurrency}
+}
+
+// SetMaxConcurrency 调整最大并发数，已在运行的任务不受影响
+func (q *RunQueue) SetMaxConcurrency(maxConcurrency int) {
+	if maxConcurrency <= 0 {
+		maxConcurrency = DefaultMaxConcurrency
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.maxConcurrency = maxConcurrency
+	q.dispatch()
+}
+
+// Submit 提交一次运行，按策略决定排队、跳过或取消同一任务之前的运行
+// 运行ID通过 ctx 中的 RunInfo 传递给执行器
+func (q *RunQueue) Submit(taskName, kind, policy string, fn RunFunc) (*QueuedRun, error) {
+	if policy == "" {
+		policy = ConcurrencyAllow
+	}
+	if !ValidConcurrencyPolicy(policy) {
+		return nil, fmt.Errorf("未知的并发策略: %s", policy)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	run := &QueuedRun{
+		ID:          GenerateTaskID(),
+		TaskName:    taskName,
+		Kind:        kind,
+		Policy:      policy,
+		State:       RunStatePending,
+		EnqueueTime: time.Now(),
+		fn:          fn,
+		cancel:      cancel,
+		done:        make(chan struct{}),
+	}
+	run.ctx = WithRunInfo(ctx, &RunInfo{ID: run.ID})
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		cancel()
+		return nil, fmt.Errorf("运行队列已关闭")
+	}
+
+	switch policy {
+	case ConcurrencySkip:
+		if q.hasActive(taskName) {
+			q.finish(run, "skipped", ErrRunSkipped)
+			return run, ErrRunSkipped
+		}
+	case ConcurrencyCancelPrevious:
+		q.cancelTask(taskName)
+	}
+
+	q.pending = append(q.pending, run)
+	q.dispatch()
+	return run, nil
+}
+
+// Cancel 取消运行，target 可以是运行ID或任务名称（取消该任务所有未结束的运行）
+// 排队中的运行直接移出队列，运行中的运行通过 context 通知执行器终止
+func (q *RunQueue) Cancel(target string) []string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for _, run := range q.pending {
+		if run.ID == target {
+			q.cancelPending(run)
+			return []string{run.ID}
+		}
+	}
+	for _, run := range q.running {
+		if run.ID == target {
+			run.cancel()
+			return []string{run.ID}
+		}
+	}
+	return q.cancelTask(target)
+}
+
+// Running 返回任务当前是否有运行中的实例
+func (q *RunQueue) Running(taskName string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for _, run := range q.running {
+		if run.TaskName == taskName {
+			return true
+		}
+	}
+	return false
+}
+
+// Snapshot 返回队列状态快照，taskName 非空时只包含该任务的运行
+func (q *RunQueue) Snapshot(taskName string) QueueSnapshot {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	snapshot := QueueSnapshot{
+		MaxConcurrency: q.maxConcurrency,
+		Pending:        snapshotRuns(q.pending, taskName),
+		Running:        snapshotRuns(q.running, taskName),
+		Finished:       snapshotRuns(q.finished, taskName),
+	}
+	return snapshot
+}
+
+// Close 关闭队列：拒绝新的提交，丢弃排队中的运行并取消运行中的运行
+func (q *RunQueue) Close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.closed = true
+	for len(q.pending) > 0 {
+		q.cancelPending(q.pending[0])
+	}
+	for _, run := range q.running {
+		run.cancel()
+	}
+}
+
+// hasActive 任务是否有排队或运行中的实例，调用方需持有锁
+func (q *RunQueue) hasActive(taskName string) bool {
+	for _, run := range q.pending {
+		if run.TaskName == taskName {
+			return true
+		}
+	}
+	for _, run := range q.running {
+		if run.TaskName == taskName {
+			return true
+		}
+	}
+	return false
+}
+
+// cancelTask 取消任务所有未结束的运行，调用方需持有锁
+func (q *RunQueue) cancelTask(taskName string) []string {
+	var cancelled []string
+
+	var remaining []*QueuedRun
+	for _, run := range q.pending {
+		if run.TaskName == taskName {
+			cancelled = append(cancelled, run.ID)
+			run.cancel()
+			q.finish(run, "cancelled", context.Canceled)
+			continue
+		}
+		remaining = append(remaining, run)
+	}
+	q.pending = remaining
+
+	for _, run := range q.running {
+		if run.TaskName == taskName {
+			run.cancel()
+			cancelled = append(cancelled, run.ID)
+		}
+	}
+	return cancelled
+}
+
+// cancelPending 将排队中的运行移出队列并标记为已取消，调用方需持有锁
+func (q *RunQueue) cancelPending(target *QueuedRun) {
+	for i, run := range q.pending {
+		if run == target {
+			q.pending = append(q.pending[:i], q.pending[i+1:]...)
+			break
+		}
+	}
+	target.cancel()
+	q.finish(target, "cancelled", context.Canceled)
+}
+
+// dispatch 按提交顺序启动可以运行的任务，调用方需持有锁
+// 串行策略的运行在同一任务仍有实例运行时继续等待，不阻塞后面其他任务的运行
+func (q *RunQueue) dispatch() {
+	var waiting []*QueuedRun
+	for i, run := range q.pending {
+		if len(q.running) >= q.maxConcurrency {
+			waiting = append(waiting, q.pending[i:]...)
+			break
+		}
+		if run.serial() && q.taskRunning(run.TaskName) {
+			waiting = append(waiting, run)
+			continue
+		}
+		q.start(run)
+	}
+	q.pending = waiting
+}
+
+// taskRunning 任务是否有运行中的实例，调用方需持有锁
+func (q *RunQueue) taskRunning(taskName string) bool {
+	for _, run := range q.running {
+		if run.TaskName == taskName {
+			return true
+		}
+	}
+	return false
+}
+
+// start 启动运行，调用方需持有锁
+func (q *RunQueue) start(run *QueuedRun) {
+	run.State = RunStateRunning
+	run.StartTime = time.Now()
+	q.running = append(q.running, run)
+
+	go func() {
+		err := run.fn(run.ctx)
+
+		status := "success"
+		if err != nil {
+			status = "failure"
+			if errors.Is(run.ctx.Err(), context.Canceled) {
+				status = "cancelled"
+			}
+		}
+		run.cancel()
+
+		q.mu.Lock()
+		defer q.mu.Unlock()
+
+		for i, r := range q.running {
+			if r == run {
+				q.running = append(q.running[:i], q.running[i+1:]...)
+				break
+			}
+		}
+		q.finish(run, status, err)
+		q.dispatch()
+	}()
+}
+
+// finish 记录运行结束，调用方需持有锁
+func (q *RunQueue) finish(run *QueuedRun, status string, err error) {
+	run.State = RunStateFinished
+	run.Status = status
+	run.EndTime = time.Now()
+	run.err = err
+	if err != nil {
+		run.Error = err.Error()
+	}
+	close(run.done)
+
+	q.finished = append([]*QueuedRun{run}, q.finished...)
+	if len(q.finished) > maxFinishedRuns {
+		q.finished = q.finished[:maxFinishedRuns]
+	}
+}
+
+// snapshotRuns 生成运行列表的快照，taskName 非空时按任务过滤
+func snapshotRuns(runs []*QueuedRun, taskName string) []RunSnapshot {
+	snapshots := make([]RunSnapshot, 0, len(runs))
+	for _, run := range runs {
+		if taskName != "" && run.TaskName != taskName {
+			continue
+		}
+		snapshots = append(snapshots, RunSnapshot{
+			ID:          run.ID,
+			TaskName:    run.TaskName,
+			Kind:        run.Kind,
+			Policy:      run.Policy,
+			State:       run.State,
+			Status:      run.Status,
+			Error:       run.Error,
+			EnqueueTime: formatRunTime(run.EnqueueTime),
+			StartTime:   formatRunTime(run.StartTime),
+			EndTime:     formatRunTime(run.EndTime),
+		})
+	}
+	return snapshots
+}
+
+// formatRunTime 格式化时间，零值返回空字符串
+func formatRunTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format("2006-01-02 15:04:05")
+}
@@ -0,0 +1,201 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// blockingRun 返回一个在 release 关闭或 ctx 取消前一直阻塞的运行函数
+func blockingRun(release <-chan struct{}, active, peak *int32) RunFunc {
+	return func(ctx context.Context) error {
+		n := atomic.AddInt32(active, 1)
+		defer atomic.AddInt32(active, -1)
+		for {
+			old := atomic.LoadInt32(peak)
+			if n <= old || atomic.CompareAndSwapInt32(peak, old, n) {
+				break
+			}
+		}
+
+		select {
+		case <-release:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// waitFor 轮询等待条件成立
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("等待条件超时")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRunQueue_MaxConcurrency(t *testing.T) {
+	queue := NewRunQueue(2)
+	release := make(chan struct{})
+	var active, peak int32
+
+	var runs []*QueuedRun
+	for i := 0; i < 5; i++ {
+		run, err := queue.Submit("build", "bash", ConcurrencyAllow, blockingRun(release, &active, &peak))
+		if err != nil {
+			t.Fatalf("提交运行失败: %v", err)
+		}
+		runs = append(runs, run)
+	}
+
+	snapshot := queue.Snapshot("")
+	if len(snapshot.Running) != 2 || len(snapshot.Pending) != 3 {
+		t.Fatalf("应该有2个运行中、3个排队中，实际 %d/%d", len(snapshot.Running), len(snapshot.Pending))
+	}
+
+	close(release)
+	for _, run := range runs {
+		if err := run.Wait(); err != nil {
+			t.Errorf("运行失败: %v", err)
+		}
+	}
+
+	if peak > 2 {
+		t.Errorf("并发数超过限制: %d", peak)
+	}
+	snapshot = queue.Snapshot("build")
+	if len(snapshot.Finished) != 5 || snapshot.Finished[0].Status != "success" {
+		t.Errorf("已结束的运行记录不正确: %+v", snapshot.Finished)
+	}
+}
+
+func TestRunQueue_Policies(t *testing.T) {
+	t.Run("skip", func(t *testing.T) {
+		queue := NewRunQueue(4)
+		release := make(chan struct{})
+		defer close(release)
+		var active, peak int32
+
+		if _, err := queue.Submit("sync", "bash", ConcurrencySkip, blockingRun(release, &active, &peak)); err != nil {
+			t.Fatalf("提交运行失败: %v", err)
+		}
+		run, err := queue.Submit("sync", "bash", ConcurrencySkip, blockingRun(release, &active, &peak))
+		if !errors.Is(err, ErrRunSkipped) {
+			t.Fatalf("第二次触发应该被跳过，实际错误: %v", err)
+		}
+		if run.Status != "skipped" {
+			t.Errorf("跳过的运行状态不正确: %s", run.Status)
+		}
+	})
+
+	t.Run("queue", func(t *testing.T) {
+		queue := NewRunQueue(4)
+		var active, peak int32
+		release := make(chan struct{})
+
+		first, _ := queue.Submit("deploy", "repo", ConcurrencyQueue, blockingRun(release, &active, &peak))
+		second, _ := queue.Submit("deploy", "repo", ConcurrencyQueue, blockingRun(release, &active, &peak))
+		// 其他任务不受串行策略影响
+		other, _ := queue.Submit("lint", "repo", ConcurrencyQueue, blockingRun(release, &active, &peak))
+
+		waitFor(t, func() bool { return atomic.LoadInt32(&active) == 2 })
+		if pending := queue.Snapshot("deploy").Pending; len(pending) != 1 || pending[0].ID != second.ID {
+			t.Errorf("同一任务的第二次运行应该排队: %+v", pending)
+		}
+
+		close(release)
+		for _, run := range []*QueuedRun{first, second, other} {
+			run.Wait()
+		}
+		if peak != 2 {
+			t.Errorf("同一任务不应并行运行，峰值并发: %d", peak)
+		}
+		if !second.StartTime.After(first.EndTime) && !second.StartTime.Equal(first.EndTime) {
+			t.Errorf("第二次运行应该在第一次结束后开始")
+		}
+	})
+
+	t.Run("cancel-previous", func(t *testing.T) {
+		queue := NewRunQueue(4)
+		release := make(chan struct{})
+		var active, peak int32
+
+		first, _ := queue.Submit("preview", "repo", ConcurrencyCancelPrevious, blockingRun(release, &active, &peak))
+		waitFor(t, func() bool { return atomic.LoadInt32(&active) == 1 })
+
+		second, _ := queue.Submit("preview", "repo", ConcurrencyCancelPrevious, blockingRun(release, &active, &peak))
+		if err := first.Wait(); !errors.Is(err, context.Canceled) {
+			t.Errorf("之前的运行应该被取消，实际错误: %v", err)
+		}
+		if first.Status != "cancelled" {
+			t.Errorf("之前的运行状态不正确: %s", first.Status)
+		}
+
+		close(release)
+		if err := second.Wait(); err != nil {
+			t.Errorf("新的运行失败: %v", err)
+		}
+	})
+}
+
+func TestRunQueue_Cancel(t *testing.T) {
+	queue := NewRunQueue(1)
+	release := make(chan struct{})
+	defer close(release)
+	var active, peak int32
+
+	running, _ := queue.Submit("a", "bash", ConcurrencyAllow, blockingRun(release, &active, &peak))
+	pending, _ := queue.Submit("b", "bash", ConcurrencyAllow, blockingRun(release, &active, &peak))
+
+	// 取消排队中的运行不会执行运行函数
+	if ids := queue.Cancel(pending.ID); len(ids) != 1 {
+		t.Fatalf("应该取消1个运行，实际取消%d个", len(ids))
+	}
+	if err := pending.Wait(); !errors.Is(err, context.Canceled) || !pending.StartTime.IsZero() {
+		t.Errorf("排队中的运行应该直接取消: err=%v", err)
+	}
+
+	// 按任务名称取消运行中的运行
+	if ids := queue.Cancel("a"); len(ids) != 1 || ids[0] != running.ID {
+		t.Fatalf("按任务名称取消失败: %v", ids)
+	}
+	running.Wait()
+	if running.Status != "cancelled" {
+		t.Errorf("运行状态应该为 cancelled，实际为: %s", running.Status)
+	}
+
+	if ids := queue.Cancel("missing"); len(ids) != 0 {
+		t.Errorf("不存在的运行不应被取消: %v", ids)
+	}
+}
+
+func TestRunQueue_RunInfo(t *testing.T) {
+	queue := NewRunQueue(1)
+
+	var mu sync.Mutex
+	var gotID string
+	run, _ := queue.Submit("info", "bash", "", func(ctx context.Context) error {
+		mu.Lock()
+		defer mu.Unlock()
+		gotID = RunID(ctx)
+		return nil
+	})
+	run.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if gotID != run.ID {
+		t.Errorf("执行器应该收到队列分配的运行ID: got %s, want %s", gotID, run.ID)
+	}
+	if run.Policy != ConcurrencyAllow {
+		t.Errorf("默认并发策略应该为 allow，实际为: %s", run.Policy)
+	}
+}
@@ -1,6 +1,7 @@
 package core
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/hex"
 	"fmt"
@@ -11,6 +12,7 @@ import (
 	"time"
 
 	"lite-cicd/config"
+	"lite-cicd/secrets"
 )
 
 // GenerateTaskID 生成唯一的任务ID
@@ -32,7 +34,7 @@ func CreateTaskDir(baseDir, taskID string) (string, error) {
 	return taskDir, nil
 }
 
-// CollectContext 收集任务上下文
+// CollectContext 收集任务上下文，内容中的密钥值会被脱敏后再提供给AI
 // contextConfig: 上下文配置列表（预定义类型或路径通配符）
 // taskDir: 任务目录
 // logFile: 日志文件路径
@@ -58,6 +60,9 @@ func CollectContext(contextConfig []string, taskDir, logFile string) (map[string
 		}
 	}
 
+	for key, content := range context {
+		context[key] = secrets.Mask(content)
+	}
 	return context, nil
 }
 
@@ -170,16 +175,17 @@ func collectRecursivePattern(pattern, taskDir string, context map[string]string)
 	return err
 }
 
-// InvokeAI 调用AI分析
-func InvokeAI(agent Agent, aiConfig config.AIConfig, taskDir string, result *TaskResult) error {
+// InvokeAI 调用AI分析，返回分析结果（含写入运行元数据的模型、token用量和上下文截断情况）
+// 分析结果边生成边写入输出文件，分析中断时保留已生成的部分并返回部分结果和错误；ctx 取消时中断分析
+func InvokeAI(ctx context.Context, agent Agent, aiConfig config.AIConfig, taskDir string, result *TaskResult) (*Analysis, error) {
 	if !aiConfig.Enabled {
-		return nil
+		return nil, nil
 	}
 
 	// 收集上下文
-	context, err := CollectContext(aiConfig.Context, taskDir, result.LogFile)
+	contextFiles, err := CollectContext(aiConfig.Context, taskDir, result.LogFile)
 	if err != nil {
-		return fmt.Errorf("收集上下文失败: %v", err)
+		return nil, fmt.Errorf("收集上下文失败: %v", err)
 	}
 
 	// 使用默认Prompt如果未配置
@@ -188,28 +194,38 @@ func InvokeAI(agent Agent, aiConfig config.AIConfig, taskDir string, result *Tas
 		prompt = "请分析以下内容，找出问题并给出建议："
 	}
 
-	// 调用AI分析（实现留空）
-	analysis, err := agent.AnalyzeWithContext(prompt, context)
+	outputFile := AnalysisFile(aiConfig, taskDir)
+	file, err := os.Create(outputFile)
 	if err != nil {
-		return fmt.Errorf("AI分析失败: %v", err)
+		return nil, fmt.Errorf("写入AI分析结果失败: %v", err)
 	}
+	defer file.Close()
 
-	// 确定输出文件路径
+	// 调用AI分析，上下文按token预算截断
+	analysis, err := agent.AnalyzeWithContext(ctx, prompt, contextFiles, aiConfig, file)
+	if err != nil {
+		if analysis == nil || analysis.Content == "" {
+			file.Close()
+			os.Remove(outputFile)
+			return analysis, fmt.Errorf("AI分析失败: %v", err)
+		}
+		fmt.Fprintf(file, "\n\n> ⚠️ AI分析未完成，以上为中断前生成的部分内容: %v\n", err)
+		analysis.Metadata.OutputFile = outputFile
+		return analysis, fmt.Errorf("AI分析中断: %v", err)
+	}
+	analysis.Metadata.OutputFile = outputFile
+
+	return analysis, nil
+}
+
+// AnalysisFile 返回AI分析结果的输出文件路径，未配置时为任务目录下的 ai-analysis.md，相对路径相对于任务目录
+func AnalysisFile(aiConfig config.AIConfig, taskDir string) string {
 	outputFile := aiConfig.OutputFile
 	if outputFile == "" {
 		outputFile = "ai-analysis.md"
 	}
-	
-	// 如果是相对路径，则相对于任务目录
 	if !filepath.IsAbs(outputFile) {
 		outputFile = filepath.Join(taskDir, outputFile)
 	}
-
-	// 写入分析结果
-	err = ioutil.WriteFile(outputFile, []byte(analysis), 0644)
-	if err != nil {
-		return fmt.Errorf("写入AI分析结果失败: %v", err)
-	}
-
-	return nil
+	return outputFile
 }
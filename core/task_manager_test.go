@@ -1,12 +1,16 @@
 package core
 
 import (
+	"context"
+	"errors"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
 
 	"lite-cicd/config"
+	"lite-cicd/secrets"
 )
 
 func TestGenerateTaskID(t *testing.T) {
@@ -150,6 +154,28 @@ func TestCollectContext_RecursiveWildcard(t *testing.T) {
 	}
 }
 
+func TestCollectContext_MaskSecrets(t *testing.T) {
+	taskDir := t.TempDir()
+	logFile := filepath.Join(taskDir, "task.log")
+	os.WriteFile(logFile, []byte("curl -H 'Authorization: token ghp-collect-0001'"), 0644)
+	os.WriteFile(filepath.Join(taskDir, "env.txt"), []byte("TOKEN=ghp-collect-0001"), 0644)
+
+	secrets.Register("ghp-collect-0001")
+
+	context, err := CollectContext([]string{"log", "*.txt"}, taskDir, logFile)
+	if err != nil {
+		t.Fatalf("收集上下文失败: %v", err)
+	}
+	for key, content := range context {
+		if strings.Contains(content, "ghp-collect-0001") {
+			t.Errorf("上下文 %s 中的密钥未脱敏: %s", key, content)
+		}
+	}
+	if context["env.txt"] != "TOKEN=***" {
+		t.Errorf("脱敏结果不正确: %q", context["env.txt"])
+	}
+}
+
 func TestInvokeAI(t *testing.T) {
 	// 创建临时任务目录
 	baseDir := "/tmp/test-smartci"
@@ -166,10 +192,11 @@ func TestInvokeAI(t *testing.T) {
 
 	// 创建AI配置
 	aiConfig := config.AIConfig{
-		Enabled:    true,
-		Context:    []string{"log"},
-		Prompt:     "分析日志",
-		OutputFile: "test-output.md",
+		Enabled:          true,
+		Context:          []string{"log"},
+		Prompt:           "分析日志",
+		OutputFile:       "test-output.md",
+		MaxContextTokens: 2000,
 	}
 
 	// 创建任务结果
@@ -183,7 +210,7 @@ func TestInvokeAI(t *testing.T) {
 	mockAgent := &MockAgent{}
 
 	// 调用AI
-	err := InvokeAI(mockAgent, aiConfig, taskDir, result)
+	analysis, err := InvokeAI(context.Background(), mockAgent, aiConfig, taskDir, result)
 	if err != nil {
 		t.Fatalf("调用AI失败: %v", err)
 	}
@@ -193,15 +220,66 @@ func TestInvokeAI(t *testing.T) {
 	if _, err := os.Stat(outputFile); os.IsNotExist(err) {
 		t.Errorf("输出文件未创建: %s", outputFile)
 	}
+	if analysis.Metadata.OutputFile != outputFile {
+		t.Errorf("元数据中的输出文件不正确: %s", analysis.Metadata.OutputFile)
+	}
+	if mockAgent.aiConfig.MaxContextTokens != 2000 {
+		t.Errorf("未传递AI配置: %+v", mockAgent.aiConfig)
+	}
+}
+
+func TestInvokeAI_Interrupted(t *testing.T) {
+	taskDir := t.TempDir()
+	aiConfig := config.AIConfig{Enabled: true}
+	result := &TaskResult{TaskID: "test-interrupted", TaskDir: taskDir}
+
+	t.Run("保留已生成的部分", func(t *testing.T) {
+		agent := &partialAgent{written: "## 根因\n\n依赖", err: errors.New("请求超时（120秒）")}
+		analysis, err := InvokeAI(context.Background(), agent, aiConfig, taskDir, result)
+		if err == nil || analysis == nil {
+			t.Fatalf("应返回部分结果和错误: %v", err)
+		}
+		data, _ := os.ReadFile(AnalysisFile(aiConfig, taskDir))
+		if !strings.HasPrefix(string(data), "## 根因\n\n依赖") || !strings.Contains(string(data), "AI分析未完成") {
+			t.Errorf("输出文件内容不正确: %q", data)
+		}
+	})
+
+	t.Run("没有输出时不保留文件", func(t *testing.T) {
+		agent := &partialAgent{err: errors.New("429 Too Many Requests")}
+		if _, err := InvokeAI(context.Background(), agent, aiConfig, taskDir, result); err == nil {
+			t.Fatal("应返回错误")
+		}
+		if _, err := os.Stat(AnalysisFile(aiConfig, taskDir)); !os.IsNotExist(err) {
+			t.Error("没有输出时不应保留输出文件")
+		}
+	})
+}
+
+// partialAgent 写入部分内容后返回错误，模拟流式输出中断
+type partialAgent struct {
+	MockAgent
+	written string
+	err     error
+}
+
+func (p *partialAgent) AnalyzeWithContext(ctx context.Context, prompt string, contextFiles map[string]string, aiConfig config.AIConfig, out io.Writer) (*Analysis, error) {
+	io.WriteString(out, p.written)
+	return &Analysis{Content: p.written}, p.err
 }
 
 // MockAgent 用于测试的mock agent
-type MockAgent struct{}
+type MockAgent struct {
+	aiConfig config.AIConfig
+}
 
-func (m *MockAgent) AnalyzeLog(logContent string) (string, error) {
+func (m *MockAgent) AnalyzeLog(ctx context.Context, logPath string) (string, error) {
 	return "Mock analysis", nil
 }
 
-func (m *MockAgent) AnalyzeWithContext(prompt string, context map[string]string) (string, error) {
-	return "# Mock AI Analysis\n\nTest result", nil
+func (m *MockAgent) AnalyzeWithContext(ctx context.Context, prompt string, contextFiles map[string]string, aiConfig config.AIConfig, out io.Writer) (*Analysis, error) {
+	m.aiConfig = aiConfig
+	content := "# Mock AI Analysis\n\nTest result"
+	io.WriteString(out, content)
+	return &Analysis{Content: content}, nil
 }
@@ -2,30 +2,49 @@ package executor
 
 import (
     "context"
+    "errors"
     "fmt"
     "io"
     "lite-cicd/config"
     "lite-cicd/core"
     "lite-cicd/metrics"
+    "lite-cicd/secrets"
     "log"
     "os"
     "os/exec"
     "path/filepath"
+    "sort"
     "strings"
     "time"
 )
 
+// 容器中执行时事件内容文件的挂载路径
+const containerPayloadFile = "/smart-ci/payload.json"
+
 type BashExecutor struct {
-    logDir string
+    logDir  string
+    store   metrics.RunStore
+    secrets *secrets.Manager
+    docker  *DockerExecutor // 配置了 image 的任务在容器中执行
+}
+
+func NewBashExecutor(logDir string, store metrics.RunStore) (*BashExecutor, error) {
+    return &BashExecutor{logDir: logDir, store: store}, nil
+}
+
+// SetSecrets 设置密钥管理器，任务配置的 secrets 从中读取并注入环境变量
+func (e *BashExecutor) SetSecrets(manager *secrets.Manager) {
+    e.secrets = manager
 }
 
-func NewBashExecutor(logDir string) (*BashExecutor, error) {
-    return &BashExecutor{logDir: logDir}, nil
+// SetDocker 设置Docker执行器，用于在容器中执行配置了 image 的任务
+func (e *BashExecutor) SetDocker(docker *DockerExecutor) {
+    e.docker = docker
 }
 
 func (e *BashExecutor) RunBashTask(ctx context.Context, task config.BashTaskConfig) (*core.TaskResult, error) {
     // 生成任务ID
-    taskID := core.GenerateTaskID()
+    taskID := core.RunID(ctx)
     
     // 创建任务目录
     taskDir, err := core.CreateTaskDir(e.logDir, taskID)
@@ -37,9 +56,10 @@ func (e *BashExecutor) RunBashTask(ctx context.Context, task config.BashTaskConf
     logFile := filepath.Join(taskDir, "task.log")
     
     result := &core.TaskResult{
-        TaskID:  taskID,
-        TaskDir: taskDir,
-        LogFile: logFile,
+        TaskID:   taskID,
+        TaskDir:  taskDir,
+        LogFile:  logFile,
+        ExitCode: -1,
     }
     
     // 创建元数据记录
@@ -48,18 +68,26 @@ func (e *BashExecutor) RunBashTask(ctx context.Context, task config.BashTaskConf
         TaskName:  task.Name,
         TaskType:  "bash",
         StartTime: time.Now(),
+        Status:    "running",
         LogFile:   logFile,
         TaskDir:   taskDir,
         Config: map[string]interface{}{
             "command":     task.Command,
             "script_file": task.ScriptFile,
             "working_dir": task.WorkingDir,
+            "image":       task.Image,
+            "sandbox":     task.Sandbox.Enabled,
             "timeout":     task.Timeout,
+            "secrets":     task.Secrets,
         },
+        Params: core.RunParams(ctx),
     }
     
     log.Printf("🔧 [Bash] 任务ID: %s", taskID)
     log.Printf("📁 [Bash] 任务目录: %s", taskDir)
+
+    // 记录运行中状态，便于日志跟随和状态查询
+    e.store.Save(metadata)
     
     // 确定要执行的命令
     var command string
@@ -73,7 +101,9 @@ func (e *BashExecutor) RunBashTask(ctx context.Context, task config.BashTaskConf
             metadata.Duration = metadata.EndTime.Sub(metadata.StartTime).Seconds()
             metadata.Status = "failure"
             metadata.Error = result.Error.Error()
-            metrics.SaveMetadata(metadata)
+            result.FailureReason = metrics.FailureInfra
+            metadata.FailureReason = result.FailureReason
+            e.store.Save(metadata)
             return result, result.Error
         }
     } else if task.Command != "" {
@@ -85,7 +115,35 @@ func (e *BashExecutor) RunBashTask(ctx context.Context, task config.BashTaskConf
         metadata.Duration = metadata.EndTime.Sub(metadata.StartTime).Seconds()
         metadata.Status = "failure"
         metadata.Error = result.Error.Error()
-        metrics.SaveMetadata(metadata)
+        result.FailureReason = metrics.FailureInfra
+        metadata.FailureReason = result.FailureReason
+        e.store.Save(metadata)
+        return result, result.Error
+    }
+
+    // 依次注入任务环境变量、事件变量、运行参数和任务声明的密钥
+    // 在服务器上执行时先继承服务器环境变量（不含密钥相关变量），在容器中执行时不继承
+    vars := runVars(ctx, task.Env)
+    settings := task.Container
+    if payloadFile, err := writePayload(ctx, taskDir); err != nil {
+        log.Printf("⚠️ [Bash] 保存事件内容失败: %v", err)
+    } else {
+        settings = payloadSettings(task, payloadFile, vars)
+    }
+    var base []string
+    if task.Image == "" {
+        base = os.Environ()
+    }
+    env, err := taskEnv(e.secrets, base, vars, task.Secrets)
+    if err != nil {
+        result.Error = err
+        metadata.EndTime = time.Now()
+        metadata.Duration = metadata.EndTime.Sub(metadata.StartTime).Seconds()
+        metadata.Status = "failure"
+        metadata.Error = result.Error.Error()
+        result.FailureReason = metrics.FailureInfra
+        metadata.FailureReason = result.FailureReason
+        e.store.Save(metadata)
         return result, result.Error
     }
 
@@ -109,23 +167,32 @@ func (e *BashExecutor) RunBashTask(ctx context.Context, task config.BashTaskConf
     if task.WorkingDir != "" {
         log.Printf("📁 [Bash] 工作目录: %s", task.WorkingDir)
     }
+    if task.Image != "" {
+        log.Printf("🐳 [Bash] 镜像: %s", task.Image)
+    }
+    if task.Sandbox.Enabled {
+        log.Printf("🔒 [Bash] 在沙箱中执行")
+    }
 
-    err = e.runBashCommand(ctx, command, task.WorkingDir, logFile)
+    err = e.runBashCommand(ctx, task, command, taskDir, env, settings, logFile)
     
     // 更新元数据
     metadata.EndTime = time.Now()
     metadata.Duration = metadata.EndTime.Sub(metadata.StartTime).Seconds()
+    ran := recordExit(result, metadata, err)
     
     if err != nil {
         result.Error = fmt.Errorf("bash任务执行失败: %v", err)
-        metadata.Status = "failure"
+        metadata.Status = runStatus(ctx, err)
         metadata.Error = result.Error.Error()
-        metrics.SaveMetadata(metadata)
+        result.FailureReason = failureReason(ctx, err, commandFailure(ran))
+        metadata.FailureReason = result.FailureReason
+        e.store.Save(metadata)
         return result, result.Error
     }
 
     metadata.Status = "success"
-    metrics.SaveMetadata(metadata)
+    e.store.Save(metadata)
     
     log.Printf("✅ [Bash] 任务完成: %s", task.Name)
     return result, nil
@@ -146,7 +213,7 @@ func (e *BashExecutor) readScriptFile(scriptFile string) (string, error) {
     return string(content), nil
 }
 
-func (e *BashExecutor) runBashCommand(ctx context.Context, command, workingDir, logFile string) error {
+func (e *BashExecutor) runBashCommand(ctx context.Context, task config.BashTaskConfig, command, taskDir string, env []string, settings config.ContainerConfig, logFile string) error {
     // 创建日志文件
     logF, err := os.Create(logFile)
     if err != nil {
@@ -154,49 +221,202 @@ func (e *BashExecutor) runBashCommand(ctx context.Context, command, workingDir,
     }
     defer logF.Close()
 
-    // 创建bash命令
-    cmd := exec.CommandContext(ctx, "bash", "-c", command)
+    // 执行命令，输出经脱敏后写入日志文件
+    out := secrets.NewMaskingWriter(logF)
+    err = e.execCommand(ctx, task, command, taskDir, env, settings, out)
+    out.Flush()
     
-    // 设置工作目录
-    if workingDir != "" {
-        cmd.Dir = workingDir
+    // 写入执行结果
+    if errors.Is(ctx.Err(), context.Canceled) {
+        logF.WriteString("\n\n=== 任务已取消 ===\n")
+    } else if err != nil {
+        logF.WriteString(fmt.Sprintf("\n\n=== 命令执行失败 ===\n错误: %v\n", err))
+    } else {
+        logF.WriteString("\n\n=== 命令执行成功 ===\n退出码: 0\n")
     }
 
-    // 设置环境变量
-    cmd.Env = os.Environ()
+    return err
+}
 
-    // 创建管道来捕获输出
-    stdoutPipe, err := cmd.StdoutPipe()
-    if err != nil {
-        return fmt.Errorf("创建stdout管道失败: %v", err)
+// execCommand 执行任务命令：配置了 image 时在容器中执行，工作目录（未配置时为任务目录）挂载到 /workspace
+// 启用沙箱时在服务器的沙箱中执行，工作目录未配置时同样为任务目录；否则直接在服务器上执行
+func (e *BashExecutor) execCommand(ctx context.Context, task config.BashTaskConfig, command, taskDir string, env []string, settings config.ContainerConfig, out io.Writer) error {
+    workDir := task.WorkingDir
+    if workDir == "" {
+        workDir = taskDir
+    }
+    if task.Image == "" && task.Sandbox.Enabled {
+        return runSandboxCommand(ctx, core.RunID(ctx), command, workDir, env, task.Sandbox, out)
+    }
+    if task.Image == "" {
+        return runShellCommand(ctx, command, task.WorkingDir, env, out)
+    }
+    if e.docker == nil {
+        return fmt.Errorf("Docker执行器不可用，无法在镜像 %s 中执行", task.Image)
+    }
+    return e.docker.runStepContainer(ctx, task.Image, containerBashCommand(command), workDir, env, settings, out)
+}
+
+// containerBashCommand 生成在容器中执行命令的参数：镜像中有 bash 时使用 bash，否则使用 sh
+func containerBashCommand(command string) []string {
+    return []string{"sh", "-c", `if command -v bash >/dev/null 2>&1; then exec bash -c "$1"; else exec sh -c "$1"; fi`, "sh", command}
+}
+
+// runVars 依次合并任务配置的环境变量、触发事件的环境变量和本次运行的参数，同名时后者优先
+func runVars(ctx context.Context, env map[string]string) map[string]string {
+    params := core.RunParams(ctx)
+    vars := make(map[string]string, len(env)+len(params))
+    for name, value := range env {
+        vars[name] = value
+    }
+    if trigger := core.RunTrigger(ctx); trigger != nil {
+        for name, value := range trigger.Env {
+            vars[name] = value
+        }
+    }
+    for name, value := range params {
+        vars[name] = value
     }
-    stderrPipe, err := cmd.StderrPipe()
+    return vars
+}
+
+// writePayload 将触发事件的原始内容写入任务目录的 payload.json，返回文件的绝对路径
+// 没有事件内容时返回空字符串
+func writePayload(ctx context.Context, taskDir string) (string, error) {
+    trigger := core.RunTrigger(ctx)
+    if trigger == nil || len(trigger.Payload) == 0 {
+        return "", nil
+    }
+    payloadFile, err := filepath.Abs(filepath.Join(taskDir, "payload.json"))
     if err != nil {
-        return fmt.Errorf("创建stderr管道失败: %v", err)
+        return "", err
     }
+    if err := os.WriteFile(payloadFile, trigger.Payload, 0600); err != nil {
+        return "", err
+    }
+    return payloadFile, nil
+}
 
-    // 启动命令
-    if err := cmd.Start(); err != nil {
-        return fmt.Errorf("启动命令失败: %v", err)
+// payloadSettings 将事件内容文件的路径写入 vars，返回任务使用的容器配置
+// 在容器中执行时事件内容文件以只读方式挂载到容器中，没有事件内容时原样返回任务的容器配置
+func payloadSettings(task config.BashTaskConfig, payloadFile string, vars map[string]string) config.ContainerConfig {
+    settings := task.Container
+    switch {
+    case payloadFile == "":
+    case task.Image != "":
+        settings.Volumes = append(append([]string(nil), settings.Volumes...), payloadFile+":"+containerPayloadFile+":ro")
+        vars[core.PayloadFileEnv] = containerPayloadFile
+    default:
+        vars[core.PayloadFileEnv] = payloadFile
     }
+    return settings
+}
 
-    // 实时写入日志
-    go func() {
-        io.Copy(logF, stdoutPipe)
-    }()
-    go func() {
-        io.Copy(logF, stderrPipe)
-    }()
+// taskEnv 生成任务环境变量：base 中去掉密钥相关变量后，按名称顺序追加 vars，最后追加任务声明的密钥
+// 同名变量以后出现的为准，因此密钥不会被任务环境变量或参数覆盖
+// 未设置密钥管理器时 base 不做过滤，声明了密钥则报错
+func taskEnv(manager *secrets.Manager, base []string, vars map[string]string, names []string) ([]string, error) {
+    env := append([]string(nil), base...)
+    if manager != nil {
+        env = manager.FilterEnv(base)
+    }
 
-    // 等待命令完成
-    err = cmd.Wait()
-    
-    // 写入执行结果
+    keys := make([]string, 0, len(vars))
+    for name := range vars {
+        keys = append(keys, name)
+    }
+    sort.Strings(keys)
+    for _, name := range keys {
+        env = append(env, name+"="+vars[name])
+    }
+
+    if len(names) == 0 {
+        return env, nil
+    }
+    if manager == nil {
+        return nil, fmt.Errorf("未配置密钥管理器，无法注入密钥: %s", strings.Join(names, ", "))
+    }
+    secretEnv, err := manager.Env(nil, names)
     if err != nil {
-        logF.WriteString(fmt.Sprintf("\n\n=== 命令执行失败 ===\n错误: %v\n", err))
-    } else {
-        logF.WriteString(fmt.Sprintf("\n\n=== 命令执行成功 ===\n退出码: %d\n", cmd.ProcessState.ExitCode()))
+        return nil, fmt.Errorf("注入密钥失败: %v", err)
     }
+    return append(env, secretEnv...), nil
+}
 
-    return err
-}
\ No newline at end of file
+// runShellCommand 使用 bash -c 执行命令，stdout 和 stderr 写入同一个输出
+// 输出为 *os.File 时由子进程直接写入，否则经管道复制
+func runShellCommand(ctx context.Context, command, workingDir string, env []string, out io.Writer) error {
+    cmd := exec.CommandContext(ctx, "bash", "-c", command)
+    if workingDir != "" {
+        cmd.Dir = workingDir
+    }
+    cmd.Env = env
+    cmd.Stdout = out
+    cmd.Stderr = out
+    setProcessGroup(cmd)
+    return cmd.Run()
+}
+
+// runStatus 根据执行错误和 context 状态确定运行状态
+// 被主动取消的运行记为 cancelled，超时仍记为 failure
+func runStatus(ctx context.Context, err error) string {
+    if err == nil {
+        return "success"
+    }
+    if errors.Is(ctx.Err(), context.Canceled) {
+        return "cancelled"
+    }
+    return "failure"
+}
+
+// failureReason 失败的运行返回 reason，成功和被主动取消的运行返回空
+func failureReason(ctx context.Context, err error, reason string) string {
+    if runStatus(ctx, err) != "failure" {
+        return ""
+    }
+    return reason
+}
+
+// commandFailure 命令已执行（退出码非0、超时被终止）时为 test，未能执行时为 infra
+func commandFailure(ran bool) string {
+    if ran {
+        return metrics.FailureTest
+    }
+    return metrics.FailureInfra
+}
+
+// recordExit 从命令的执行结果中取出退出码，写入任务结果和元数据，返回命令是否已执行
+func recordExit(result *core.TaskResult, metadata *metrics.TaskMetadata, err error) bool {
+    code, oomKilled, ran := exitStatus(err)
+    if !ran {
+        return false
+    }
+    result.ExitCode = code
+    result.OOMKilled = oomKilled
+    metadata.ExitCode = &code
+    metadata.OOMKilled = oomKilled
+    return true
+}
+
+// exitStatus 从命令的执行结果中取出退出码和是否因内存不足被终止，ran 表示命令是否已执行
+// bash 命令失败时为 *exec.ExitError（沙箱中内存超限时包装为 *oomKilledError），容器中的命令失败时为 *containerExitError，超时视为命令已执行，其他错误表示命令未能执行
+func exitStatus(err error) (code int, oomKilled, ran bool) {
+    var (
+        exitErr      *exec.ExitError
+        containerErr *containerExitError
+        oomErr       *oomKilledError
+    )
+    switch {
+    case err == nil:
+        return 0, false, true
+    case errors.As(err, &exitErr):
+        return exitErr.ExitCode(), errors.As(err, &oomErr), true
+    case errors.As(err, &containerErr):
+        return containerErr.code, containerErr.oomKilled, true
+    case errors.Is(err, context.DeadlineExceeded):
+        // 容器中执行的命令超时，容器被强制删除，没有退出码
+        return -1, false, true
+    default:
+        return -1, false, false
+    }
+}
@@ -3,8 +3,13 @@ package executor
 import (
     "context"
     "lite-cicd/config"
+    "lite-cicd/core"
+    "lite-cicd/metrics"
+    "lite-cicd/secrets"
     "os"
+    "os/exec"
     "path/filepath"
+    "reflect"
     "testing"
     "time"
 )
@@ -14,7 +19,7 @@ func TestBashExecutor(t *testing.T) {
     tempDir := t.TempDir()
     
     // 创建bash执行器
-    executor, err := NewBashExecutor(tempDir)
+    executor, err := NewBashExecutor(tempDir, metrics.NewJSONStore(tempDir))
     if err != nil {
         t.Fatalf("创建bash执行器失败: %v", err)
     }
@@ -156,6 +161,277 @@ echo "脚本执行完成"
             t.Fatalf("超时时间不符合预期，实际耗时: %v", duration)
         }
     })
+
+    // 测试退出码和失败原因
+    t.Run("退出码", func(t *testing.T) {
+        task := config.BashTaskConfig{
+            Name:    "test-exit-code",
+            Command: "echo failing; exit 3",
+            Timeout: 10,
+        }
+
+        ctx := core.WithRunInfo(context.Background(), &core.RunInfo{ID: "test-exit-code-run"})
+        result, err := executor.RunBashTask(ctx, task)
+        if err == nil {
+            t.Fatalf("预期命令失败，但执行成功")
+        }
+        if result.ExitCode != 3 || result.FailureReason != metrics.FailureTest {
+            t.Fatalf("退出码或失败原因不正确: %d %s", result.ExitCode, result.FailureReason)
+        }
+
+        metadata, err := metrics.NewJSONStore(tempDir).Get("test-exit-code-run")
+        if err != nil {
+            t.Fatalf("读取元数据失败: %v", err)
+        }
+        if metadata.ExitCode == nil || *metadata.ExitCode != 3 || metadata.FailureReason != metrics.FailureTest {
+            t.Fatalf("元数据中的退出码或失败原因不正确: %v %s", metadata.ExitCode, metadata.FailureReason)
+        }
+
+        result, err = executor.RunBashTask(context.Background(), config.BashTaskConfig{Name: "test-no-command"})
+        if err == nil || result.ExitCode != -1 || result.FailureReason != metrics.FailureInfra {
+            t.Fatalf("未执行命令时退出码应为-1且失败原因为 infra: %d %s", result.ExitCode, result.FailureReason)
+        }
+    })
+
+    // 测试在容器中执行（未设置Docker执行器）
+    t.Run("容器镜像", func(t *testing.T) {
+        task := config.BashTaskConfig{
+            Name:    "test-image",
+            Command: "echo in-container",
+            Image:   "alpine:3.19",
+            Timeout: 10,
+        }
+
+        result, err := executor.RunBashTask(context.Background(), task)
+        if err == nil {
+            t.Fatalf("未设置Docker执行器时应该失败")
+        }
+        if result.FailureReason != metrics.FailureInfra || result.ExitCode != -1 {
+            t.Fatalf("失败原因应为 infra: %s %d", result.FailureReason, result.ExitCode)
+        }
+        content, _ := os.ReadFile(result.LogFile)
+        if !contains(string(content), "Docker执行器不可用") {
+            t.Fatalf("日志中缺少错误信息: %s", content)
+        }
+    })
+
+    // 测试取消运行
+    t.Run("取消运行", func(t *testing.T) {
+        task := config.BashTaskConfig{
+            Name:        "test-cancel",
+            Description: "测试取消",
+            Command:     "sleep 30 & sleep 30; wait",
+            Timeout:     60,
+        }
+
+        ctx, cancel := context.WithCancel(context.Background())
+        ctx = core.WithRunInfo(ctx, &core.RunInfo{ID: "test-cancel-run"})
+        time.AfterFunc(500*time.Millisecond, cancel)
+
+        start := time.Now()
+        _, err := executor.RunBashTask(ctx, task)
+        duration := time.Since(start)
+
+        if err == nil {
+            t.Fatalf("预期取消错误，但执行成功")
+        }
+        if duration > 5*time.Second {
+            t.Fatalf("取消后进程未及时退出，实际耗时: %v", duration)
+        }
+
+        metadata, err := metrics.NewJSONStore(tempDir).Get("test-cancel-run")
+        if err != nil {
+            t.Fatalf("读取元数据失败: %v", err)
+        }
+        if metadata.Status != "cancelled" {
+            t.Fatalf("运行状态应该为 cancelled，实际为: %s", metadata.Status)
+        }
+    })
+
+    // 测试密钥注入与日志脱敏
+    t.Run("密钥注入", func(t *testing.T) {
+        t.Setenv("SMARTCI_SECRET_DEPLOY_TOKEN", "deploy-token-9527")
+        t.Setenv("SMARTCI_SECRET_OTHER_TOKEN", "other-token-9528")
+        executor.SetSecrets(secrets.NewManagerWithProviders(secrets.NewEnvProvider("")))
+        defer executor.SetSecrets(nil)
+
+        task := config.BashTaskConfig{
+            Name:    "test-secrets",
+            Command: `echo "token=$DEPLOY_TOKEN other=${OTHER_TOKEN:-unset} raw=${SMARTCI_SECRET_OTHER_TOKEN:-unset}"`,
+            Secrets: []string{"DEPLOY_TOKEN"},
+            Timeout: 10,
+        }
+
+        result, err := executor.RunBashTask(context.Background(), task)
+        if err != nil {
+            t.Fatalf("执行bash任务失败: %v", err)
+        }
+
+        content, _ := os.ReadFile(result.LogFile)
+        logContent := string(content)
+        if !contains(logContent, "token=*** other=unset raw=unset") {
+            t.Fatalf("密钥应该只注入声明的名称并在日志中脱敏，实际: %s", logContent)
+        }
+
+        task.Secrets = []string{"MISSING_TOKEN"}
+        if _, err := executor.RunBashTask(context.Background(), task); err == nil || !contains(err.Error(), "MISSING_TOKEN") {
+            t.Fatalf("缺少密钥时应该执行失败，实际: %v", err)
+        }
+    })
+
+    // 测试任务环境变量与运行参数
+    t.Run("环境变量与参数", func(t *testing.T) {
+        task := config.BashTaskConfig{
+            Name:    "test-params",
+            Command: `echo "stage=$STAGE version=$VERSION"`,
+            Env:     map[string]string{"STAGE": "test", "VERSION": "0.0.0"},
+            Timeout: 10,
+        }
+
+        ctx := core.WithRunInfo(context.Background(), &core.RunInfo{ID: "test-params-run"})
+        ctx = core.WithParams(ctx, map[string]string{"VERSION": "1.2.3"})
+        result, err := executor.RunBashTask(ctx, task)
+        if err != nil {
+            t.Fatalf("执行bash任务失败: %v", err)
+        }
+
+        content, _ := os.ReadFile(result.LogFile)
+        if !contains(string(content), "stage=test version=1.2.3") {
+            t.Fatalf("运行参数应该覆盖同名的任务环境变量，实际: %s", content)
+        }
+
+        metadata, err := metrics.NewJSONStore(tempDir).Get("test-params-run")
+        if err != nil {
+            t.Fatalf("读取元数据失败: %v", err)
+        }
+        if metadata.Params["VERSION"] != "1.2.3" {
+            t.Fatalf("元数据应该记录运行参数，实际: %v", metadata.Params)
+        }
+    })
+
+    // 测试webhook事件变量与事件内容文件
+    t.Run("事件变量", func(t *testing.T) {
+        task := config.BashTaskConfig{
+            Name:       "test-trigger",
+            Command:    `echo "branch=$SMARTCI_BRANCH" && cat "$SMARTCI_PAYLOAD_FILE"`,
+            WorkingDir: os.TempDir(),
+            Timeout:    10,
+        }
+
+        ctx := core.WithTrigger(context.Background(), &core.Trigger{
+            Event:   "push",
+            Env:     map[string]string{"SMARTCI_BRANCH": "main"},
+            Payload: []byte(`{"ref":"refs/heads/main"}`),
+        })
+        result, err := executor.RunBashTask(ctx, task)
+        if err != nil {
+            t.Fatalf("执行bash任务失败: %v", err)
+        }
+
+        content, _ := os.ReadFile(result.LogFile)
+        if !contains(string(content), "branch=main") || !contains(string(content), `{"ref":"refs/heads/main"}`) {
+            t.Fatalf("事件变量或事件内容文件未注入，实际: %s", content)
+        }
+        if _, err := os.Stat(filepath.Join(result.TaskDir, "payload.json")); err != nil {
+            t.Fatalf("事件内容应该保存在任务目录: %v", err)
+        }
+    })
+}
+
+func TestContainerBashCommand(t *testing.T) {
+    command := `name='a "b"'; echo "$name" $((1+2))`
+
+    // 命令作为位置参数传入，不会被外层 shell 展开
+    t.Run("参数", func(t *testing.T) {
+        argv := containerBashCommand(command)
+        if len(argv) != 5 || argv[0] != "sh" || argv[1] != "-c" || argv[3] != "sh" || argv[4] != command {
+            t.Fatalf("容器命令参数不正确: %q", argv)
+        }
+    })
+
+    // 在本机按相同参数执行，镜像中有 bash 时使用 bash，否则使用 sh
+    run := func(t *testing.T, path string) string {
+        argv := containerBashCommand(command + "; echo $0")
+        cmd := exec.Command(argv[0], argv[1:]...)
+        cmd.Env = []string{"PATH=" + path}
+        out, err := cmd.CombinedOutput()
+        if err != nil {
+            t.Fatalf("执行失败: %v\n%s", err, out)
+        }
+        return string(out)
+    }
+    t.Run("使用bash", func(t *testing.T) {
+        if _, err := exec.LookPath("bash"); err != nil {
+            t.Skip("未安装bash")
+        }
+        if out := run(t, os.Getenv("PATH")); out != "a \"b\" 3\nbash\n" {
+            t.Errorf("输出不正确: %q", out)
+        }
+    })
+    t.Run("没有bash时使用sh", func(t *testing.T) {
+        shPath, err := exec.LookPath("sh")
+        if err != nil {
+            t.Skip("未安装sh")
+        }
+        binDir := t.TempDir()
+        if err := os.Symlink(shPath, filepath.Join(binDir, "sh")); err != nil {
+            t.Fatalf("创建链接失败: %v", err)
+        }
+        if out := run(t, binDir); out != "a \"b\" 3\nsh\n" {
+            t.Errorf("输出不正确: %q", out)
+        }
+    })
+
+    // 工作目录挂载到 /workspace，事件内容文件只读挂载，环境变量传入容器
+    t.Run("容器配置", func(t *testing.T) {
+        workDir := t.TempDir()
+        task := config.BashTaskConfig{
+            Image:     "alpine:3.19",
+            Env:       map[string]string{"STAGE": "nightly"},
+            Container: config.ContainerConfig{Volumes: []string{"/srv/cache:/cache:ro"}, User: "1000"},
+        }
+        vars := runVars(context.Background(), task.Env)
+        settings := payloadSettings(task, "/var/lib/smart-ci/run-1/payload.json", vars)
+        env, err := taskEnv(nil, nil, vars, nil)
+        if err != nil {
+            t.Fatalf("生成环境变量失败: %v", err)
+        }
+
+        containerConfig, hostConfig, err := stepContainerConfig(task.Image, containerBashCommand("make"), workDir, env, settings)
+        if err != nil {
+            t.Fatalf("生成容器配置失败: %v", err)
+        }
+        wantBinds := []string{
+            workDir + ":/workspace",
+            "/srv/cache:/cache:ro",
+            "/var/lib/smart-ci/run-1/payload.json:/smart-ci/payload.json:ro",
+        }
+        if !reflect.DeepEqual(hostConfig.Binds, wantBinds) {
+            t.Errorf("挂载不正确: %q", hostConfig.Binds)
+        }
+        if containerConfig.WorkingDir != "/workspace" || containerConfig.Image != task.Image || containerConfig.User != "1000" {
+            t.Errorf("容器配置不正确: %+v", containerConfig)
+        }
+        if !reflect.DeepEqual([]string(containerConfig.Cmd), containerBashCommand("make")) {
+            t.Errorf("容器命令不正确: %q", containerConfig.Cmd)
+        }
+        wantEnv := []string{core.PayloadFileEnv + "=/smart-ci/payload.json", "STAGE=nightly"}
+        if !reflect.DeepEqual(containerConfig.Env, wantEnv) {
+            t.Errorf("环境变量不正确: %q", containerConfig.Env)
+        }
+        if len(task.Container.Volumes) != 1 {
+            t.Errorf("不应修改任务配置中的挂载: %q", task.Container.Volumes)
+        }
+    })
+
+    // 在服务器上执行时使用事件内容文件的实际路径
+    t.Run("服务器执行", func(t *testing.T) {
+        vars := map[string]string{}
+        settings := payloadSettings(config.BashTaskConfig{}, "/var/lib/smart-ci/run-1/payload.json", vars)
+        if len(settings.Volumes) != 0 || vars[core.PayloadFileEnv] != "/var/lib/smart-ci/run-1/payload.json" {
+            t.Errorf("事件内容文件路径不正确: %q %v", settings.Volumes, vars)
+        }
+    })
 }
 
 func contains(s, substr string) bool {
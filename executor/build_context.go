@@ -0,0 +1,175 @@
+package executor
+
+import (
+    "archive/tar"
+    "bufio"
+    "fmt"
+    "io"
+    "io/fs"
+    "os"
+    "path"
+    "path/filepath"
+    "regexp"
+    "strings"
+)
+
+// ignorePattern .dockerignore 中的一条规则，exclude 为 false 表示以 ! 开头的例外规则
+type ignorePattern struct {
+    re      *regexp.Regexp
+    exclude bool
+}
+
+// buildContext 将构建目录打包为 tar 流作为镜像构建的上下文，按 .dockerignore 排除文件
+// Dockerfile 和 .dockerignore 即使被排除也会发送，与 docker build 的行为一致
+func buildContext(dir, dockerfile string) (io.ReadCloser, error) {
+    patterns, err := readDockerignore(dir)
+    if err != nil {
+        return nil, err
+    }
+    keep := map[string]bool{".dockerignore": true, path.Clean(filepath.ToSlash(dockerfile)): true}
+
+    pr, pw := io.Pipe()
+    go func() {
+        pw.CloseWithError(writeBuildContext(pw, dir, patterns, keep))
+    }()
+    return pr, nil
+}
+
+// writeBuildContext 遍历构建目录写入 tar 流
+func writeBuildContext(w io.Writer, dir string, patterns []ignorePattern, keep map[string]bool) error {
+    tw := tar.NewWriter(w)
+    err := filepath.WalkDir(dir, func(file string, entry fs.DirEntry, err error) error {
+        if err != nil {
+            return err
+        }
+        rel, err := filepath.Rel(dir, file)
+        if err != nil || rel == "." {
+            return err
+        }
+        rel = filepath.ToSlash(rel)
+        if ignored(patterns, rel) && !keep[rel] {
+            // 没有例外规则时整个目录都不需要遍历
+            if entry.IsDir() && !hasExceptions(patterns) {
+                return filepath.SkipDir
+            }
+            return nil
+        }
+
+        info, err := entry.Info()
+        if err != nil {
+            return err
+        }
+        var link string
+        if info.Mode()&fs.ModeSymlink != 0 {
+            if link, err = os.Readlink(file); err != nil {
+                return err
+            }
+        }
+        header, err := tar.FileInfoHeader(info, link)
+        if err != nil {
+            return err
+        }
+        header.Name = rel
+        if entry.IsDir() {
+            header.Name += "/"
+        }
+        if err := tw.WriteHeader(header); err != nil {
+            return err
+        }
+        if !info.Mode().IsRegular() {
+            return nil
+        }
+        f, err := os.Open(file)
+        if err != nil {
+            return err
+        }
+        defer f.Close()
+        _, err = io.Copy(tw, f)
+        return err
+    })
+    if err != nil {
+        return fmt.Errorf("打包构建上下文失败: %v", err)
+    }
+    return tw.Close()
+}
+
+// readDockerignore 读取构建目录下的 .dockerignore，文件不存在时返回空规则
+// 支持 *、?、** 通配符和以 ! 开头的例外规则，匹配目录时同时排除目录下的所有文件
+func readDockerignore(dir string) ([]ignorePattern, error) {
+    f, err := os.Open(filepath.Join(dir, ".dockerignore"))
+    if os.IsNotExist(err) {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, fmt.Errorf("读取 .dockerignore 失败: %v", err)
+    }
+    defer f.Close()
+
+    var patterns []ignorePattern
+    scanner := bufio.NewScanner(f)
+    for scanner.Scan() {
+        line := strings.TrimSpace(scanner.Text())
+        if line == "" || strings.HasPrefix(line, "#") {
+            continue
+        }
+        pattern := ignorePattern{exclude: true}
+        if strings.HasPrefix(line, "!") {
+            pattern.exclude = false
+            line = strings.TrimSpace(line[1:])
+        }
+        line = strings.TrimPrefix(path.Clean("/"+filepath.ToSlash(line)), "/")
+        if line == "" {
+            continue
+        }
+        pattern.re = compileIgnorePattern(line)
+        patterns = append(patterns, pattern)
+    }
+    if err := scanner.Err(); err != nil {
+        return nil, fmt.Errorf("读取 .dockerignore 失败: %v", err)
+    }
+    return patterns, nil
+}
+
+// compileIgnorePattern 将 .dockerignore 规则转换为正则表达式，规则同时匹配路径下的所有文件
+func compileIgnorePattern(pattern string) *regexp.Regexp {
+    var b strings.Builder
+    b.WriteString("^")
+    for i := 0; i < len(pattern); i++ {
+        switch {
+        case strings.HasPrefix(pattern[i:], "**/"):
+            b.WriteString("(.*/)?")
+            i += 2
+        case strings.HasPrefix(pattern[i:], "**"):
+            b.WriteString(".*")
+            i++
+        case pattern[i] == '*':
+            b.WriteString("[^/]*")
+        case pattern[i] == '?':
+            b.WriteString("[^/]")
+        default:
+            b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+        }
+    }
+    b.WriteString("(/.*)?$")
+    return regexp.MustCompile(b.String())
+}
+
+// ignored 判断路径是否被排除，后面的规则优先
+func ignored(patterns []ignorePattern, rel string) bool {
+    excluded := false
+    for _, pattern := range patterns {
+        if pattern.re.MatchString(rel) {
+            excluded = pattern.exclude
+        }
+    }
+    return excluded
+}
+
+func hasExceptions(patterns []ignorePattern) bool {
+    for _, pattern := range patterns {
+        if !pattern.exclude {
+            return true
+        }
+    }
+    return false
+}
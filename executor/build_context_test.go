@@ -0,0 +1,71 @@
+package executor
+
+import (
+    "archive/tar"
+    "io"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+    "testing"
+)
+
+func TestBuildContext(t *testing.T) {
+    dir := t.TempDir()
+    files := map[string]string{
+        "Dockerfile":           "FROM alpine\n",
+        "main.go":              "package main\n",
+        ".dockerignore":        "# 构建产物\nnode_modules\n*.log\n**/secret.txt\ndocs\n!docs/README.md\nDockerfile\n",
+        "debug.log":            "log",
+        "node_modules/x/a.js":  "x",
+        "pkg/secret.txt":       "secret",
+        "pkg/lib.go":           "package pkg\n",
+        "docs/README.md":       "readme",
+        "docs/design.md":       "design",
+        "logs/keep/server.log": "nested log",
+    }
+    for name, content := range files {
+        file := filepath.Join(dir, name)
+        if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+            t.Fatalf("创建目录失败: %v", err)
+        }
+        if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+            t.Fatalf("写入文件失败: %v", err)
+        }
+    }
+
+    buildCtx, err := buildContext(dir, "Dockerfile")
+    if err != nil {
+        t.Fatalf("打包构建上下文失败: %v", err)
+    }
+    defer buildCtx.Close()
+
+    var names []string
+    contents := make(map[string]string)
+    tr := tar.NewReader(buildCtx)
+    for {
+        header, err := tr.Next()
+        if err == io.EOF {
+            break
+        }
+        if err != nil {
+            t.Fatalf("读取构建上下文失败: %v", err)
+        }
+        if header.Typeflag == tar.TypeDir {
+            continue
+        }
+        data, _ := io.ReadAll(tr)
+        names = append(names, header.Name)
+        contents[header.Name] = string(data)
+    }
+    sort.Strings(names)
+
+    // *.log 只匹配根目录下的文件；Dockerfile 和 .dockerignore 即使被排除也要发送
+    want := []string{".dockerignore", "Dockerfile", "docs/README.md", "logs/keep/server.log", "main.go", "pkg/lib.go"}
+    if strings.Join(names, ",") != strings.Join(want, ",") {
+        t.Errorf("构建上下文文件不正确:\n实际: %v\n期望: %v", names, want)
+    }
+    if contents["main.go"] != "package main\n" {
+        t.Errorf("文件内容不正确: %q", contents["main.go"])
+    }
+}
@@ -0,0 +1,193 @@
+package executor
+
+import (
+    "bytes"
+    "context"
+    "errors"
+    "lite-cicd/config"
+    "lite-cicd/core"
+    "lite-cicd/metrics"
+    "os"
+    "os/exec"
+    "path/filepath"
+    "strings"
+    "testing"
+
+    "github.com/docker/docker/pkg/jsonmessage"
+)
+
+// newTestRepo 创建 main 分支的本地源仓库，允许按SHA拉取；返回仓库地址和提交 version.txt 的函数
+func newTestRepo(t *testing.T) (string, func(content string) string) {
+    if _, err := exec.LookPath("git"); err != nil {
+        t.Skip("未安装git")
+    }
+
+    srcDir := t.TempDir()
+    git := func(args ...string) string {
+        cmd := exec.Command("git", args...)
+        cmd.Dir = srcDir
+        cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=ci", "GIT_AUTHOR_EMAIL=ci@example.com", "GIT_COMMITTER_NAME=ci", "GIT_COMMITTER_EMAIL=ci@example.com")
+        out, err := cmd.CombinedOutput()
+        if err != nil {
+            t.Fatalf("git %v 失败: %v\n%s", args, err, out)
+        }
+        return strings.TrimSpace(string(out))
+    }
+    commit := func(content string) string {
+        if err := os.WriteFile(filepath.Join(srcDir, "version.txt"), []byte(content), 0644); err != nil {
+            t.Fatalf("写入文件失败: %v", err)
+        }
+        git("add", "version.txt")
+        git("commit", "-q", "-m", content)
+        return git("rev-parse", "HEAD")
+    }
+    git("init", "-q", "-b", "main")
+    git("config", "uploadpack.allowReachableSHA1InWant", "true")
+    return "file://" + srcDir, commit
+}
+
+func TestSyncCode(t *testing.T) {
+    url, commit := newTestRepo(t)
+    first := commit("v1")
+
+    workDir := filepath.Join(t.TempDir(), "work")
+    readVersion := func() string {
+        data, _ := os.ReadFile(filepath.Join(workDir, "version.txt"))
+        return string(data)
+    }
+
+    t.Run("克隆分支最新提交", func(t *testing.T) {
+        sha, err := syncCode(context.Background(), url, "main", "", workDir)
+        if err != nil {
+            t.Fatalf("同步代码失败: %v", err)
+        }
+        if sha != first || readVersion() != "v1" {
+            t.Fatalf("检出的提交不正确: %s, 内容: %s", sha, readVersion())
+        }
+    })
+
+    second := commit("v2")
+    third := commit("v3")
+
+    t.Run("检出指定提交", func(t *testing.T) {
+        sha, err := syncCode(context.Background(), url, "main", second, workDir)
+        if err != nil {
+            t.Fatalf("同步代码失败: %v", err)
+        }
+        if sha != second || readVersion() != "v2" {
+            t.Fatalf("应该检出事件对应的提交 %s，实际: %s, 内容: %s", second, sha, readVersion())
+        }
+    })
+
+    t.Run("拉取分支更新", func(t *testing.T) {
+        sha, err := syncCode(context.Background(), url, "main", "", workDir)
+        if err != nil {
+            t.Fatalf("同步代码失败: %v", err)
+        }
+        if sha != third || readVersion() != "v3" {
+            t.Fatalf("应该检出分支最新提交 %s，实际: %s, 内容: %s", third, sha, readVersion())
+        }
+
+        // 没有新提交时不应报错
+        if _, err := syncCode(context.Background(), url, "main", "", workDir); err != nil {
+            t.Fatalf("分支没有更新时同步失败: %v", err)
+        }
+    })
+}
+
+func TestContainerHostConfig(t *testing.T) {
+    hostConfig, err := containerHostConfig(config.ContainerConfig{
+        CPUs:      1.5,
+        Memory:    "512m",
+        PidsLimit: 256,
+        Network:   config.NetworkNone,
+        ReadOnly:  true,
+        Tmpfs:     []string{"/tmp:size=64m", "/run"},
+        Volumes:   []string{"/srv/cache:/cache:ro"},
+    })
+    if err != nil {
+        t.Fatalf("转换容器配置失败: %v", err)
+    }
+    if hostConfig.NanoCPUs != 1500000000 || hostConfig.Memory != 512<<20 || hostConfig.PidsLimit == nil || *hostConfig.PidsLimit != 256 {
+        t.Errorf("资源限制不正确: %+v", hostConfig.Resources)
+    }
+    if hostConfig.NetworkMode != "none" || !hostConfig.ReadonlyRootfs {
+        t.Errorf("网络或只读设置不正确: %s %v", hostConfig.NetworkMode, hostConfig.ReadonlyRootfs)
+    }
+    if hostConfig.Tmpfs["/tmp"] != "size=64m" || len(hostConfig.Tmpfs) != 2 {
+        t.Errorf("tmpfs 不正确: %v", hostConfig.Tmpfs)
+    }
+    if len(hostConfig.Binds) != 1 || hostConfig.Binds[0] != "/srv/cache:/cache:ro" {
+        t.Errorf("挂载不正确: %v", hostConfig.Binds)
+    }
+
+    // 未配置时保持 Docker 默认值
+    hostConfig, _ = containerHostConfig(config.ContainerConfig{})
+    if hostConfig.NanoCPUs != 0 || hostConfig.Memory != 0 || hostConfig.PidsLimit != nil || hostConfig.NetworkMode != "" {
+        t.Errorf("默认配置不应设置限制: %+v", hostConfig)
+    }
+}
+
+
+func TestDockerExecutor_LogFileError(t *testing.T) {
+    logDir := t.TempDir()
+    // task.log 已存在同名目录，无法创建日志文件
+    if err := os.MkdirAll(filepath.Join(logDir, "log-fail", "task.log"), 0755); err != nil {
+        t.Fatalf("创建目录失败: %v", err)
+    }
+    executor := &DockerExecutor{logDir: logDir, imgPref: "smart-ci-", store: metrics.NewJSONStore(logDir)}
+    ctx := core.WithRunInfo(context.Background(), &core.RunInfo{ID: "log-fail"})
+
+    result, err := executor.Run(ctx, config.RepoConfig{Name: "app", URL: "https://example.com/app.git"}, "main")
+    if err == nil {
+        t.Fatalf("预期执行失败")
+    }
+    if result == nil || result.FailureReason != metrics.FailureInfra {
+        t.Fatalf("应该返回失败原因为 infra 的结果: %+v", result)
+    }
+    metadata, err := executor.store.Get("log-fail")
+    if err != nil {
+        t.Fatalf("读取执行记录失败: %v", err)
+    }
+    if metadata.Status != "failure" || metadata.FailureReason != metrics.FailureInfra {
+        t.Errorf("执行记录状态不正确: %s, %s", metadata.Status, metadata.FailureReason)
+    }
+}
+
+func TestStreamBuildOutput(t *testing.T) {
+    t.Run("构建成功", func(t *testing.T) {
+        var out bytes.Buffer
+        writeStage(&out, "build", "构建镜像: %s (%s)", "smart-ci-app:main", "Dockerfile")
+        body := strings.NewReader(`{"stream":"Step 1/2 : FROM alpine\n"}
+{"stream":" ---> 1d34ffeaf190\n"}
+{"aux":{"ID":"sha256:abc"}}
+{"stream":"Successfully built abc\n"}
+`)
+        if err := streamBuildOutput(body, &out); err != nil {
+            t.Fatalf("转换构建输出失败: %v", err)
+        }
+        writeStage(&out, "build", "成功")
+
+        want := "=== [build] 构建镜像: smart-ci-app:main (Dockerfile) ===\n" +
+            "Step 1/2 : FROM alpine\n ---> 1d34ffeaf190\nSuccessfully built abc\n" +
+            "=== [build] 成功 ===\n"
+        if out.String() != want {
+            t.Errorf("日志内容不正确:\n%s", out.String())
+        }
+    })
+
+    t.Run("构建步骤失败", func(t *testing.T) {
+        var out bytes.Buffer
+        body := strings.NewReader(`{"stream":"Step 2/2 : RUN make\n"}
+{"errorDetail":{"code":2,"message":"The command '/bin/sh -c make' returned a non-zero code: 2"},"error":"The command '/bin/sh -c make' returned a non-zero code: 2"}
+`)
+        err := streamBuildOutput(body, &out)
+        var buildErr *jsonmessage.JSONError
+        if !errors.As(err, &buildErr) || buildErr.Code != 2 {
+            t.Fatalf("应该返回构建步骤的错误，实际: %v", err)
+        }
+        if !strings.Contains(out.String(), "Step 2/2 : RUN make") {
+            t.Errorf("失败前的构建输出应该写入日志: %q", out.String())
+        }
+    })
+}
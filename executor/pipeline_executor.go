@@ -0,0 +1,418 @@
+package executor
+
+import (
+    "bytes"
+    "context"
+    "fmt"
+    "io"
+    "lite-cicd/config"
+    "lite-cicd/core"
+    "lite-cicd/metrics"
+    "lite-cicd/secrets"
+    "log"
+    "os"
+    "path/filepath"
+    "strings"
+    "sync"
+    "time"
+)
+
+// 流水线步骤默认超时时间
+const defaultStepTimeout = 1800 * time.Second
+
+// PipelineExecutor 多阶段流水线执行器
+// 仓库配置了 pipeline 时按阶段依赖执行各步骤，否则回退到 DockerExecutor 的 clone → build → test 流程
+type PipelineExecutor struct {
+    docker  *DockerExecutor
+    logDir  string
+    store   metrics.RunStore
+    secrets *secrets.Manager
+}
+
+func NewPipelineExecutor(logDir string, docker *DockerExecutor, store metrics.RunStore) (*PipelineExecutor, error) {
+    return &PipelineExecutor{docker: docker, logDir: logDir, store: store}, nil
+}
+
+// SetSecrets 设置密钥管理器，同时用于回退的 DockerExecutor
+func (e *PipelineExecutor) SetSecrets(manager *secrets.Manager) {
+    e.secrets = manager
+    if e.docker != nil {
+        e.docker.SetSecrets(manager)
+    }
+}
+
+func (e *PipelineExecutor) Run(ctx context.Context, repo config.RepoConfig, branch string) (*core.TaskResult, error) {
+    if !repo.Pipeline.Enabled() {
+        if e.docker == nil {
+            return nil, fmt.Errorf("Docker执行器不可用")
+        }
+        return e.docker.Run(ctx, repo, branch)
+    }
+
+    if err := repo.Pipeline.Validate(); err != nil {
+        return nil, fmt.Errorf("流水线配置无效: %v", err)
+    }
+
+    // 生成任务ID
+    taskID := core.RunID(ctx)
+
+    // 创建任务目录
+    taskDir, err := core.CreateTaskDir(e.logDir, taskID)
+    if err != nil {
+        return nil, fmt.Errorf("创建任务目录失败: %v", err)
+    }
+
+    logFile := filepath.Join(taskDir, "task.log")
+
+    result := &core.TaskResult{
+        TaskID:   taskID,
+        TaskDir:  taskDir,
+        LogFile:  logFile,
+        ExitCode: -1,
+    }
+
+    // 创建元数据记录
+    metadata := &metrics.TaskMetadata{
+        TaskID:    taskID,
+        TaskName:  repo.Name,
+        TaskType:  "pipeline",
+        StartTime: time.Now(),
+        Status:    "running",
+        LogFile:   logFile,
+        TaskDir:   taskDir,
+        Config: map[string]interface{}{
+            "url":    repo.URL,
+            "branch": branch,
+            "stages":  len(repo.Pipeline.Stages),
+            "secrets": repo.Secrets,
+        },
+        Params: core.RunParams(ctx),
+    }
+
+    log.Printf("🧩 [Pipeline] 任务ID: %s", taskID)
+    log.Printf("📁 [Pipeline] 任务目录: %s", taskDir)
+
+    // 记录运行中状态，便于日志跟随和状态查询
+    e.store.Save(metadata)
+
+    logF, err := os.Create(logFile)
+    if err != nil {
+        result.Error = fmt.Errorf("创建日志文件失败: %v", err)
+        metadata.EndTime = time.Now()
+        metadata.Duration = metadata.EndTime.Sub(metadata.StartTime).Seconds()
+        metadata.Status = "failure"
+        metadata.Error = result.Error.Error()
+        result.FailureReason = metrics.FailureInfra
+        metadata.FailureReason = result.FailureReason
+        e.store.Save(metadata)
+        return result, result.Error
+    }
+    defer logF.Close()
+
+    // 任务日志中的密钥值脱敏
+    logW := secrets.NewMaskingWriter(logF)
+    defer logW.Flush()
+
+    workDir := checkoutDir(repo.Name, taskID)
+    defer os.RemoveAll(workDir)
+
+    log.Printf("📥 [Git] 拉取代码: %s (%s)", repo.Name, branch)
+    fmt.Fprintf(logW, "=== [git] 拉取代码: %s (%s) ===\n", repo.URL, branch)
+    commit, err := syncCode(ctx, repo.URL, branch, core.RunCommit(ctx), workDir)
+    if err != nil {
+        result.Error = fmt.Errorf("git sync failed: %v", err)
+        fmt.Fprintf(logW, "%v\n", result.Error)
+        metadata.EndTime = time.Now()
+        metadata.Duration = metadata.EndTime.Sub(metadata.StartTime).Seconds()
+        metadata.Status = runStatus(ctx, result.Error)
+        metadata.Error = result.Error.Error()
+        result.FailureReason = failureReason(ctx, result.Error, metrics.FailureClone)
+        metadata.FailureReason = result.FailureReason
+        e.store.Save(metadata)
+        return result, result.Error
+    }
+    metadata.Commit = commit
+    fmt.Fprintf(logW, "检出提交: %s\n", commit)
+
+    // 仓库的环境变量、运行参数和声明的密钥注入每个步骤
+    env, err := taskEnv(e.secrets, nil, runVars(ctx, repo.Env), repo.Secrets)
+    var steps []metrics.StepMetadata
+    reason := metrics.FailureInfra
+    if err == nil {
+        steps, err = e.runPipeline(ctx, repo.Pipeline, workDir, taskDir, env, repo.Container, logW)
+        reason = recordPipelineExit(result, metadata, steps, err)
+    } else {
+        fmt.Fprintf(logW, "%v\n", err)
+    }
+
+    // 更新元数据
+    metadata.Steps = steps
+    metadata.EndTime = time.Now()
+    metadata.Duration = metadata.EndTime.Sub(metadata.StartTime).Seconds()
+
+    metadata.Status = runStatus(ctx, err)
+    if err != nil {
+        result.Error = err
+        metadata.Error = err.Error()
+        result.FailureReason = failureReason(ctx, err, reason)
+        metadata.FailureReason = result.FailureReason
+    }
+
+    e.store.Save(metadata)
+
+    return result, err
+}
+
+// runPipeline 按依赖关系执行所有阶段，无依赖关系的阶段并行执行
+// 阶段失败时，依赖它的阶段及其步骤记为 skipped
+func (e *PipelineExecutor) runPipeline(ctx context.Context, pipeline config.PipelineConfig, workDir, taskDir string, env []string, settings config.ContainerConfig, taskLog io.Writer) ([]metrics.StepMetadata, error) {
+    taskLog = &lockedWriter{w: taskLog}
+
+    done := make(map[string]chan struct{}, len(pipeline.Stages))
+    for _, stage := range pipeline.Stages {
+        done[stage.Name] = make(chan struct{})
+    }
+
+    var (
+        mu          sync.Mutex
+        stageStatus = make(map[string]string, len(pipeline.Stages))
+        stageSteps  = make(map[string][]metrics.StepMetadata, len(pipeline.Stages))
+        wg          sync.WaitGroup
+    )
+
+    for _, stage := range pipeline.Stages {
+        wg.Add(1)
+        go func(stage config.StageConfig) {
+            defer wg.Done()
+            defer close(done[stage.Name])
+
+            // 等待所有依赖阶段结束
+            runnable := true
+            for _, need := range stage.Needs {
+                <-done[need]
+                mu.Lock()
+                if stageStatus[need] != "success" {
+                    runnable = false
+                }
+                mu.Unlock()
+            }
+
+            var steps []metrics.StepMetadata
+            status := "success"
+            if !runnable {
+                status = "skipped"
+                fmt.Fprintf(taskLog, "=== [%s] 依赖阶段未成功，跳过 ===\n", stage.Name)
+                steps = skippedSteps(stage, stage.Steps)
+            } else {
+                steps, status = e.runStage(ctx, stage, workDir, taskDir, env, settings, taskLog)
+            }
+
+            mu.Lock()
+            stageStatus[stage.Name] = status
+            stageSteps[stage.Name] = steps
+            mu.Unlock()
+        }(stage)
+    }
+    wg.Wait()
+
+    // 按配置顺序汇总步骤结果
+    var (
+        allSteps []metrics.StepMetadata
+        failed   []string
+    )
+    for _, stage := range pipeline.Stages {
+        allSteps = append(allSteps, stageSteps[stage.Name]...)
+        if stageStatus[stage.Name] == "failure" {
+            failed = append(failed, stage.Name)
+        }
+    }
+
+    if len(failed) > 0 {
+        return allSteps, fmt.Errorf("流水线失败，失败阶段: %s", strings.Join(failed, ", "))
+    }
+    return allSteps, nil
+}
+
+// runStage 顺序执行阶段内的步骤，某一步失败后其余步骤记为 skipped
+func (e *PipelineExecutor) runStage(ctx context.Context, stage config.StageConfig, workDir, taskDir string, env []string, settings config.ContainerConfig, taskLog io.Writer) ([]metrics.StepMetadata, string) {
+    var steps []metrics.StepMetadata
+
+    for i, step := range stage.Steps {
+        stepMeta := e.runStep(ctx, stage.Name, step, workDir, taskDir, env, settings, taskLog)
+        steps = append(steps, stepMeta)
+        if stepMeta.Status != "success" {
+            return append(steps, skippedSteps(stage, stage.Steps[i+1:])...), "failure"
+        }
+    }
+
+    return steps, "success"
+}
+
+// runStep 执行单个步骤，输出同时写入步骤日志和任务日志
+func (e *PipelineExecutor) runStep(ctx context.Context, stageName string, step config.StepConfig, workDir, taskDir string, env []string, settings config.ContainerConfig, taskLog io.Writer) metrics.StepMetadata {
+    stepMeta := metrics.StepMetadata{
+        Stage:     stageName,
+        Name:      step.Name,
+        Image:     step.Image,
+        StartTime: time.Now(),
+        LogFile:   filepath.Join(taskDir, "steps", safeName(stageName), safeName(step.Name)+".log"),
+    }
+
+    err := e.execStep(ctx, stageName, step, workDir, stepMeta.LogFile, env, settings, taskLog)
+
+    stepMeta.EndTime = time.Now()
+    stepMeta.Duration = stepMeta.EndTime.Sub(stepMeta.StartTime).Seconds()
+    if code, oomKilled, ran := exitStatus(err); ran {
+        stepMeta.ExitCode = &code
+        stepMeta.OOMKilled = oomKilled
+    }
+    if err != nil {
+        stepMeta.Status = "failure"
+        stepMeta.Error = err.Error()
+        fmt.Fprintf(taskLog, "=== [%s/%s] 失败: %v ===\n", stageName, step.Name, err)
+        log.Printf("❌ [Pipeline] 步骤失败: %s/%s: %v", stageName, step.Name, err)
+    } else {
+        stepMeta.Status = "success"
+        fmt.Fprintf(taskLog, "=== [%s/%s] 成功 ===\n", stageName, step.Name)
+        log.Printf("✅ [Pipeline] 步骤完成: %s/%s", stageName, step.Name)
+    }
+
+    return stepMeta
+}
+
+// execStep 执行步骤，env 为仓库配置的环境变量、运行参数和密钥：宿主机步骤追加到服务器环境变量之后，容器步骤只注入 env
+// settings 为仓库的容器配置，只用于容器步骤
+func (e *PipelineExecutor) execStep(ctx context.Context, stageName string, step config.StepConfig, workDir, stepLog string, env []string, settings config.ContainerConfig, taskLog io.Writer) error {
+    if err := os.MkdirAll(filepath.Dir(stepLog), 0755); err != nil {
+        return fmt.Errorf("创建步骤日志目录失败: %v", err)
+    }
+    stepF, err := os.Create(stepLog)
+    if err != nil {
+        return fmt.Errorf("创建步骤日志失败: %v", err)
+    }
+    defer stepF.Close()
+
+    timeout := time.Duration(step.Timeout) * time.Second
+    if step.Timeout == 0 {
+        timeout = defaultStepTimeout
+    }
+    ctx, cancel := context.WithTimeout(ctx, timeout)
+    defer cancel()
+
+    stepW := secrets.NewMaskingWriter(stepF)
+    defer stepW.Flush()
+    lineW := &lineWriter{w: taskLog}
+    defer lineW.Flush()
+    out := io.MultiWriter(stepW, lineW)
+
+    if step.Image == "" {
+        log.Printf("🔧 [Pipeline] 执行步骤: %s/%s", stageName, step.Name)
+        fmt.Fprintf(taskLog, "=== [%s/%s] 宿主机执行 ===\n", stageName, step.Name)
+        hostEnv, _ := taskEnv(e.secrets, os.Environ(), nil, nil)
+        return runShellCommand(ctx, step.Command, workDir, append(hostEnv, env...), out)
+    }
+
+    if e.docker == nil {
+        return fmt.Errorf("Docker执行器不可用，无法在镜像 %s 中执行", step.Image)
+    }
+    log.Printf("🐳 [Pipeline] 执行步骤: %s/%s (%s)", stageName, step.Name, step.Image)
+    fmt.Fprintf(taskLog, "=== [%s/%s] 镜像 %s ===\n", stageName, step.Name, step.Image)
+    return e.docker.runStepContainer(ctx, step.Image, []string{"sh", "-c", step.Command}, workDir, env, settings, out)
+}
+
+// recordPipelineExit 将失败步骤的退出码写入任务结果和元数据，返回流水线失败时的原因
+// 失败步骤的命令已执行时为 test，命令未能执行（如拉取镜像失败）时属于基础设施问题
+func recordPipelineExit(result *core.TaskResult, metadata *metrics.TaskMetadata, steps []metrics.StepMetadata, err error) string {
+    if err == nil {
+        recordExit(result, metadata, nil)
+        return ""
+    }
+    for _, step := range steps {
+        if step.Status != "failure" {
+            continue
+        }
+        if step.ExitCode == nil {
+            return metrics.FailureInfra
+        }
+        exitCode := *step.ExitCode
+        result.ExitCode = exitCode
+        result.OOMKilled = step.OOMKilled
+        metadata.ExitCode = &exitCode
+        metadata.OOMKilled = step.OOMKilled
+        return metrics.FailureTest
+    }
+    return metrics.FailureInfra
+}
+
+// skippedSteps 生成被跳过步骤的元数据
+func skippedSteps(stage config.StageConfig, steps []config.StepConfig) []metrics.StepMetadata {
+    var skipped []metrics.StepMetadata
+    for _, step := range steps {
+        skipped = append(skipped, metrics.StepMetadata{
+            Stage:  stage.Name,
+            Name:   step.Name,
+            Image:  step.Image,
+            Status: "skipped",
+        })
+    }
+    return skipped
+}
+
+// safeName 将阶段/步骤名称转换为可用作文件名的形式
+func safeName(name string) string {
+    if name == "." || name == ".." {
+        return strings.Repeat("_", len(name))
+    }
+    return strings.Map(func(r rune) rune {
+        if r == '/' || r == '\\' || r == ':' || r == ' ' {
+            return '_'
+        }
+        return r
+    }, name)
+}
+
+// lockedWriter 串行化并行阶段对任务日志的写入，每次写入的内容不会与其他写入交错
+type lockedWriter struct {
+    mu sync.Mutex
+    w  io.Writer
+}
+
+func (l *lockedWriter) Write(p []byte) (int, error) {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    return l.w.Write(p)
+}
+
+// 步骤输出没有换行时，按行缓冲的最大长度，超过后直接写入任务日志
+const maxLineBuffer = 64 * 1024
+
+// lineWriter 按行缓冲一个步骤的输出，每次只向任务日志写入完整的行，
+// 配合 lockedWriter 保证并行阶段的输出不会在一行内交错；不支持并发写入
+type lineWriter struct {
+    w   io.Writer
+    buf []byte
+}
+
+func (l *lineWriter) Write(p []byte) (int, error) {
+    l.buf = append(l.buf, p...)
+    n := bytes.LastIndexByte(l.buf, '\n') + 1
+    if n == 0 && len(l.buf) < maxLineBuffer {
+        return len(p), nil
+    }
+    if n == 0 {
+        n = len(l.buf)
+    }
+    _, err := l.w.Write(l.buf[:n])
+    l.buf = append(l.buf[:0], l.buf[n:]...)
+    return len(p), err
+}
+
+// Flush 写入最后一行没有换行的输出，补上换行，避免与后续输出连在同一行
+func (l *lineWriter) Flush() error {
+    if len(l.buf) == 0 {
+        return nil
+    }
+    _, err := l.w.Write(append(l.buf, '\n'))
+    l.buf = l.buf[:0]
+    return err
+}
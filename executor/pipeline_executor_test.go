@@ -0,0 +1,320 @@
+package executor
+
+import (
+    "bytes"
+    "context"
+    "lite-cicd/config"
+    "lite-cicd/core"
+    "lite-cicd/metrics"
+    "os"
+    "path/filepath"
+    "strings"
+    "sync"
+    "testing"
+)
+
+func TestPipelineExecutor(t *testing.T) {
+    logDir := t.TempDir()
+    executor, err := NewPipelineExecutor(logDir, nil, metrics.NewJSONStore(logDir))
+    if err != nil {
+        t.Fatalf("创建流水线执行器失败: %v", err)
+    }
+
+    // 测试依赖顺序
+    t.Run("依赖顺序", func(t *testing.T) {
+        workDir := t.TempDir()
+        taskDir := t.TempDir()
+        pipeline := config.PipelineConfig{
+            Stages: []config.StageConfig{
+                {
+                    Name:  "test",
+                    Needs: []string{"build"},
+                    Steps: []config.StepConfig{
+                        {Name: "unit", Command: "test -f build.out && echo tested >> order.txt"},
+                    },
+                },
+                {
+                    Name: "build",
+                    Steps: []config.StepConfig{
+                        {Name: "compile", Command: "echo built > build.out && echo built >> order.txt"},
+                    },
+                },
+            },
+        }
+
+        var taskLog bytes.Buffer
+        steps, err := executor.runPipeline(context.Background(), pipeline, workDir, taskDir, nil, config.ContainerConfig{}, &taskLog)
+        if err != nil {
+            t.Fatalf("流水线执行失败: %v\n%s", err, taskLog.String())
+        }
+
+        if len(steps) != 2 {
+            t.Fatalf("应该记录2个步骤，实际记录了%d个", len(steps))
+        }
+        for _, step := range steps {
+            if step.Status != "success" {
+                t.Errorf("步骤 %s/%s 状态不正确: %s", step.Stage, step.Name, step.Status)
+            }
+            if _, err := os.Stat(step.LogFile); os.IsNotExist(err) {
+                t.Errorf("步骤日志不存在: %s", step.LogFile)
+            }
+        }
+
+        content, _ := os.ReadFile(workDir + "/order.txt")
+        if string(content) != "built\ntested\n" {
+            t.Errorf("阶段执行顺序不正确: %q", string(content))
+        }
+    })
+
+    // 测试失败传播
+    t.Run("失败传播", func(t *testing.T) {
+        pipeline := config.PipelineConfig{
+            Stages: []config.StageConfig{
+                {
+                    Name: "build",
+                    Steps: []config.StepConfig{
+                        {Name: "compile", Command: "echo compiling && exit 3"},
+                        {Name: "archive", Command: "echo archive"},
+                    },
+                },
+                {
+                    Name: "lint",
+                    Steps: []config.StepConfig{
+                        {Name: "vet", Command: "echo vet ok"},
+                    },
+                },
+                {
+                    Name:  "deploy",
+                    Needs: []string{"build", "lint"},
+                    Steps: []config.StepConfig{
+                        {Name: "release", Command: "echo release"},
+                    },
+                },
+            },
+        }
+
+        var taskLog bytes.Buffer
+        steps, err := executor.runPipeline(context.Background(), pipeline, t.TempDir(), t.TempDir(), nil, config.ContainerConfig{}, &taskLog)
+        if err == nil {
+            t.Fatalf("预期流水线失败，但执行成功")
+        }
+
+        want := map[string]string{
+            "build/compile":  "failure",
+            "build/archive":  "skipped",
+            "lint/vet":       "success",
+            "deploy/release": "skipped",
+        }
+        if len(steps) != len(want) {
+            t.Fatalf("应该记录%d个步骤，实际记录了%d个", len(want), len(steps))
+        }
+        for _, step := range steps {
+            key := step.Stage + "/" + step.Name
+            if step.Status != want[key] {
+                t.Errorf("步骤 %s 状态不正确: got %s, want %s", key, step.Status, want[key])
+            }
+        }
+
+        if !contains(taskLog.String(), "compiling") {
+            t.Errorf("任务日志缺少步骤输出: %s", taskLog.String())
+        }
+
+        // 失败步骤的退出码传递到任务结果和元数据
+        for _, step := range steps {
+            key := step.Stage + "/" + step.Name
+            if key == "build/compile" && (step.ExitCode == nil || *step.ExitCode != 3) {
+                t.Errorf("步骤 %s 应该记录退出码3: %v", key, step.ExitCode)
+            }
+            if key == "lint/vet" && (step.ExitCode == nil || *step.ExitCode != 0) {
+                t.Errorf("步骤 %s 应该记录退出码0: %v", key, step.ExitCode)
+            }
+            if step.Status == "skipped" && step.ExitCode != nil {
+                t.Errorf("跳过的步骤 %s 不应该有退出码", key)
+            }
+        }
+        result := &core.TaskResult{ExitCode: -1}
+        metadata := &metrics.TaskMetadata{}
+        if reason := recordPipelineExit(result, metadata, steps, err); reason != metrics.FailureTest {
+            t.Errorf("步骤命令失败时原因应该为 test，实际为 %s", reason)
+        }
+        if result.ExitCode != 3 || metadata.ExitCode == nil || *metadata.ExitCode != 3 {
+            t.Errorf("任务退出码应该为3: result=%d, metadata=%v", result.ExitCode, metadata.ExitCode)
+        }
+    })
+
+    // 测试步骤命令未能执行
+    t.Run("步骤无法执行", func(t *testing.T) {
+        pipeline := config.PipelineConfig{
+            Stages: []config.StageConfig{
+                {
+                    Name: "build",
+                    Steps: []config.StepConfig{
+                        {Name: "compile", Image: "golang:1.24", Command: "go build ./..."},
+                    },
+                },
+            },
+        }
+
+        // 执行器没有配置 Docker，镜像步骤无法执行
+        var taskLog bytes.Buffer
+        steps, err := executor.runPipeline(context.Background(), pipeline, t.TempDir(), t.TempDir(), nil, config.ContainerConfig{}, &taskLog)
+        if err == nil {
+            t.Fatalf("预期流水线失败，但执行成功")
+        }
+        if len(steps) != 1 || steps[0].Status != "failure" || steps[0].ExitCode != nil {
+            t.Fatalf("未执行的步骤应该失败且没有退出码: %+v", steps)
+        }
+
+        result := &core.TaskResult{ExitCode: -1}
+        metadata := &metrics.TaskMetadata{}
+        if reason := recordPipelineExit(result, metadata, steps, err); reason != metrics.FailureInfra {
+            t.Errorf("步骤命令未能执行时原因应该为 infra，实际为 %s", reason)
+        }
+        if result.ExitCode != -1 || metadata.ExitCode != nil {
+            t.Errorf("命令未执行时不应该记录退出码: result=%d, metadata=%v", result.ExitCode, metadata.ExitCode)
+        }
+    })
+
+    // 测试成功时的退出码
+    t.Run("成功退出码", func(t *testing.T) {
+        result := &core.TaskResult{ExitCode: -1}
+        metadata := &metrics.TaskMetadata{}
+        recordPipelineExit(result, metadata, nil, nil)
+        if result.ExitCode != 0 || metadata.ExitCode == nil || *metadata.ExitCode != 0 {
+            t.Errorf("流水线成功时退出码应该为0: result=%d, metadata=%v", result.ExitCode, metadata.ExitCode)
+        }
+    })
+
+    // 测试日志文件无法创建
+    t.Run("日志文件创建失败", func(t *testing.T) {
+        // task.log 已存在同名目录，无法创建日志文件
+        if err := os.MkdirAll(filepath.Join(logDir, "log-fail", "task.log"), 0755); err != nil {
+            t.Fatalf("创建目录失败: %v", err)
+        }
+        repo := config.RepoConfig{
+            Name: "app",
+            URL:  "https://example.com/app.git",
+            Pipeline: config.PipelineConfig{
+                Stages: []config.StageConfig{{Name: "build", Steps: []config.StepConfig{{Name: "s", Command: "true"}}}},
+            },
+        }
+        ctx := core.WithRunInfo(context.Background(), &core.RunInfo{ID: "log-fail"})
+
+        result, err := executor.Run(ctx, repo, "main")
+        if err == nil {
+            t.Fatalf("预期执行失败")
+        }
+        if result == nil || result.FailureReason != metrics.FailureInfra {
+            t.Fatalf("应该返回失败原因为 infra 的结果: %+v", result)
+        }
+        metadata, err := executor.store.Get("log-fail")
+        if err != nil {
+            t.Fatalf("读取执行记录失败: %v", err)
+        }
+        if metadata.Status != "failure" || metadata.FailureReason != metrics.FailureInfra {
+            t.Errorf("执行记录状态不正确: %s, %s", metadata.Status, metadata.FailureReason)
+        }
+    })
+
+    // 测试并行阶段的输出按行写入任务日志
+    t.Run("并行输出不交错", func(t *testing.T) {
+        command := func(c string) string {
+            // 每行分两次输出，中间停顿，使另一个阶段的输出有机会写入
+            return "for i in $(seq 50); do printf '" + c + c + "'; sleep 0.01; printf '" + c + c + "\\n'; done"
+        }
+        pipeline := config.PipelineConfig{
+            Stages: []config.StageConfig{
+                {Name: "a", Steps: []config.StepConfig{{Name: "print", Command: command("a")}}},
+                {Name: "b", Steps: []config.StepConfig{{Name: "print", Command: command("b")}}},
+            },
+        }
+
+        var taskLog bytes.Buffer
+        if _, err := executor.runPipeline(context.Background(), pipeline, t.TempDir(), t.TempDir(), nil, config.ContainerConfig{}, &taskLog); err != nil {
+            t.Fatalf("流水线执行失败: %v\n%s", err, taskLog.String())
+        }
+        counts := map[string]int{}
+        for _, line := range strings.Split(strings.TrimSpace(taskLog.String()), "\n") {
+            if strings.HasPrefix(line, "===") {
+                continue
+            }
+            counts[line]++
+        }
+        if counts["aaaa"] != 50 || counts["bbbb"] != 50 || len(counts) != 2 {
+            t.Errorf("并行阶段的输出在行内交错: %v", counts)
+        }
+    })
+
+    // 测试循环依赖
+    t.Run("循环依赖", func(t *testing.T) {
+        pipeline := config.PipelineConfig{
+            Stages: []config.StageConfig{
+                {Name: "a", Needs: []string{"b"}, Steps: []config.StepConfig{{Name: "s", Command: "true"}}},
+                {Name: "b", Needs: []string{"a"}, Steps: []config.StepConfig{{Name: "s", Command: "true"}}},
+            },
+        }
+        if err := pipeline.Validate(); err == nil {
+            t.Fatalf("预期检测到循环依赖")
+        }
+    })
+}
+
+func TestPipelineExecutor_ConcurrentCheckout(t *testing.T) {
+    url, commit := newTestRepo(t)
+    first := commit("v1")
+    second := commit("v2")
+
+    logDir := t.TempDir()
+    executor, err := NewPipelineExecutor(logDir, nil, metrics.NewJSONStore(logDir))
+    if err != nil {
+        t.Fatalf("创建流水线执行器失败: %v", err)
+    }
+    repo := config.RepoConfig{
+        Name: "concurrent-" + filepath.Base(logDir),
+        URL:  url,
+        Pipeline: config.PipelineConfig{
+            Stages: []config.StageConfig{{Name: "test", Steps: []config.StepConfig{
+                {Name: "version", Command: "sleep 0.5 && echo version=$(cat version.txt) && pwd"},
+            }}},
+        },
+    }
+
+    // 同一分支的两次运行分别检出不同的提交并同时执行
+    var wg sync.WaitGroup
+    results := make(map[string]*core.TaskResult)
+    var mu sync.Mutex
+    for id, sha := range map[string]string{"run-v1": first, "run-v2": second} {
+        wg.Add(1)
+        go func(id, sha string) {
+            defer wg.Done()
+            ctx := core.WithRunInfo(context.Background(), &core.RunInfo{ID: id, Trigger: &core.Trigger{Commit: sha}})
+            result, err := executor.Run(ctx, repo, "main")
+            if err != nil {
+                t.Errorf("运行 %s 失败: %v", id, err)
+                return
+            }
+            mu.Lock()
+            results[id] = result
+            mu.Unlock()
+        }(id, sha)
+    }
+    wg.Wait()
+
+    for id, want := range map[string]string{"run-v1": "version=v1", "run-v2": "version=v2"} {
+        result := results[id]
+        if result == nil {
+            continue
+        }
+        content, _ := os.ReadFile(result.LogFile)
+        if !contains(string(content), want) {
+            t.Errorf("运行 %s 应该使用自己检出的代码 %s，日志:\n%s", id, want, content)
+        }
+        if !contains(string(content), checkoutDir(repo.Name, id)) {
+            t.Errorf("运行 %s 应该在独立的目录中执行，日志:\n%s", id, content)
+        }
+        if _, err := os.Stat(checkoutDir(repo.Name, id)); !os.IsNotExist(err) {
+            t.Errorf("运行结束后应该删除检出目录: %v", err)
+        }
+    }
+    os.Remove(filepath.Dir(checkoutDir(repo.Name, "run-v1")))
+}
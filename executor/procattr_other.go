@@ -0,0 +1,8 @@
+//go:build !unix
+
+package executor
+
+import "os/exec"
+
+// setProcessGroup 非 Unix 平台不支持进程组，取消时仅终止 bash 进程本身
+func setProcessGroup(cmd *exec.Cmd) {}
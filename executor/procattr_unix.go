@@ -0,0 +1,27 @@
+//go:build unix
+
+package executor
+
+import (
+    "os/exec"
+    "syscall"
+    "time"
+)
+
+// 取消时发送 SIGTERM 后等待进程组退出的时间，超时后发送 SIGKILL
+const killGracePeriod = 5 * time.Second
+
+// setProcessGroup 让命令在独立的进程组中运行，取消时终止整个进程组，
+// 避免 bash 启动的子进程在任务取消或超时后继续运行
+func setProcessGroup(cmd *exec.Cmd) {
+    cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+    cmd.Cancel = func() error {
+        pgid := cmd.Process.Pid
+        err := syscall.Kill(-pgid, syscall.SIGTERM)
+        time.AfterFunc(killGracePeriod, func() {
+            syscall.Kill(-pgid, syscall.SIGKILL)
+        })
+        return err
+    }
+    cmd.WaitDelay = killGracePeriod + time.Second
+}
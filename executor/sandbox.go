@@ -0,0 +1,32 @@
+package executor
+
+import (
+    "fmt"
+    "path/filepath"
+)
+
+// oomKilledError 沙箱中的命令因超出内存上限被终止
+type oomKilledError struct {
+    err error
+}
+
+func (e *oomKilledError) Error() string {
+    return fmt.Sprintf("%v（超出沙箱内存上限被终止）", e.err)
+}
+
+func (e *oomKilledError) Unwrap() error {
+    return e.err
+}
+
+// sandboxWritable 返回沙箱中可写的目录（绝对路径）：工作目录和配置的 writable
+func sandboxWritable(workDir string, writable []string) ([]string, error) {
+    dirs := make([]string, 0, len(writable)+1)
+    for _, dir := range append([]string{workDir}, writable...) {
+        abs, err := filepath.Abs(dir)
+        if err != nil {
+            return nil, fmt.Errorf("解析可写目录失败: %v", err)
+        }
+        dirs = append(dirs, abs)
+    }
+    return dirs, nil
+}
@@ -0,0 +1,599 @@
+//go:build linux
+
+package executor
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "lite-cicd/config"
+    "log"
+    "os"
+    "os/exec"
+    "os/signal"
+    "path/filepath"
+    "runtime"
+    "strconv"
+    "strings"
+    "sync"
+    "syscall"
+    "time"
+    "unsafe"
+)
+
+const (
+    // 沙箱初始化进程的 argv[0]，服务器以该名称重新执行自身时由 SandboxInit 接管
+    sandboxInitName = "smart-ci-sandbox-init"
+    // 向沙箱初始化进程传递参数的环境变量，执行命令前移除
+    sandboxSpecEnv = "SMART_CI_SANDBOX_SPEC"
+    // 沙箱初始化失败时的退出码
+    sandboxInitFailed = 125
+)
+
+const (
+    cgroupRoot      = "/sys/fs/cgroup" // cgroup v2 挂载点
+    cgroupCPUPeriod = 100000           // cpu.max 的周期（微秒）
+    cgroupServer    = "smart-ci-server" // 服务器进程所在 cgroup 不能直接启用控制器时移入的子 cgroup
+)
+
+const (
+    prSetNoNewPrivs        = 38         // PR_SET_NO_NEW_PRIVS
+    prCapAmbient           = 47         // PR_CAP_AMBIENT
+    prCapAmbientClearAll   = 4          // PR_CAP_AMBIENT_CLEAR_ALL
+    linuxCapabilityVersion = 0x20080522 // _LINUX_CAPABILITY_VERSION_3
+    oPath                  = 0x200000   // O_PATH
+)
+
+// capHeader、capData capset 系统调用的参数，版本 3 的能力集分为两组 32 位
+type capHeader struct {
+    version uint32
+    pid     int32
+}
+
+type capData struct {
+    effective   uint32
+    permitted   uint32
+    inheritable uint32
+}
+
+// 沙箱 /dev 中保留的设备，其他设备（如磁盘）在沙箱中不可见
+var sandboxDevices = []string{"null", "zero", "full", "random", "urandom", "tty"}
+
+// mountFlagBits statfs 返回的挂载标志与对应的挂载选项
+var mountFlagBits = []struct{ st, ms uintptr }{
+    {1, syscall.MS_RDONLY},        // ST_RDONLY
+    {2, syscall.MS_NOSUID},        // ST_NOSUID
+    {4, syscall.MS_NODEV},         // ST_NODEV
+    {8, syscall.MS_NOEXEC},        // ST_NOEXEC
+    {1024, syscall.MS_NOATIME},    // ST_NOATIME
+    {2048, syscall.MS_NODIRATIME}, // ST_NODIRATIME
+    {4096, syscall.MS_RELATIME},   // ST_RELATIME
+}
+
+// sandboxSpec 传给沙箱初始化进程的参数
+type sandboxSpec struct {
+    Command  string   `json:"command"`
+    WorkDir  string   `json:"work_dir"`
+    Writable []string `json:"writable"`
+}
+
+// runSandboxCommand 在沙箱中使用 bash -c 执行命令，name 用于命名本次运行的 cgroup
+// 命令在独立的 PID 和挂载命名空间中运行：文件系统只读，工作目录和 writable 可写，/tmp 为独立的 tmpfs
+// 配置了资源限制时在独立的 cgroup 中运行，无法使用 cgroup v2 时报错；超时或取消时终止 cgroup 中的所有进程
+func runSandboxCommand(ctx context.Context, name, command, workDir string, env []string, sandbox config.SandboxConfig, out io.Writer) error {
+    writable, err := sandboxWritable(workDir, sandbox.Writable)
+    if err != nil {
+        return err
+    }
+
+    // 未配置资源限制时不使用 cgroup，超时或取消时通过PID命名空间终止所有进程
+    var cgroup *sandboxCgroup
+    if sandbox.CPUs > 0 || sandbox.Memory != "" || sandbox.PidsLimit > 0 {
+        cgroup, err = newSandboxCgroup(name, sandbox)
+        if err != nil {
+            return fmt.Errorf("沙箱资源限制不可用: %v", err)
+        }
+        defer cgroup.remove()
+    }
+
+    cmd, err := sandboxCommand(ctx, command, writable[0], writable, env)
+    if err != nil {
+        return err
+    }
+    cmd.Stdout = out
+    cmd.Stderr = out
+    if cgroup != nil {
+        cmd.SysProcAttr.UseCgroupFD = true
+        cmd.SysProcAttr.CgroupFD = int(cgroup.fd.Fd())
+        cancel := cmd.Cancel
+        cmd.Cancel = func() error {
+            err := cancel()
+            time.AfterFunc(killGracePeriod, cgroup.kill)
+            return err
+        }
+    }
+
+    err = cmd.Run()
+    if err != nil && cgroup != nil && cgroup.oomKilled() {
+        return &oomKilledError{err: err}
+    }
+    return err
+}
+
+// sandboxCommand 生成在沙箱中执行命令的进程
+// 安装了 bubblewrap 时使用 bwrap，否则在新的命名空间中重新执行服务器程序，由 SandboxInit 完成挂载设置后执行命令
+func sandboxCommand(ctx context.Context, command, workDir string, writable, env []string) (*exec.Cmd, error) {
+    if bwrap, err := exec.LookPath("bwrap"); err == nil {
+        cmd := exec.CommandContext(ctx, bwrap, bwrapArgs(command, workDir, writable)...)
+        cmd.Env = env
+        setProcessGroup(cmd)
+        return cmd, nil
+    }
+
+    spec, err := json.Marshal(sandboxSpec{Command: command, WorkDir: workDir, Writable: writable})
+    if err != nil {
+        return nil, fmt.Errorf("生成沙箱参数失败: %v", err)
+    }
+    cmd := exec.CommandContext(ctx, "/proc/self/exe")
+    cmd.Args = []string{sandboxInitName}
+    cmd.Env = append(append([]string(nil), env...), sandboxSpecEnv+"="+string(spec))
+    setProcessGroup(cmd)
+    cmd.SysProcAttr.Cloneflags = syscall.CLONE_NEWNS | syscall.CLONE_NEWPID
+    if uid, gid := os.Getuid(), os.Getgid(); uid != 0 {
+        // 非 root 用户在新的用户命名空间中获得挂载权限：服务器用户映射为命名空间中的 root，
+        // 初始化进程执行后仍保留能力，沙箱中创建的文件在宿主机上属于服务器用户
+        cmd.SysProcAttr.Cloneflags |= syscall.CLONE_NEWUSER
+        cmd.SysProcAttr.UidMappings = []syscall.SysProcIDMap{{ContainerID: 0, HostID: uid, Size: 1}}
+        cmd.SysProcAttr.GidMappings = []syscall.SysProcIDMap{{ContainerID: 0, HostID: gid, Size: 1}}
+    }
+    return cmd, nil
+}
+
+// bwrapArgs 生成 bwrap 的参数：根目录只读绑定，可写目录读写绑定，使用独立的 /dev、/proc 和 /tmp
+func bwrapArgs(command, workDir string, writable []string) []string {
+    args := []string{"--ro-bind", "/", "/", "--dev", "/dev", "--proc", "/proc", "--tmpfs", "/tmp"}
+    for _, dir := range writable {
+        args = append(args, "--bind", dir, dir)
+    }
+    return append(args, "--unshare-pid", "--die-with-parent", "--chdir", workDir, "--", "bash", "-c", command)
+}
+
+// SandboxInit 服务器程序作为沙箱初始化进程启动时（见 sandboxCommand）完成挂载设置并执行任务命令，不再返回
+// 其他情况下直接返回，需要在 main 函数开始时调用
+func SandboxInit() {
+    if len(os.Args) == 0 || os.Args[0] != sandboxInitName {
+        return
+    }
+    // 能力集和 no_new_privs 按线程设置，需要在启动命令的线程上完成
+    runtime.LockOSThread()
+    err := sandboxInit()
+    fmt.Fprintf(os.Stderr, "沙箱初始化失败: %v\n", err)
+    os.Exit(sandboxInitFailed)
+}
+
+// sandboxInit 在新的挂载命名空间中设置文件系统后执行命令，命令结束后以命令的退出码退出，只在出错时返回
+func sandboxInit() error {
+    var spec sandboxSpec
+    if err := json.Unmarshal([]byte(os.Getenv(sandboxSpecEnv)), &spec); err != nil {
+        return fmt.Errorf("解析沙箱参数失败: %v", err)
+    }
+    os.Unsetenv(sandboxSpecEnv)
+
+    // 挂载变更只在沙箱中生效，不传播到宿主机
+    if err := syscall.Mount("", "/", "", syscall.MS_REC|syscall.MS_PRIVATE, ""); err != nil {
+        return fmt.Errorf("设置挂载传播失败: %v", err)
+    }
+    // 先打开可写目录，/tmp 替换为 tmpfs 后仍然可以通过文件描述符绑定原目录
+    dirs := make([]*os.File, len(spec.Writable))
+    for i, dir := range spec.Writable {
+        f, err := os.Open(dir)
+        if err != nil {
+            return fmt.Errorf("打开可写目录失败: %v", err)
+        }
+        dirs[i] = f
+    }
+
+    if err := remountReadOnly(); err != nil {
+        return err
+    }
+    if err := syscall.Mount("proc", "/proc", "proc", syscall.MS_NOSUID|syscall.MS_NODEV|syscall.MS_NOEXEC, ""); err != nil {
+        return fmt.Errorf("挂载 /proc 失败: %v", err)
+    }
+    if err := syscall.Mount("tmpfs", "/tmp", "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV, "mode=1777"); err != nil {
+        return fmt.Errorf("挂载 /tmp 失败: %v", err)
+    }
+    if err := mountDev(); err != nil {
+        return err
+    }
+    for i, dir := range spec.Writable {
+        if err := bindWritable(dirs[i], dir); err != nil {
+            return fmt.Errorf("挂载可写目录 %s 失败: %v", dir, err)
+        }
+        dirs[i].Close()
+    }
+    if err := os.Chdir(spec.WorkDir); err != nil {
+        return fmt.Errorf("切换工作目录失败: %v", err)
+    }
+
+    // 禁止解除只读挂载、创建设备和通过 setuid 程序提权
+    if err := dropCapabilities(); err != nil {
+        return err
+    }
+    if _, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, prSetNoNewPrivs, 1, 0); errno != 0 {
+        return fmt.Errorf("设置 no_new_privs 失败: %v", errno)
+    }
+
+    bash, err := exec.LookPath("bash")
+    if err != nil {
+        return err
+    }
+    code, err := sandboxWait(bash, spec.Command)
+    if err != nil {
+        return err
+    }
+    os.Exit(code)
+    return nil
+}
+
+// sandboxWait 初始化进程作为 PID 命名空间的 1 号进程启动命令并等待结束，返回命令的退出码，被信号终止时为 128+信号值
+// 内核只向设置了处理函数的 1 号进程传递信号，命令不能作为 1 号进程运行，否则取消时的 SIGTERM 会被忽略；
+// 等待期间将 SIGTERM 和 SIGINT 转发给命令，并回收命名空间中的孤儿进程
+func sandboxWait(bash, command string) (int, error) {
+    signals := make(chan os.Signal, 1)
+    signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+    // 初始化进程的其他线程仍保留能力，禁止命令调试或读取初始化进程的内存
+    if _, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, syscall.PR_SET_DUMPABLE, 0, 0); errno != 0 {
+        return 0, fmt.Errorf("设置 dumpable 失败: %v", errno)
+    }
+    pid, err := syscall.ForkExec(bash, []string{"bash", "-c", command}, &syscall.ProcAttr{
+        Env:   os.Environ(),
+        Files: []uintptr{0, 1, 2},
+    })
+    if err != nil {
+        return 0, fmt.Errorf("启动命令失败: %v", err)
+    }
+    go func() {
+        for sig := range signals {
+            syscall.Kill(pid, sig.(syscall.Signal))
+        }
+    }()
+
+    for {
+        var status syscall.WaitStatus
+        wpid, err := syscall.Wait4(-1, &status, 0, nil)
+        if err == syscall.EINTR {
+            continue
+        }
+        if err != nil {
+            return 0, fmt.Errorf("等待命令结束失败: %v", err)
+        }
+        if wpid != pid {
+            continue
+        }
+        if status.Signaled() {
+            return 128 + int(status.Signal()), nil
+        }
+        return status.ExitStatus(), nil
+    }
+}
+
+// dropCapabilities 清空所有能力集，命令以 root（非 root 用户运行服务器时为用户命名空间中的 root）执行，
+// 清空边界集和可继承集后执行命令也不会重新获得能力
+func dropCapabilities() error {
+    for capability := uintptr(0); ; capability++ {
+        _, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, syscall.PR_CAPBSET_DROP, capability, 0)
+        if errno == syscall.EINVAL {
+            // 超出内核支持的最大能力
+            break
+        }
+        if errno != 0 {
+            return fmt.Errorf("移除能力 %d 失败: %v", capability, errno)
+        }
+    }
+    // 4.3 之前的内核没有 ambient 能力集
+    if _, _, errno := syscall.RawSyscall6(syscall.SYS_PRCTL, prCapAmbient, prCapAmbientClearAll, 0, 0, 0, 0); errno != 0 && errno != syscall.EINVAL {
+        return fmt.Errorf("清空 ambient 能力集失败: %v", errno)
+    }
+    header := capHeader{version: linuxCapabilityVersion}
+    var data [2]capData
+    if _, _, errno := syscall.RawSyscall(syscall.SYS_CAPSET, uintptr(unsafe.Pointer(&header)), uintptr(unsafe.Pointer(&data[0])), 0); errno != 0 {
+        return fmt.Errorf("清空能力集失败: %v", errno)
+    }
+    return nil
+}
+
+// mountDev 在 /dev 挂载新的 tmpfs，只绑定 sandboxDevices 中的设备，与 bwrap --dev 一致
+func mountDev() error {
+    devices := make(map[string]*os.File)
+    for _, name := range sandboxDevices {
+        // O_PATH 只引用设备节点，不会打开设备
+        f, err := os.OpenFile(filepath.Join("/dev", name), oPath, 0)
+        if err != nil {
+            continue
+        }
+        defer f.Close()
+        devices[name] = f
+    }
+
+    if err := syscall.Mount("tmpfs", "/dev", "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV|syscall.MS_NOEXEC, "mode=0755"); err != nil {
+        return fmt.Errorf("挂载 /dev 失败: %v", err)
+    }
+    for name, f := range devices {
+        dev := filepath.Join("/dev", name)
+        if err := os.WriteFile(dev, nil, 0644); err != nil {
+            return fmt.Errorf("创建 %s 失败: %v", dev, err)
+        }
+        source := fmt.Sprintf("/proc/self/fd/%d", f.Fd())
+        if err := syscall.Mount(source, dev, "", syscall.MS_BIND, ""); err != nil {
+            return fmt.Errorf("挂载 %s 失败: %v", dev, err)
+        }
+    }
+    links := map[string]string{"fd": "/proc/self/fd", "stdin": "/proc/self/fd/0", "stdout": "/proc/self/fd/1", "stderr": "/proc/self/fd/2"}
+    for name, target := range links {
+        if err := os.Symlink(target, filepath.Join("/dev", name)); err != nil {
+            return fmt.Errorf("创建 /dev/%s 失败: %v", name, err)
+        }
+    }
+    if err := os.Mkdir("/dev/shm", 01777); err != nil {
+        return fmt.Errorf("创建 /dev/shm 失败: %v", err)
+    }
+    if err := syscall.Mount("tmpfs", "/dev/shm", "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV, "mode=1777"); err != nil {
+        return fmt.Errorf("挂载 /dev/shm 失败: %v", err)
+    }
+    return nil
+}
+
+// remountReadOnly 将所有挂载点重新挂载为只读，/proc 随后会重新挂载，跳过
+func remountReadOnly() error {
+    points, err := mountPoints()
+    if err != nil {
+        return err
+    }
+    for _, point := range points {
+        if point == "/proc" || strings.HasPrefix(point, "/proc/") {
+            continue
+        }
+        flags, err := mountFlags(point)
+        if err != nil {
+            // 被其他挂载覆盖或无法访问的挂载点
+            continue
+        }
+        if err := syscall.Mount("", point, "", syscall.MS_REMOUNT|syscall.MS_BIND|syscall.MS_RDONLY|flags, ""); err != nil {
+            return fmt.Errorf("只读挂载 %s 失败: %v", point, err)
+        }
+    }
+    return nil
+}
+
+// bindWritable 将打开的目录以读写方式绑定到 dir，不允许使用其中的设备文件和 setuid 程序
+func bindWritable(f *os.File, dir string) error {
+    if err := os.MkdirAll(dir, 0755); err != nil {
+        return err
+    }
+    source := fmt.Sprintf("/proc/self/fd/%d", f.Fd())
+    if err := syscall.Mount(source, dir, "", syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
+        return err
+    }
+    // 绑定挂载沿用来源的只读选项，需要再重新挂载为读写
+    flags, err := mountFlags(dir)
+    if err != nil {
+        return err
+    }
+    return syscall.Mount("", dir, "", syscall.MS_REMOUNT|syscall.MS_BIND|syscall.MS_NOSUID|syscall.MS_NODEV|flags&^syscall.MS_RDONLY, "")
+}
+
+// mountPoints 读取当前挂载命名空间的所有挂载点
+func mountPoints() ([]string, error) {
+    data, err := os.ReadFile("/proc/self/mountinfo")
+    if err != nil {
+        return nil, fmt.Errorf("读取挂载信息失败: %v", err)
+    }
+    var points []string
+    for _, line := range strings.Split(string(data), "\n") {
+        fields := strings.Fields(line)
+        if len(fields) > 4 {
+            points = append(points, unescapeMountPoint(fields[4]))
+        }
+    }
+    return points, nil
+}
+
+// unescapeMountPoint 还原 mountinfo 中以八进制转义的空白和反斜杠，如 \040
+func unescapeMountPoint(s string) string {
+    var b strings.Builder
+    for i := 0; i < len(s); i++ {
+        if s[i] == '\\' && i+4 <= len(s) {
+            if c, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+                b.WriteByte(byte(c))
+                i += 3
+                continue
+            }
+        }
+        b.WriteByte(s[i])
+    }
+    return b.String()
+}
+
+// mountFlags 返回挂载点当前的挂载选项，重新挂载时需要保留（用户命名空间中不能清除这些选项）
+func mountFlags(path string) (uintptr, error) {
+    var st syscall.Statfs_t
+    if err := syscall.Statfs(path, &st); err != nil {
+        return 0, err
+    }
+    var flags uintptr
+    for _, bit := range mountFlagBits {
+        if uintptr(st.Flags)&bit.st != 0 {
+            flags |= bit.ms
+        }
+    }
+    if flags&(syscall.MS_NOATIME|syscall.MS_RELATIME) == 0 {
+        flags |= syscall.MS_STRICTATIME
+    }
+    return flags, nil
+}
+
+// sandboxCgroup 一次沙箱运行使用的 cgroup
+type sandboxCgroup struct {
+    dir string
+    fd  *os.File
+}
+
+// 沙箱 cgroup 的父目录，首次使用时确定
+var sandboxCgroups struct {
+    once   sync.Once
+    parent string
+    err    error
+}
+
+// newSandboxCgroup 在服务器进程所在的 cgroup 下创建本次运行的子 cgroup 并设置资源限制
+func newSandboxCgroup(name string, sandbox config.SandboxConfig) (*sandboxCgroup, error) {
+    sandboxCgroups.once.Do(func() {
+        sandboxCgroups.parent, sandboxCgroups.err = cgroupParent()
+    })
+    if sandboxCgroups.err != nil {
+        return nil, sandboxCgroups.err
+    }
+
+    dir := filepath.Join(sandboxCgroups.parent, "smart-ci-"+name)
+    if err := os.Mkdir(dir, 0755); err != nil {
+        return nil, fmt.Errorf("创建 cgroup 失败: %v", err)
+    }
+    cgroup := &sandboxCgroup{dir: dir}
+    if err := cgroup.setLimits(sandbox); err != nil {
+        cgroup.remove()
+        return nil, err
+    }
+    fd, err := os.Open(dir)
+    if err != nil {
+        cgroup.remove()
+        return nil, fmt.Errorf("打开 cgroup 失败: %v", err)
+    }
+    cgroup.fd = fd
+    return cgroup, nil
+}
+
+// cgroupParent 返回创建沙箱 cgroup 的父目录，即服务器进程所在的 cgroup，并为子 cgroup 启用 cpu、memory、pids 控制器
+// cgroup v2 中有进程的 cgroup（根 cgroup 除外）不能为子 cgroup 启用控制器，此时先将服务器进程移到子 cgroup smart-ci-server 中
+// 只在首次运行配置了资源限制的沙箱任务时调用，未配置资源限制时不改变服务器进程所在的 cgroup
+func cgroupParent() (string, error) {
+    if _, err := os.Stat(filepath.Join(cgroupRoot, "cgroup.controllers")); err != nil {
+        return "", fmt.Errorf("未挂载 cgroup v2: %v", err)
+    }
+    data, err := os.ReadFile("/proc/self/cgroup")
+    if err != nil {
+        return "", fmt.Errorf("读取进程 cgroup 失败: %v", err)
+    }
+    var parent string
+    for _, line := range strings.Split(string(data), "\n") {
+        if own, ok := strings.CutPrefix(line, "0::"); ok {
+            parent = filepath.Join(cgroupRoot, own)
+        }
+    }
+    if parent == "" {
+        return "", fmt.Errorf("未找到服务器进程所在的 cgroup v2")
+    }
+
+    available, err := os.ReadFile(filepath.Join(parent, "cgroup.controllers"))
+    if err != nil {
+        return "", fmt.Errorf("读取 cgroup 控制器失败: %v", err)
+    }
+    var enable []string
+    for _, controller := range strings.Fields(string(available)) {
+        if controller == "cpu" || controller == "memory" || controller == "pids" {
+            enable = append(enable, "+"+controller)
+        }
+    }
+    if len(enable) == 0 {
+        return parent, nil
+    }
+
+    control := filepath.Join(parent, "cgroup.subtree_control")
+    err = os.WriteFile(control, []byte(strings.Join(enable, " ")), 0644)
+    if errors.Is(err, syscall.EBUSY) {
+        leaf := filepath.Join(parent, cgroupServer)
+        if err := os.Mkdir(leaf, 0755); err != nil && !os.IsExist(err) {
+            return "", fmt.Errorf("创建 cgroup 失败: %v", err)
+        }
+        if err := os.WriteFile(filepath.Join(leaf, "cgroup.procs"), []byte(strconv.Itoa(os.Getpid())), 0644); err != nil {
+            return "", fmt.Errorf("移动服务器进程到 %s 失败: %v", leaf, err)
+        }
+        log.Printf("📦 [Bash] 服务器进程已移入 cgroup %s", leaf)
+        err = os.WriteFile(control, []byte(strings.Join(enable, " ")), 0644)
+    }
+    if err != nil {
+        return "", fmt.Errorf("启用 cgroup 控制器失败: %v", err)
+    }
+    return parent, nil
+}
+
+// setLimits 写入 CPU、内存和进程数限制，对应的控制器未启用时报错
+func (c *sandboxCgroup) setLimits(sandbox config.SandboxConfig) error {
+    limits := make(map[string]string)
+    if sandbox.CPUs > 0 {
+        limits["cpu.max"] = fmt.Sprintf("%d %d", int64(sandbox.CPUs*cgroupCPUPeriod), cgroupCPUPeriod)
+    }
+    if sandbox.Memory != "" {
+        memory, err := config.ParseMemory(sandbox.Memory)
+        if err != nil {
+            return err
+        }
+        limits["memory.max"] = strconv.FormatInt(memory, 10)
+    }
+    if sandbox.PidsLimit > 0 {
+        limits["pids.max"] = strconv.FormatInt(sandbox.PidsLimit, 10)
+    }
+    for file, value := range limits {
+        if err := os.WriteFile(filepath.Join(c.dir, file), []byte(value), 0644); err != nil {
+            return fmt.Errorf("设置 cgroup %s 失败: %v", file, err)
+        }
+    }
+    if sandbox.Memory != "" {
+        // 不允许使用交换分区绕过内存上限，未启用交换分区统计时忽略
+        os.WriteFile(filepath.Join(c.dir, "memory.swap.max"), []byte("0"), 0644)
+    }
+    return nil
+}
+
+// kill 终止 cgroup 中的所有进程，5.14 之前的内核没有 cgroup.kill，逐个终止
+func (c *sandboxCgroup) kill() {
+    if err := os.WriteFile(filepath.Join(c.dir, "cgroup.kill"), []byte("1"), 0644); err == nil {
+        return
+    }
+    data, _ := os.ReadFile(filepath.Join(c.dir, "cgroup.procs"))
+    for _, field := range strings.Fields(string(data)) {
+        if pid, err := strconv.Atoi(field); err == nil {
+            syscall.Kill(pid, syscall.SIGKILL)
+        }
+    }
+}
+
+// oomKilled 判断 cgroup 中是否有进程因超出内存上限被终止
+func (c *sandboxCgroup) oomKilled() bool {
+    data, err := os.ReadFile(filepath.Join(c.dir, "memory.events"))
+    if err != nil {
+        return false
+    }
+    for _, line := range strings.Split(string(data), "\n") {
+        if count, ok := strings.CutPrefix(line, "oom_kill "); ok {
+            return count != "0"
+        }
+    }
+    return false
+}
+
+// remove 终止残留进程后删除 cgroup，进程退出后才能删除，最多等待5秒
+func (c *sandboxCgroup) remove() {
+    if c.fd != nil {
+        c.fd.Close()
+    }
+    c.kill()
+    for i := 0; i < 50; i++ {
+        if err := syscall.Rmdir(c.dir); err == nil || errors.Is(err, syscall.ENOENT) {
+            return
+        }
+        time.Sleep(100 * time.Millisecond)
+    }
+    log.Printf("⚠️ [Bash] 删除沙箱 cgroup 失败: %s", c.dir)
+}
@@ -0,0 +1,192 @@
+//go:build linux
+
+package executor
+
+import (
+    "bytes"
+    "context"
+    "errors"
+    "lite-cicd/config"
+    "os"
+    "os/exec"
+    "path/filepath"
+    "strconv"
+    "strings"
+    "sync"
+    "syscall"
+    "testing"
+    "time"
+)
+
+func TestMain(m *testing.M) {
+    // 沙箱通过重新执行测试程序完成初始化
+    SandboxInit()
+    os.Exit(m.Run())
+}
+
+func TestRunSandboxCommand(t *testing.T) {
+    workDir := t.TempDir()
+    cwd, err := os.Getwd()
+    if err != nil {
+        t.Fatalf("获取当前目录失败: %v", err)
+    }
+    outside := filepath.Join(cwd, "sandbox-escape.txt")
+    defer os.Remove(outside)
+
+    command := strings.Join([]string{
+        "echo ok > result.txt",
+        "touch " + outside + " 2>/dev/null && echo escaped || echo readonly",
+        "test -d /proc/" + strconv.Itoa(os.Getpid()) + " && echo visible || echo isolated",
+    }, "\n")
+    var out bytes.Buffer
+    err = runSandboxCommand(context.Background(), "test", command, workDir, os.Environ(), config.SandboxConfig{Enabled: true}, &out)
+    var exitErr *exec.ExitError
+    if err != nil && !errors.As(err, &exitErr) {
+        t.Skipf("当前环境无法创建沙箱: %v", err)
+    }
+    if err != nil {
+        t.Fatalf("沙箱中执行失败: %v\n%s", err, out.String())
+    }
+
+    if content, err := os.ReadFile(filepath.Join(workDir, "result.txt")); err != nil || string(content) != "ok\n" {
+        t.Errorf("工作目录应该可写，读取结果: %q, %v", content, err)
+    }
+    if !strings.Contains(out.String(), "readonly") {
+        t.Errorf("工作目录之外应该只读，输出:\n%s", out.String())
+    }
+    if !strings.Contains(out.String(), "isolated") {
+        t.Errorf("沙箱中不应看到服务器进程，输出:\n%s", out.String())
+    }
+}
+
+func TestRunSandboxCommand_Devices(t *testing.T) {
+    workDir := t.TempDir()
+    // 以 root 运行测试时在工作目录中预先创建设备文件，沙箱中的可写目录不允许使用设备
+    hostDev := false
+    if os.Getuid() == 0 {
+        hostDev = syscall.Mknod(filepath.Join(workDir, "null"), syscall.S_IFCHR|0666, 1<<8|3) == nil
+    }
+
+    command := strings.Join([]string{
+        "mknod ./blk b 7 0 2>/dev/null && echo mknod-created || echo mknod-denied",
+        "head -c1 ./blk >/dev/null 2>&1 && echo blk-readable || echo blk-denied",
+        "head -c1 /dev/loop0 >/dev/null 2>&1 && echo loop-readable || echo loop-denied",
+        "cat ./null >/dev/null 2>&1 && echo dev-readable || echo dev-denied",
+        "grep -E '^Cap(Inh|Prm|Eff|Bnd|Amb):' /proc/self/status",
+    }, "\n")
+    var out bytes.Buffer
+    err := runSandboxCommand(context.Background(), "test-devices", command, workDir, os.Environ(), config.SandboxConfig{Enabled: true}, &out)
+    var exitErr *exec.ExitError
+    if err != nil && !errors.As(err, &exitErr) {
+        t.Skipf("当前环境无法创建沙箱: %v", err)
+    }
+    if err != nil {
+        t.Fatalf("沙箱中执行失败: %v\n%s", err, out.String())
+    }
+
+    output := out.String()
+    for _, want := range []string{"mknod-denied", "blk-denied", "loop-denied"} {
+        if !strings.Contains(output, want) {
+            t.Errorf("沙箱中不应能创建或读取设备，缺少 %s，输出:\n%s", want, output)
+        }
+    }
+    if hostDev && !strings.Contains(output, "dev-denied") {
+        t.Errorf("可写目录中的设备文件不应能使用，输出:\n%s", output)
+    }
+    caps := 0
+    for _, line := range strings.Split(output, "\n") {
+        if !strings.HasPrefix(line, "Cap") {
+            continue
+        }
+        caps++
+        if fields := strings.Fields(line); len(fields) != 2 || strings.Trim(fields[1], "0") != "" {
+            t.Errorf("沙箱中的能力集应该为空: %s", line)
+        }
+    }
+    if caps != 5 {
+        t.Errorf("应该读取到5个能力集，输出:\n%s", output)
+    }
+}
+
+func TestRunSandboxCommand_Cancel(t *testing.T) {
+    // 孤儿进程退出后应被回收，取消时命令应收到 SIGTERM 并按 trap 退出，不需要等到 SIGKILL
+    command := strings.Join([]string{
+        "bash -c 'sleep 0.1 &'",
+        "sleep 0.5",
+        "grep -l '^State:.*zombie' /proc/[0-9]*/status 2>/dev/null | sed 's/^/zombie /'",
+        "echo ready",
+        "trap 'echo terminated; exit 3' TERM",
+        "sleep 30 & wait",
+    }, "\n")
+    ctx, cancel := context.WithCancel(context.Background())
+    defer cancel()
+    var out syncBuffer
+    done := make(chan error, 1)
+    go func() {
+        done <- runSandboxCommand(ctx, "test-cancel", command, t.TempDir(), os.Environ(), config.SandboxConfig{Enabled: true}, &out)
+    }()
+
+    deadline := time.After(5 * time.Second)
+    for !strings.Contains(out.String(), "ready") {
+        select {
+        case err := <-done:
+            t.Skipf("当前环境无法创建沙箱: %v\n%s", err, out.String())
+        case <-deadline:
+            t.Fatalf("命令未开始执行，输出:\n%s", out.String())
+        case <-time.After(10 * time.Millisecond):
+        }
+    }
+    cancel()
+    start := time.Now()
+    var err error
+    select {
+    case err = <-done:
+    case <-time.After(killGracePeriod + 2*time.Second):
+        t.Fatalf("取消后命令未退出")
+    }
+
+    if elapsed := time.Since(start); elapsed >= killGracePeriod {
+        t.Errorf("命令应该收到 SIGTERM 后退出，实际等待了 %v", elapsed)
+    }
+    var exitErr *exec.ExitError
+    if !errors.As(err, &exitErr) || exitErr.ExitCode() != 3 {
+        t.Errorf("命令应该按 trap 以退出码3结束: %v", err)
+    }
+    if !strings.Contains(out.String(), "terminated") {
+        t.Errorf("命令应该收到 SIGTERM，输出:\n%s", out.String())
+    }
+    if strings.Contains(out.String(), "zombie") {
+        t.Errorf("孤儿进程退出后应该被回收，输出:\n%s", out.String())
+    }
+}
+
+// syncBuffer 可以在命令写入的同时读取的缓冲区
+type syncBuffer struct {
+    mu  sync.Mutex
+    buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    return b.buf.String()
+}
+
+func TestUnescapeMountPoint(t *testing.T) {
+    for input, want := range map[string]string{
+        "/":                  "/",
+        `/mnt/my\040disk`:    "/mnt/my disk",
+        `/data\134backslash`: `/data\backslash`,
+        `/tail\04`:           `/tail\04`,
+    } {
+        if got := unescapeMountPoint(input); got != want {
+            t.Errorf("unescapeMountPoint(%q) = %q，期望 %q", input, got, want)
+        }
+    }
+}
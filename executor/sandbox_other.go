@@ -0,0 +1,18 @@
+//go:build !linux
+
+package executor
+
+import (
+    "context"
+    "fmt"
+    "io"
+    "lite-cicd/config"
+)
+
+// SandboxInit 非 Linux 平台不支持沙箱，直接返回
+func SandboxInit() {}
+
+// runSandboxCommand 非 Linux 平台不支持沙箱
+func runSandboxCommand(ctx context.Context, name, command, workDir string, env []string, sandbox config.SandboxConfig, out io.Writer) error {
+    return fmt.Errorf("沙箱仅支持 Linux")
+}
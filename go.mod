@@ -7,8 +7,8 @@ require (
 	github.com/go-git/go-git/v5 v5.11.0
 	github.com/robfig/cron/v3 v3.0.1
 	github.com/sashabaranov/go-openai v1.41.2
+	go.etcd.io/bbolt v1.4.3
 	gopkg.in/yaml.v3 v3.0.1
-
 )
 
 require (
@@ -1,14 +1,18 @@
 package main
 
 import (
+    "bytes"
     "context"
     "encoding/json"
     "flag"
     "fmt"
     "log"
     "net/http"
+    "net/url"
     "os"
     "os/signal"
+    "strconv"
+    "strings"
     "sync"
     "syscall"
     "time"
@@ -19,7 +23,10 @@ import (
     "lite-cicd/config"
     "lite-cicd/core"
     "lite-cicd/executor"
+    "lite-cicd/metrics"
     "lite-cicd/oauth"
+    "lite-cicd/reporter"
+    "lite-cicd/secrets"
     "lite-cicd/webhook"
 )
 
@@ -28,20 +35,29 @@ type Engine struct {
     executor     core.Executor
     bashExecutor core.BashExecutor
     agent        core.Agent
+    store        metrics.RunStore
+    secrets      *secrets.Manager        // 密钥管理，任务声明的密钥从中读取
     cron         *cron.Cron
     mu           sync.Mutex
     running      bool
-    taskStatus   map[string]bool         // 任务运行状态
+    queue        *core.RunQueue          // 运行队列，限制并发并按任务策略调度
+    repoEntry    cron.EntryID            // 全局仓库调度的cron entry ID
     taskEntries  map[string]cron.EntryID // 任务cron entry ID映射
+    stoppedTasks map[string]bool         // 被 stop 命令停止的周期性任务，重新加载配置时保持停止
     shutdownChan chan struct{}           // 服务器关闭信号
 }
 
 type Server struct {
     engine          *Engine
+    configFile      string
+    mu              sync.RWMutex // 保护 cfg、oauthProviders、oauthTokens、webhookHandlers，热加载时整体替换
+    reloadMu        sync.Mutex   // 串行化重新加载
     cfg             *config.Config
     server          *http.Server
     oauthProviders  map[string]oauth.Provider
+    oauthTokens     map[string]*oauth.Token // OAuth授权获得的令牌，按提供商名称保存，用于回报提交状态
     webhookHandlers map[string]*webhook.Handler
+    stopWatch       context.CancelFunc
 }
 
 // APIRequest API请求结构
@@ -57,28 +73,52 @@ type APIResponse struct {
     Data    interface{} `json:"data,omitempty"`
 }
 
-func NewEngine(cfg config.Config) *Engine {
-    dockerExecutor, _ := executor.NewDockerExecutor("./logs")
-    bashExecutor, _ := executor.NewBashExecutor("./logs")
-    aiAgent := ai.NewAIAgent(cfg.LLMKey, cfg.LLMBase)
+func NewEngine(cfg config.Config, secretManager *secrets.Manager) *Engine {
+    store, err := metrics.OpenStore(cfg.Store.Type, cfg.Store.Path, "./logs")
+    if err != nil {
+        log.Printf("⚠️ 执行记录存储初始化失败: %v，使用JSON文件存储", err)
+        store = metrics.NewJSONStore("./logs")
+    }
+
+    dockerExecutor, err := executor.NewDockerExecutor("./logs", store)
+    if err != nil {
+        log.Printf("⚠️ Docker执行器初始化失败: %v", err)
+        dockerExecutor = nil
+    }
+    pipelineExecutor, _ := executor.NewPipelineExecutor("./logs", dockerExecutor, store)
+    pipelineExecutor.SetSecrets(secretManager)
+    bashExecutor, _ := executor.NewBashExecutor("./logs", store)
+    bashExecutor.SetSecrets(secretManager)
+    bashExecutor.SetDocker(dockerExecutor)
+    aiAgent := ai.NewAIAgent(cfg.LLMSettings())
 
     return &Engine{
         cfg:          cfg,
-        executor:     dockerExecutor,
+        executor:     pipelineExecutor,
         bashExecutor: bashExecutor,
         agent:        aiAgent,
+        store:        store,
+        secrets:      secretManager,
         cron:         cron.New(),
-        taskStatus:   make(map[string]bool),
+        queue:        core.NewRunQueue(cfg.Server.MaxConcurrency),
         taskEntries:  make(map[string]cron.EntryID),
+        stoppedTasks: make(map[string]bool),
         shutdownChan: make(chan struct{}),
     }
 }
 
-func (e *Engine) Trigger(repoName, branch string) {
+// Trigger 将仓库流水线提交到运行队列，params 按仓库的参数定义校验并补全默认值
+func (e *Engine) Trigger(repoName, branch string, params map[string]string) (*core.QueuedRun, error) {
+    return e.triggerRepo(repoName, branch, params, nil)
+}
+
+// triggerRepo 提交仓库流水线，trigger 为触发运行的外部事件，指定了提交时检出该提交
+func (e *Engine) triggerRepo(repoName, branch string, params map[string]string, trigger *core.Trigger) (*core.QueuedRun, error) {
     // 查找配置
+    cfg := e.currentConfig()
     var targetRepo config.RepoConfig
     found := false
-    for _, r := range e.cfg.Repos {
+    for _, r := range cfg.Repos {
         if r.Name == repoName {
             targetRepo = r
             found = true
@@ -87,22 +127,46 @@ func (e *Engine) Trigger(repoName, branch string) {
     }
     if !found {
         log.Printf("❌ 未找到仓库配置: %s", repoName)
-        return
+        return nil, fmt.Errorf("未找到仓库配置: %s", repoName)
+    }
+    // 未配置的资源限制使用全局容器策略的上限
+    targetRepo.Container = cfg.ContainerPolicy.Apply(targetRepo.Container)
+    if branch == "" && len(targetRepo.Branches) > 0 {
+        branch = targetRepo.Branches[0]
     }
 
-    log.Printf("⚙️ 触发流水线: %s/%s", repoName, branch)
-    result, err := e.executor.Run(context.Background(), targetRepo, branch)
+    resolved, err := config.ResolveParams(targetRepo.Params, params)
+    if err != nil {
+        log.Printf("❌ 流水线参数无效: %s, 错误: %v", repoName, err)
+        return nil, fmt.Errorf("参数无效: %v", err)
+    }
+
+    run, err := e.queue.Submit(repoName, "repo", targetRepo.Concurrency, func(ctx context.Context) error {
+        return e.runRepo(core.WithTrigger(core.WithParams(ctx, resolved), trigger), targetRepo, branch)
+    })
+    if err != nil {
+        log.Printf("⏭️ 流水线未执行: %s/%s, 原因: %v", repoName, branch, err)
+        return run, err
+    }
+    log.Printf("📥 流水线已加入队列: %s/%s [运行ID: %s]", repoName, branch, run.ID)
+    return run, nil
+}
+
+// runRepo 执行仓库流水线，由运行队列调用
+func (e *Engine) runRepo(ctx context.Context, targetRepo config.RepoConfig, branch string) error {
+    log.Printf("⚙️ 触发流水线: %s/%s", targetRepo.Name, branch)
+    result, err := e.executor.Run(ctx, targetRepo, branch)
 
     if err != nil {
         log.Printf("❌ 流水线失败: %v", err)
         // 兼容旧的AutoAnalyze配置或使用新的AI配置
         if result != nil && e.agent != nil {
             if targetRepo.AutoAnalyze && result.LogFile != "" {
-                e.analyzeFailure(result.LogFile)
+                e.analyzeFailure(ctx, result.LogFile)
             }
             // 使用新的AI配置
             if targetRepo.AI.Enabled {
-                e.invokeAI(targetRepo.AI, result)
+                e.invokeAI(ctx, targetRepo.AI, result)
             }
         }
     } else {
@@ -110,19 +174,27 @@ func (e *Engine) Trigger(repoName, branch string) {
             log.Printf("✅ 流水线成功，任务ID: %s, 日志: %s", result.TaskID, result.LogFile)
             // 即使成功也可能需要AI分析（根据配置）
             if targetRepo.AI.Enabled && e.agent != nil {
-                e.invokeAI(targetRepo.AI, result)
+                e.invokeAI(ctx, targetRepo.AI, result)
             }
         } else {
             log.Printf("✅ 流水线成功")
         }
     }
+    return err
 }
 
-func (e *Engine) TriggerBashTask(taskName string) {
+// TriggerBashTask 将bash任务提交到运行队列，params 按任务的参数定义校验并补全默认值
+func (e *Engine) TriggerBashTask(taskName string, params map[string]string) (*core.QueuedRun, error) {
+    return e.triggerBashTask(taskName, params, nil)
+}
+
+// triggerBashTask 提交bash任务，trigger 为触发运行的外部事件，可以为 nil
+func (e *Engine) triggerBashTask(taskName string, params map[string]string, trigger *core.Trigger) (*core.QueuedRun, error) {
     // 查找bash任务配置
+    cfg := e.currentConfig()
     var targetTask config.BashTaskConfig
     found := false
-    for _, t := range e.cfg.BashTasks {
+    for _, t := range cfg.BashTasks {
         if t.Name == taskName {
             targetTask = t
             found = true
@@ -131,30 +203,44 @@ func (e *Engine) TriggerBashTask(taskName string) {
     }
     if !found {
         log.Printf("❌ 未找到Bash任务配置: %s", taskName)
-        return
+        return nil, fmt.Errorf("未找到Bash任务配置: %s", taskName)
+    }
+    if targetTask.Image != "" {
+        targetTask.Container = cfg.ContainerPolicy.Apply(targetTask.Container)
     }
 
-    e.mu.Lock()
-    e.taskStatus[taskName] = true
-    e.mu.Unlock()
+    resolved, err := config.ResolveParams(targetTask.Params, params)
+    if err != nil {
+        log.Printf("❌ Bash任务参数无效: %s, 错误: %v", taskName, err)
+        return nil, fmt.Errorf("参数无效: %v", err)
+    }
 
-    log.Printf("⚙️ 触发Bash任务: %s", taskName)
-    result, err := e.bashExecutor.RunBashTask(context.Background(), targetTask)
+    run, err := e.queue.Submit(taskName, "bash", targetTask.Concurrency, func(ctx context.Context) error {
+        return e.runBashTask(core.WithTrigger(core.WithParams(ctx, resolved), trigger), targetTask)
+    })
+    if err != nil {
+        log.Printf("⏭️ Bash任务未执行: %s, 原因: %v", taskName, err)
+        return run, err
+    }
+    log.Printf("📥 Bash任务已加入队列: %s [运行ID: %s]", taskName, run.ID)
+    return run, nil
+}
 
-    e.mu.Lock()
-    e.taskStatus[taskName] = false
-    e.mu.Unlock()
+// runBashTask 执行bash任务，由运行队列调用
+func (e *Engine) runBashTask(ctx context.Context, targetTask config.BashTaskConfig) error {
+    log.Printf("⚙️ 触发Bash任务: %s", targetTask.Name)
+    result, err := e.bashExecutor.RunBashTask(ctx, targetTask)
 
     if err != nil {
         log.Printf("❌ Bash任务失败: %v", err)
         // 兼容旧的AutoAnalyze配置或使用新的AI配置
         if result != nil && e.agent != nil {
             if targetTask.AutoAnalyze && result.LogFile != "" {
-                e.analyzeFailure(result.LogFile)
+                e.analyzeFailure(ctx, result.LogFile)
             }
             // 使用新的AI配置
             if targetTask.AI.Enabled {
-                e.invokeAI(targetTask.AI, result)
+                e.invokeAI(ctx, targetTask.AI, result)
             }
         }
     } else {
@@ -162,17 +248,47 @@ func (e *Engine) TriggerBashTask(taskName string) {
             log.Printf("✅ Bash任务成功，任务ID: %s, 日志: %s", result.TaskID, result.LogFile)
             // 即使成功也可能需要AI分析（根据配置）
             if targetTask.AI.Enabled && e.agent != nil {
-                e.invokeAI(targetTask.AI, result)
+                e.invokeAI(ctx, targetTask.AI, result)
             }
         } else {
             log.Printf("✅ Bash任务成功")
         }
     }
+    return err
 }
 
-func (e *Engine) analyzeFailure(logPath string) {
+// runBash 将临时bash任务提交到运行队列并等待其结束
+func (e *Engine) runBash(task config.BashTaskConfig, trigger *core.Trigger) error {
+    run, err := e.submitBash(task, trigger)
+    if err != nil {
+        return err
+    }
+    return run.Wait()
+}
+
+// submitBash 将临时bash任务提交到运行队列，不等待其结束
+func (e *Engine) submitBash(task config.BashTaskConfig, trigger *core.Trigger) (*core.QueuedRun, error) {
+    return e.queue.Submit(task.Name, "bash", core.ConcurrencyAllow, func(ctx context.Context) error {
+        _, err := e.bashExecutor.RunBashTask(core.WithTrigger(ctx, trigger), task)
+        return err
+    })
+}
+
+// CancelRun 取消排队中或正在执行的运行，target 可以是运行ID或任务名称（取消该任务的所有运行）
+func (e *Engine) CancelRun(target string) ([]string, error) {
+    cancelled := e.queue.Cancel(target)
+    if len(cancelled) == 0 {
+        return nil, fmt.Errorf("没有排队中或正在执行的运行: %s", target)
+    }
+    for _, runID := range cancelled {
+        log.Printf("⏹️ 已取消运行: %s", runID)
+    }
+    return cancelled, nil
+}
+
+func (e *Engine) analyzeFailure(ctx context.Context, logPath string) {
     log.Println("🤖 正在请求 AI 分析失败原因...")
-    analysis, err := e.agent.AnalyzeLog(logPath)
+    analysis, err := e.agent.AnalyzeLog(ctx, logPath)
     if err != nil {
         log.Printf("AI 分析失败: %v", err)
         return
@@ -185,39 +301,59 @@ func (e *Engine) analyzeFailure(logPath string) {
 }
 
 // invokeAI 调用AI分析（使用新的AI配置）
-func (e *Engine) invokeAI(aiConfig config.AIConfig, result *core.TaskResult) {
+// 模型、token用量和上下文截断情况记录到运行元数据，分析失败时记录错误信息
+// ctx 为运行的上下文，运行取消时中断分析，已生成的部分保留在输出文件中
+func (e *Engine) invokeAI(ctx context.Context, aiConfig config.AIConfig, result *core.TaskResult) {
+    if ctx.Err() != nil {
+        log.Printf("⏭️ 运行已取消，跳过 AI 分析，任务ID: %s", result.TaskID)
+        return
+    }
     log.Println("🤖 正在调用 AI 分析...")
     
-    err := core.InvokeAI(e.agent, aiConfig, result.TaskDir, result)
+    analysis, err := core.InvokeAI(ctx, e.agent, aiConfig, result.TaskDir, result)
     if err != nil {
         log.Printf("❌ AI 分析失败: %v", err)
+        aiMeta := &metrics.AIMetadata{}
+        if analysis != nil {
+            aiMeta = &analysis.Metadata
+        }
+        aiMeta.Error = err.Error()
+        e.recordAI(result.TaskID, aiMeta)
         return
     }
     
-    log.Printf("✅ AI 分析完成，任务ID: %s", result.TaskID)
+    log.Printf("✅ AI 分析完成，任务ID: %s, 模型: %s, token: %d", result.TaskID, analysis.Metadata.Model, analysis.Metadata.TotalTokens)
+    e.recordAI(result.TaskID, &analysis.Metadata)
+}
+
+// recordAI 将AI分析记录写入运行元数据
+func (e *Engine) recordAI(taskID string, aiMeta *metrics.AIMetadata) {
+    meta, err := e.store.Get(taskID)
+    if err != nil {
+        log.Printf("⚠️ 读取运行元数据失败，AI分析记录未保存: %v", err)
+        return
+    }
+    meta.AI = aiMeta
+    if err := e.store.Save(meta); err != nil {
+        log.Printf("⚠️ 保存AI分析记录失败: %v", err)
+    }
 }
 
 func (e *Engine) StartCron() {
+    e.mu.Lock()
+    defer e.mu.Unlock()
+
     // 全局仓库任务调度
-    e.cron.AddFunc(e.cfg.Schedule, func() {
-        for _, r := range e.cfg.Repos {
-            e.Trigger(r.Name, r.Branches[0])
-        }
-    })
+    e.scheduleRepos()
 
     // Bash任务独立调度
     for _, task := range e.cfg.BashTasks {
         if task.Schedule != "" {
-            taskName := task.Name // 创建局部变量避免闭包问题
-            entryID, err := e.cron.AddFunc(task.Schedule, func() {
-                e.TriggerBashTask(taskName)
-            })
-            if err != nil {
-                log.Printf("❌ 注册Bash任务失败: %s, 错误: %v", taskName, err)
+            if err := e.scheduleBashTask(task); err != nil {
+                log.Printf("❌ 注册Bash任务失败: %s, 错误: %v", task.Name, err)
                 continue
             }
-            e.taskEntries[taskName] = entryID
-            log.Printf("📅 已注册Bash任务: %s (%s) [ID: %d]", taskName, task.Schedule, entryID)
+            log.Printf("📅 已注册Bash任务: %s (%s) [ID: %d]", task.Name, task.Schedule, e.taskEntries[task.Name])
         }
     }
 
@@ -226,6 +362,7 @@ func (e *Engine) StartCron() {
     log.Printf("✅ Cron调度器已启动，共注册 %d 个周期性Bash任务", len(e.taskEntries))
 }
 
+// StopCron 停止调度器并等待正在执行的定时任务，调用方不能持有 e.mu（定时任务触发时需要读取当前配置）
 func (e *Engine) StopCron() {
     if e.cron != nil {
         ctx := e.cron.Stop()
@@ -235,7 +372,9 @@ func (e *Engine) StopCron() {
         case <-time.After(time.Second * 10):
             log.Printf("⚠️ Cron调度器停止超时")
         }
+        e.mu.Lock()
         e.running = false
+        e.mu.Unlock()
     }
 }
 
@@ -252,6 +391,7 @@ func (e *Engine) StopBashTask(taskName string) error {
     // 从cron中移除任务
     e.cron.Remove(entryID)
     delete(e.taskEntries, taskName)
+    e.stoppedTasks[taskName] = true
 
     log.Printf("🛑 已停止周期性Bash任务: %s [ID: %d]", taskName, entryID)
     return nil
@@ -286,30 +426,135 @@ func (e *Engine) StartBashTask(taskName string) error {
     }
 
     // 添加到cron调度
-    entryID, err := e.cron.AddFunc(targetTask.Schedule, func() {
-        e.TriggerBashTask(taskName)
+    if err := e.scheduleBashTask(targetTask); err != nil {
+        return fmt.Errorf("注册Bash任务失败: %v", err)
+    }
+    delete(e.stoppedTasks, taskName)
+
+    log.Printf("📅 已启动周期性Bash任务: %s (%s) [ID: %d]", taskName, targetTask.Schedule, e.taskEntries[taskName])
+    return nil
+}
+
+// scheduleRepos 注册全局仓库调度，调用方需持有 e.mu
+// 触发时读取当时的仓库配置，仓库列表变化无需重新注册
+func (e *Engine) scheduleRepos() {
+    entryID, err := e.cron.AddFunc(e.cfg.Schedule, func() {
+        for _, r := range e.currentConfig().Repos {
+            e.Trigger(r.Name, r.Branches[0], nil)
+        }
     })
     if err != nil {
-        return fmt.Errorf("注册Bash任务失败: %v", err)
+        log.Printf("❌ 注册全局仓库调度失败: %v", err)
+        return
     }
+    e.repoEntry = entryID
+}
 
+// scheduleBashTask 将bash任务注册到cron，调用方需持有 e.mu
+func (e *Engine) scheduleBashTask(task config.BashTaskConfig) error {
+    taskName := task.Name // 创建局部变量避免闭包问题
+    entryID, err := e.cron.AddFunc(task.Schedule, func() {
+        e.TriggerBashTask(taskName, nil)
+    })
+    if err != nil {
+        return err
+    }
     e.taskEntries[taskName] = entryID
-    log.Printf("📅 已启动周期性Bash任务: %s (%s) [ID: %d]", taskName, targetTask.Schedule, entryID)
     return nil
 }
 
+// currentConfig 返回当前生效的配置
+func (e *Engine) currentConfig() config.Config {
+    e.mu.Lock()
+    defer e.mu.Unlock()
+    return e.cfg
+}
+
+// hasBashTask 检查是否配置了指定名称的Bash任务
+func (e *Engine) hasBashTask(name string) bool {
+    for _, task := range e.currentConfig().BashTasks {
+        if task.Name == name {
+            return true
+        }
+    }
+    return false
+}
+
+// repoForEvent 按仓库地址查找与webhook事件匹配的仓库配置，地址都不匹配时按仓库全名或名称匹配
+func (e *Engine) repoForEvent(event *webhook.Event) (config.RepoConfig, bool) {
+    repos := e.currentConfig().Repos
+    for _, r := range repos {
+        if event.MatchRepo(r.URL) {
+            return r, true
+        }
+    }
+    for _, r := range repos {
+        if event.Repo != "" && (r.Name == event.Repo || strings.HasSuffix(event.Repo, "/"+r.Name)) {
+            return r, true
+        }
+    }
+    return config.RepoConfig{}, false
+}
+
+// analysisOutput 读取运行的AI分析结果，任务未启用AI分析或结果文件不存在时返回空字符串
+func (e *Engine) analysisOutput(kind, taskName string, meta *metrics.TaskMetadata) string {
+    var files []string
+    cfg := e.currentConfig()
+    switch kind {
+    case "repo":
+        for _, r := range cfg.Repos {
+            if r.Name == taskName {
+                if r.AI.Enabled {
+                    files = append(files, core.AnalysisFile(r.AI, meta.TaskDir))
+                }
+                if r.AutoAnalyze {
+                    files = append(files, meta.LogFile+".analysis.md")
+                }
+            }
+        }
+    case "bash":
+        for _, t := range cfg.BashTasks {
+            if t.Name == taskName {
+                if t.AI.Enabled {
+                    files = append(files, core.AnalysisFile(t.AI, meta.TaskDir))
+                }
+                if t.AutoAnalyze {
+                    files = append(files, meta.LogFile+".analysis.md")
+                }
+            }
+        }
+    }
+
+    for _, file := range files {
+        if data, err := os.ReadFile(file); err == nil {
+            return string(data)
+        }
+    }
+    return ""
+}
+
+// hasRepo 检查是否配置了指定名称的仓库
+func (e *Engine) hasRepo(name string) bool {
+    for _, repo := range e.currentConfig().Repos {
+        if repo.Name == name {
+            return true
+        }
+    }
+    return false
+}
+
 func (e *Engine) GetTaskStatus(taskName string) map[string]interface{} {
     e.mu.Lock()
     defer e.mu.Unlock()
 
     if taskName != "" {
-        status, exists := e.taskStatus[taskName]
         isScheduled, scheduled := e.taskEntries[taskName]
         return map[string]interface{}{
             "task_name":   taskName,
-            "running":     exists && status,
+            "running":     e.queue.Running(taskName),
             "scheduled":   scheduled,
             "schedule_id": isScheduled,
+            "queue":       e.queue.Snapshot(taskName),
         }
     }
 
@@ -318,8 +563,8 @@ func (e *Engine) GetTaskStatus(taskName string) map[string]interface{} {
     scheduled := make(map[string]bool)
     scheduleIds := make(map[string]int)
 
-    for name, running := range e.taskStatus {
-        status[name] = running
+    for _, task := range e.cfg.BashTasks {
+        status[task.Name] = e.queue.Running(task.Name)
     }
 
     for name, entryID := range e.taskEntries {
@@ -332,31 +577,42 @@ func (e *Engine) GetTaskStatus(taskName string) map[string]interface{} {
         "scheduled":    scheduled,
         "schedule_ids": scheduleIds,
         "cron_running": e.running,
+        "queue":        e.queue.Snapshot(""),
     }
 }
 
-// NewServer 创建新的服务器实例
-func NewServer(cfg *config.Config) *Server {
-    engine := NewEngine(*cfg)
+// NewServer 创建新的服务器实例，configFile 用于重新加载配置
+// 配置中的 secret:// 引用在创建引擎前解析
+func NewServer(configFile string, cfg *config.Config) (*Server, error) {
+    secretManager, err := secrets.NewManager(cfg.Secrets)
+    if err != nil {
+        return nil, fmt.Errorf("初始化密钥管理失败: %v", err)
+    }
+    if err := secretManager.ResolveConfig(cfg); err != nil {
+        return nil, err
+    }
+
+    engine := NewEngine(*cfg, secretManager)
     server := &Server{
-        engine:          engine,
-        cfg:             cfg,
-        oauthProviders:  make(map[string]oauth.Provider),
-        webhookHandlers: make(map[string]*webhook.Handler),
+        engine:      engine,
+        configFile:  configFile,
+        cfg:         cfg,
+        oauthTokens: make(map[string]*oauth.Token),
     }
 
     // 初始化OAuth提供商
-    server.initOAuthProviders()
+    server.oauthProviders = buildOAuthProviders(cfg.OAuth)
 
     // 初始化Webhook处理器
-    server.initWebhookHandlers()
+    server.webhookHandlers = server.buildWebhookHandlers(cfg.Webhooks, server.oauthProviders)
 
-    return server
+    return server, nil
 }
 
-// initOAuthProviders 初始化OAuth提供商
-func (s *Server) initOAuthProviders() {
-    for _, oauthCfg := range s.cfg.OAuth {
+// buildOAuthProviders 根据配置创建OAuth提供商
+func buildOAuthProviders(oauthConfigs []config.OAuthConfig) map[string]oauth.Provider {
+    providers := make(map[string]oauth.Provider)
+    for _, oauthCfg := range oauthConfigs {
         var provider oauth.Provider
 
         switch oauthCfg.Name {
@@ -367,32 +623,86 @@ func (s *Server) initOAuthProviders() {
                 oauthCfg.RedirectURL,
                 oauthCfg.Scopes,
             )
+        case "gitlab":
+            provider = oauth.NewGitLabProvider(
+                oauthCfg.BaseURL,
+                oauthCfg.ClientID,
+                oauthCfg.ClientSecret,
+                oauthCfg.RedirectURL,
+                oauthCfg.Scopes,
+            )
+        case "gitea", "forgejo":
+            provider = oauth.NewGiteaProvider(
+                oauthCfg.BaseURL,
+                oauthCfg.ClientID,
+                oauthCfg.ClientSecret,
+                oauthCfg.RedirectURL,
+                oauthCfg.Scopes,
+            )
+        case "bitbucket":
+            provider = oauth.NewBitbucketProvider(
+                oauthCfg.BaseURL,
+                oauthCfg.ClientID,
+                oauthCfg.ClientSecret,
+                oauthCfg.RedirectURL,
+                oauthCfg.Scopes,
+            )
         default:
             log.Printf("⚠️ 未知的OAuth提供商: %s", oauthCfg.Name)
             continue
         }
 
-        s.oauthProviders[oauthCfg.Name] = provider
+        providers[oauthCfg.Name] = provider
         log.Printf("✅ 已初始化OAuth提供商: %s", oauthCfg.Name)
     }
+    return providers
 }
 
-// initWebhookHandlers 初始化Webhook处理器
-func (s *Server) initWebhookHandlers() {
-    for _, webhookCfg := range s.cfg.Webhooks {
-        provider := s.oauthProviders[webhookCfg.Provider]
+// buildWebhookHandlers 根据配置创建Webhook处理器，按路径索引
+func (s *Server) buildWebhookHandlers(webhooks []config.WebhookConfig, providers map[string]oauth.Provider) map[string]*webhook.Handler {
+    handlers := make(map[string]*webhook.Handler)
+    for _, webhookCfg := range webhooks {
+        provider := providers[webhookCfg.Provider]
 
         handler := webhook.NewHandler(webhookCfg, provider, s.executeWebhookAction)
-        s.webhookHandlers[webhookCfg.Path] = handler
+        handlers[webhookCfg.Path] = handler
 
         log.Printf("✅ 已注册Webhook: %s -> %s", webhookCfg.Path, webhookCfg.Name)
     }
+    return handlers
+}
+
+// currentConfig 返回当前生效的配置
+func (s *Server) currentConfig() *config.Config {
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+    return s.cfg
+}
+
+// oauthProvider 按名称查找OAuth提供商
+func (s *Server) oauthProvider(name string) (oauth.Provider, bool) {
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+    provider, exists := s.oauthProviders[name]
+    return provider, exists
 }
 
 // executeWebhookAction 执行webhook动作
-func (s *Server) executeWebhookAction(ctx context.Context, action config.WebhookAction, payload interface{}) error {
+// 动作的 command、working_dir、env 和 params 以事件payload展开模板，事件信息以 SMARTCI_* 环境变量注入
+func (s *Server) executeWebhookAction(ctx context.Context, action config.WebhookAction, event *webhook.Event) error {
     log.Printf("⚙️ 执行Webhook动作: %s", action.Type)
 
+    action, err := action.Render(event.Payload)
+    if err != nil {
+        return err
+    }
+    trigger := &core.Trigger{
+        Event:   event.Type,
+        Commit:  event.Commit,
+        Env:     event.Env(),
+        Payload: event.Raw,
+    }
+
     switch action.Type {
     case "command":
         // 执行shell命令
@@ -406,13 +716,18 @@ func (s *Server) executeWebhookAction(ctx context.Context, action config.Webhook
             Command:    action.Command,
             WorkingDir: action.WorkingDir,
             Timeout:    action.Timeout,
+            Env:        action.Env,
         }
         if taskCfg.Timeout == 0 {
             taskCfg.Timeout = 300
         }
 
-        _, err := s.engine.bashExecutor.RunBashTask(ctx, taskCfg)
-        return err
+        run, err := s.engine.submitBash(taskCfg, trigger)
+        if err != nil {
+            return err
+        }
+        s.reportRun(event, run)
+        return run.Wait()
 
     case "script":
         // 执行shell脚本
@@ -425,13 +740,18 @@ func (s *Server) executeWebhookAction(ctx context.Context, action config.Webhook
             ScriptFile: action.Script,
             WorkingDir: action.WorkingDir,
             Timeout:    action.Timeout,
+            Env:        action.Env,
         }
         if taskCfg.Timeout == 0 {
             taskCfg.Timeout = 300
         }
 
-        _, err := s.engine.bashExecutor.RunBashTask(ctx, taskCfg)
-        return err
+        run, err := s.engine.submitBash(taskCfg, trigger)
+        if err != nil {
+            return err
+        }
+        s.reportRun(event, run)
+        return run.Wait()
 
     case "task":
         // 执行已配置的任务
@@ -439,7 +759,36 @@ func (s *Server) executeWebhookAction(ctx context.Context, action config.Webhook
             return fmt.Errorf("task类型的action必须指定task字段")
         }
 
-        go s.engine.TriggerBashTask(action.Task)
+        run, err := s.engine.triggerBashTask(action.Task, action.Params, trigger)
+        if err != nil {
+            return err
+        }
+        s.reportRun(event, run)
+        return nil
+
+    case "pipeline":
+        // 执行事件仓库的流水线，检出事件对应的提交
+        if event.Deleted() {
+            log.Printf("⏭️ 分支已删除，跳过流水线: %s", event.Branch)
+            return nil
+        }
+        if event.Branch == "" {
+            return fmt.Errorf("无法从事件中确定分支: %s", event.Type)
+        }
+        repoName := action.Repo
+        if repoName == "" {
+            repo, found := s.engine.repoForEvent(event)
+            if !found {
+                return fmt.Errorf("没有与事件仓库匹配的仓库配置: %s", event.Repo)
+            }
+            repoName = repo.Name
+        }
+
+        run, err := s.engine.triggerRepo(repoName, event.Branch, action.Params, trigger)
+        if err != nil {
+            return err
+        }
+        s.reportRun(event, run)
         return nil
 
     default:
@@ -447,6 +796,158 @@ func (s *Server) executeWebhookAction(ctx context.Context, action config.Webhook
     }
 }
 
+// reportRun 按触发事件的webhook配置向代码托管平台回报运行状态
+// 运行加入队列时回报 pending，结束后回报最终状态；PR事件按配置发布运行结果评论，回报失败只记录日志
+func (s *Server) reportRun(event *webhook.Event, run *core.QueuedRun) {
+    cfg := s.currentConfig()
+    var webhookCfg config.WebhookConfig
+    for _, w := range cfg.Webhooks {
+        if w.Name == event.Webhook {
+            webhookCfg = w
+            break
+        }
+    }
+    report := webhookCfg.Report
+    comment := report.Comment.Enabled && event.Number > 0
+    if !report.Enabled && !comment {
+        return
+    }
+    if event.Repo == "" || event.Commit == "" || event.Deleted() {
+        log.Printf("⏭️ 事件缺少仓库或提交，跳过状态回报: %s", event.Type)
+        return
+    }
+
+    rep, err := s.statusReporter(webhookCfg)
+    if err != nil {
+        log.Printf("⚠️ 无法回报提交状态: %v", err)
+        return
+    }
+
+    prefix := report.Context
+    if prefix == "" {
+        prefix = "smart-ci"
+    }
+    status := reporter.Status{
+        Repo:    event.Repo,
+        Commit:  event.Commit,
+        Context: prefix + "/" + run.TaskName,
+    }
+    if cfg.Server.ExternalURL != "" {
+        status.TargetURL = strings.TrimSuffix(cfg.Server.ExternalURL, "/") + "/api/logs/stream?run_id=" + url.QueryEscape(run.ID)
+    }
+
+    send := func(state reporter.State, description string) {
+        status.State = state
+        status.Description = description
+        if !report.Enabled {
+            return
+        }
+        if err := rep.ReportStatus(context.Background(), status); err != nil {
+            log.Printf("⚠️ %v", err)
+            return
+        }
+        log.Printf("📤 已回报提交状态: %s@%.7s %s [%s]", status.Repo, status.Commit, state, status.Context)
+    }
+
+    go func() {
+        send(reporter.StatePending, "运行已加入队列")
+        run.Wait()
+        switch run.Status {
+        case "success":
+            send(reporter.StateSuccess, fmt.Sprintf("运行成功，耗时 %s", run.EndTime.Sub(run.StartTime).Round(time.Second)))
+        case "cancelled":
+            send(reporter.StateCancelled, "运行已取消")
+        default:
+            send(reporter.StateFailure, "运行失败: "+secrets.Mask(run.Error))
+        }
+        if comment {
+            s.commentRun(rep, report.Comment, event, run, status)
+        }
+    }()
+}
+
+// commentRun 在触发运行的PR上发布或更新运行结果评论，包含失败步骤、日志末尾和AI分析
+// 同一任务在PR上只保留一条评论；only_failure 时运行成功只更新已有评论
+func (s *Server) commentRun(rep reporter.Reporter, cfg config.CommentConfig, event *webhook.Event, run *core.QueuedRun, status reporter.Status) {
+    failed := status.State != reporter.StateSuccess
+    summary := reporter.RunSummary{
+        Context:   status.Context,
+        State:     status.State,
+        RunID:     run.ID,
+        Commit:    event.Commit,
+        Duration:  run.EndTime.Sub(run.StartTime),
+        TargetURL: status.TargetURL,
+    }
+    if failed {
+        summary.Error = run.Error
+    }
+
+    if meta, err := s.engine.store.Get(run.ID); err == nil {
+        logFile := meta.LogFile
+        for _, step := range meta.Steps {
+            if step.Status == "failure" {
+                summary.FailedStep = step.Stage + "/" + step.Name
+                logFile = step.LogFile
+                break
+            }
+        }
+        if failed {
+            lines := cfg.LogLines
+            if lines == 0 {
+                lines = 30
+            }
+            summary.LogTail, _, _ = core.TailLines(logFile, lines)
+        }
+        summary.Analysis = s.engine.analysisOutput(run.Kind, run.TaskName, meta)
+    }
+
+    err := rep.UpsertComment(context.Background(), reporter.Comment{
+        Repo:       event.Repo,
+        Number:     event.Number,
+        Key:        status.Context,
+        Body:       secrets.Mask(reporter.FormatComment(summary)),
+        UpdateOnly: cfg.OnlyFailure && !failed,
+    })
+    if err != nil {
+        log.Printf("⚠️ %v", err)
+        return
+    }
+    log.Printf("💬 已更新PR评论: %s#%d [%s]", event.Repo, event.Number, status.Context)
+}
+
+// statusReporter 创建webhook配置的状态回报器，未配置令牌时使用同名OAuth提供商授权获得的令牌
+func (s *Server) statusReporter(webhookCfg config.WebhookConfig) (reporter.Reporter, error) {
+    report := webhookCfg.Report
+    token := report.Token
+    if token == "" {
+        s.mu.RLock()
+        if t, exists := s.oauthTokens[webhookCfg.Provider]; exists {
+            token = t.AccessToken
+        }
+        s.mu.RUnlock()
+        if token == "" {
+            return nil, fmt.Errorf("webhook %s 未配置 report.token，且尚未通过 /oauth/authorize?provider=%s 授权", webhookCfg.Name, webhookCfg.Provider)
+        }
+    }
+
+    baseURL := report.BaseURL
+    if baseURL == "" {
+        for _, oauthCfg := range s.currentConfig().OAuth {
+            if oauthCfg.Name == webhookCfg.Provider {
+                baseURL = oauthCfg.BaseURL
+                break
+            }
+        }
+    }
+
+    return reporter.New(reporter.Config{
+        Provider: webhookCfg.Provider,
+        BaseURL:  baseURL,
+        Token:    token,
+        API:      report.API,
+    })
+}
+
 // Start 启动服务器
 func (s *Server) Start(host string, port int) error {
     // 创建日志目录
@@ -464,22 +965,36 @@ func (s *Server) Start(host string, port int) error {
     // 注册路由
     s.setupRoutes()
 
+    // 配置文件变更时自动重新加载
+    cfg := s.currentConfig()
+    if cfg.Server.WatchConfig {
+        s.startConfigWatcher()
+    }
+
     log.Printf("🚀 SmartCI服务器启动在 %s", addr)
-    log.Printf("📋 配置文件加载完成，仓库数量: %d, Bash任务数量: %d", len(s.cfg.Repos), len(s.cfg.BashTasks))
+    log.Printf("📋 配置文件加载完成，仓库数量: %d, Bash任务数量: %d", len(cfg.Repos), len(cfg.BashTasks))
 
     return s.server.ListenAndServe()
 }
 
 // Stop 停止服务器
 func (s *Server) Stop() error {
-    s.engine.mu.Lock()
-    defer s.engine.mu.Unlock()
+    s.reloadMu.Lock()
+    defer s.reloadMu.Unlock()
 
     log.Printf("🛑 正在停止SmartCI服务器...")
 
-    // 停止Cron调度器
+    // 停止配置文件监听
+    if s.stopWatch != nil {
+        s.stopWatch()
+    }
+
+    // 停止Cron调度器，等待定时任务期间不持有 engine.mu
     s.engine.StopCron()
 
+    // 丢弃排队中的运行并取消正在执行的运行
+    s.engine.queue.Close()
+
     // 停止HTTP服务器
     var err error
     if s.server != nil {
@@ -489,6 +1004,8 @@ func (s *Server) Stop() error {
     }
 
     // 发送关闭信号给主程序（防止重复关闭）
+    s.engine.mu.Lock()
+    defer s.engine.mu.Unlock()
     if s.engine.shutdownChan != nil {
         select {
         case <-s.engine.shutdownChan:
@@ -506,15 +1023,14 @@ func (s *Server) Stop() error {
 func (s *Server) setupRoutes() {
     // API命令路由
     http.HandleFunc("/api/command", s.handleCommand)
+    http.HandleFunc("/api/logs/stream", s.handleLogStream)
 
     // OAuth路由
     http.HandleFunc("/oauth/authorize", s.handleOAuthAuthorize)
     http.HandleFunc("/oauth/callback", s.handleOAuthCallback)
 
-    // Webhook路由（动态注册）
-    for path, handler := range s.webhookHandlers {
-        http.Handle(path, handler)
-    }
+    // Webhook路由：未匹配其他路由的请求按路径查找当前配置的Webhook，支持热加载
+    http.HandleFunc("/", s.handleConfiguredWebhook)
 
     // 兼容性路由
     http.HandleFunc("/mcp/", s.handleMCP)
@@ -532,12 +1048,9 @@ func (s *Server) handleCommand(w http.ResponseWriter, r *http.Request) {
     }
 
     // 检查认证
-    if s.cfg.Server.AuthToken != "" {
-        authHeader := r.Header.Get("Authorization")
-        if authHeader != "Bearer "+s.cfg.Server.AuthToken {
-            http.Error(w, "Unauthorized", http.StatusUnauthorized)
-            return
-        }
+    if !s.authorized(r) {
+        http.Error(w, "Unauthorized", http.StatusUnauthorized)
+        return
     }
 
     var req APIRequest
@@ -546,13 +1059,134 @@ func (s *Server) handleCommand(w http.ResponseWriter, r *http.Request) {
             Success: false,
             Message: "解析请求失败: " + err.Error(),
         }
-        json.NewEncoder(w).Encode(response)
+        writeJSON(w, response)
         return
     }
 
     response := s.executeCommand(req.Command, req.Args)
     w.Header().Set("Content-Type", "application/json")
-    json.NewEncoder(w).Encode(response)
+    writeJSON(w, response)
+}
+
+// writeJSON 输出JSON响应，响应中已登记的密钥值会被脱敏
+func writeJSON(w http.ResponseWriter, v interface{}) {
+    data, err := json.Marshal(v)
+    if err != nil {
+        http.Error(w, "编码响应失败: "+err.Error(), http.StatusInternalServerError)
+        return
+    }
+    w.Write(secrets.MaskBytes(data))
+    w.Write([]byte("\n"))
+}
+
+// authorized 检查请求的认证令牌
+func (s *Server) authorized(r *http.Request) bool {
+    authToken := s.currentConfig().Server.AuthToken
+    if authToken == "" {
+        return true
+    }
+    return r.Header.Get("Authorization") == "Bearer "+authToken
+}
+
+// handleLogStream 以 SSE 方式推送任务日志，运行中的任务会持续跟随直到结束
+// 参数: task（任务名称或运行ID）、run_id（可选）、lines（初始显示的行数，默认100）
+func (s *Server) handleLogStream(w http.ResponseWriter, r *http.Request) {
+    if !s.authorized(r) {
+        http.Error(w, "Unauthorized", http.StatusUnauthorized)
+        return
+    }
+
+    query := r.URL.Query()
+    run, err := s.resolveRun(query.Get("task"), query.Get("run_id"))
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusNotFound)
+        return
+    }
+
+    lines := 100
+    if v, err := strconv.Atoi(query.Get("lines")); err == nil && v >= 0 {
+        lines = v
+    }
+
+    flusher, ok := w.(http.Flusher)
+    if !ok {
+        http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+        return
+    }
+
+    w.Header().Set("Content-Type", "text/event-stream")
+    w.Header().Set("Cache-Control", "no-cache")
+    w.Header().Set("Connection", "keep-alive")
+
+    stream := &sseWriter{w: w, flusher: flusher}
+    info, _ := json.Marshal(map[string]string{
+        "run_id":    run.TaskID,
+        "task_name": run.TaskName,
+        "status":    run.Status,
+    })
+    stream.event("run", string(info))
+
+    // 日志文件可能尚未创建，此时从头开始跟随
+    var offset int64
+    if content, end, err := core.TailLines(run.LogFile, lines); err == nil {
+        offset = end
+        if content != "" {
+            stream.write([]byte(content + "\n"))
+        }
+    }
+
+    status := run.Status
+    done := func() bool {
+        latest, err := s.engine.store.Get(run.TaskID)
+        if err != nil {
+            return true
+        }
+        status = latest.Status
+        return status != "running"
+    }
+
+    if err := core.FollowLog(r.Context(), run.LogFile, offset, stream.write, done); err != nil {
+        log.Printf("⚠️ 日志跟随结束: %v", err)
+        return
+    }
+    stream.close()
+    stream.event("end", status)
+}
+
+// sseWriter 将日志内容按行转换为 SSE 消息，已登记的密钥值会被脱敏
+type sseWriter struct {
+    w       http.ResponseWriter
+    flusher http.Flusher
+    pending []byte
+}
+
+func (s *sseWriter) write(data []byte) error {
+    s.pending = append(s.pending, data...)
+    for {
+        idx := bytes.IndexByte(s.pending, '\n')
+        if idx < 0 {
+            break
+        }
+        line := secrets.Mask(strings.TrimSuffix(string(s.pending[:idx]), "\r"))
+        if _, err := fmt.Fprintf(s.w, "data: %s\n\n", line); err != nil {
+            return err
+        }
+        s.pending = s.pending[idx+1:]
+    }
+    s.flusher.Flush()
+    return nil
+}
+
+// close 输出末尾未换行的剩余内容
+func (s *sseWriter) close() {
+    if len(s.pending) > 0 {
+        s.write([]byte("\n"))
+    }
+}
+
+func (s *sseWriter) event(name, data string) {
+    fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", name, data)
+    s.flusher.Flush()
 }
 
 // executeCommand 执行命令
@@ -580,10 +1214,32 @@ func (s *Server) executeCommand(command string, args map[string]interface{}) API
                 Message: "缺少任务名称参数",
             }
         }
-        go s.engine.TriggerBashTask(taskName)
+        params, err := paramsArg(args, "params")
+        if err != nil {
+            return APIResponse{
+                Success: false,
+                Message: err.Error(),
+            }
+        }
+        // 名称未匹配Bash任务时按仓库触发流水线，branch 为空时使用仓库的第一个分支
+        var run *core.QueuedRun
+        if branch, _ := args["branch"].(string); !s.engine.hasBashTask(taskName) && s.engine.hasRepo(taskName) {
+            run, err = s.engine.Trigger(taskName, branch, params)
+        } else {
+            run, err = s.engine.TriggerBashTask(taskName, params)
+        }
+        if err != nil {
+            return APIResponse{
+                Success: false,
+                Message: err.Error(),
+            }
+        }
         return APIResponse{
             Success: true,
-            Message: fmt.Sprintf("任务 '%s' 已启动", taskName),
+            Message: fmt.Sprintf("任务 '%s' 已加入运行队列", taskName),
+            Data: map[string]interface{}{
+                "run_id": run.ID,
+            },
         }
     case "start":
         taskName, ok := args["task_name"].(string)
@@ -625,6 +1281,28 @@ func (s *Server) executeCommand(command string, args map[string]interface{}) API
             Success: true,
             Message: fmt.Sprintf("任务 '%s' 的周期性调度已停止", taskName),
         }
+    case "cancel":
+        target, ok := args["task_name"].(string)
+        if !ok {
+            return APIResponse{
+                Success: false,
+                Message: "缺少任务名称或运行ID参数",
+            }
+        }
+        cancelled, err := s.engine.CancelRun(target)
+        if err != nil {
+            return APIResponse{
+                Success: false,
+                Message: err.Error(),
+            }
+        }
+        return APIResponse{
+            Success: true,
+            Message: fmt.Sprintf("已取消 %d 个运行", len(cancelled)),
+            Data: map[string]interface{}{
+                "run_ids": cancelled,
+            },
+        }
     case "status":
         taskName, _ := args["task_name"].(string)
         status := s.engine.GetTaskStatus(taskName)
@@ -634,48 +1312,89 @@ func (s *Server) executeCommand(command string, args map[string]interface{}) API
             Data:    status,
         }
     case "logs":
-        taskName, ok := args["task_name"].(string)
-        if !ok {
+        taskName, _ := args["task_name"].(string)
+        runID, _ := args["run_id"].(string)
+        lines := intArg(args, "lines")
+        if lines <= 0 {
+            lines = 100 // 默认显示100行
+        }
+        run, err := s.resolveRun(taskName, runID)
+        if err != nil {
             return APIResponse{
                 Success: false,
-                Message: "缺少任务名称参数",
+                Message: err.Error(),
             }
         }
-        lines, _ := args["lines"].(int)
-        if lines == 0 {
-            lines = 100 // 默认显示100行
+        content, _, err := core.TailLines(run.LogFile, lines)
+        if err != nil {
+            return APIResponse{
+                Success: false,
+                Message: err.Error(),
+            }
         }
-        // 这里可以实现日志读取逻辑
         return APIResponse{
             Success: true,
-            Message: fmt.Sprintf("显示任务 '%s' 的最近 %d 行日志", taskName, lines),
+            Message: fmt.Sprintf("显示任务 '%s' 的最近 %d 行日志", run.TaskName, lines),
             Data: map[string]interface{}{
-                "task_name": taskName,
+                "task_name": run.TaskName,
+                "run_id":    run.TaskID,
+                "status":    run.Status,
+                "log_file":  run.LogFile,
                 "lines":     lines,
-                "content":   "日志内容待实现...",
+                "content":   content,
             },
         }
     case "config":
+        cfg := s.currentConfig()
         return APIResponse{
             Success: true,
             Message: "配置信息",
             Data: map[string]interface{}{
-                "repos_count":      len(s.cfg.Repos),
-                "bash_tasks_count": len(s.cfg.BashTasks),
-                "schedule":         s.cfg.Schedule,
-                "llm_configured":   s.cfg.LLMKey != "",
-                "server":           s.cfg.Server,
+                "repos_count":      len(cfg.Repos),
+                "bash_tasks_count": len(cfg.BashTasks),
+                "schedule":         cfg.Schedule,
+                "llm_configured":   cfg.LLMSettings().Configured(),
+                "server":           cfg.Server,
             },
         }
     case "reload":
-        // 这里可以实现配置重新加载逻辑
+        changes, err := s.Reload()
+        if err != nil {
+            return APIResponse{
+                Success: false,
+                Message: fmt.Sprintf("配置重新加载失败，继续使用当前配置: %v", err),
+            }
+        }
+        message := "配置已重新加载"
+        if len(changes) == 0 {
+            message = "配置已重新加载，没有变化"
+        }
         return APIResponse{
             Success: true,
-            Message: "配置重新加载功能待实现",
+            Message: message,
+            Data: map[string]interface{}{
+                "changes": changes,
+            },
+        }
+    case "validate":
+        if err := config.ValidateFile(s.configFile); err != nil {
+            messages := validationMessages(err)
+            return APIResponse{
+                Success: false,
+                Message: fmt.Sprintf("配置校验失败:\n  - %s", strings.Join(messages, "\n  - ")),
+                Data: map[string]interface{}{
+                    "errors": messages,
+                },
+            }
+        }
+        return APIResponse{
+            Success: true,
+            Message: fmt.Sprintf("配置校验通过: %s", s.configFile),
         }
     case "list":
-        tasks := make([]string, 0, len(s.cfg.BashTasks))
-        for _, task := range s.cfg.BashTasks {
+        cfg := s.currentConfig()
+        tasks := make([]string, 0, len(cfg.BashTasks))
+        for _, task := range cfg.BashTasks {
             tasks = append(tasks, task.Name)
         }
         return APIResponse{
@@ -683,7 +1402,7 @@ func (s *Server) executeCommand(command string, args map[string]interface{}) API
             Message: "可用任务列表",
             Data: map[string]interface{}{
                 "bash_tasks": tasks,
-                "repos":      getRepoNames(s.cfg.Repos),
+                "repos":      getRepoNames(cfg.Repos),
             },
         }
     case "health":
@@ -705,6 +1424,75 @@ func (s *Server) executeCommand(command string, args map[string]interface{}) API
     }
 }
 
+// resolveRun 查找执行记录：优先按运行ID查找，否则返回任务的最近一次执行
+func (s *Server) resolveRun(taskName, runID string) (*metrics.TaskMetadata, error) {
+    if runID != "" {
+        return s.engine.store.Get(runID)
+    }
+    if taskName == "" {
+        return nil, fmt.Errorf("缺少任务名称参数")
+    }
+    // 兼容直接传入运行ID
+    if run, err := s.engine.store.Get(taskName); err == nil {
+        return run, nil
+    }
+    return metrics.GetLatestExecution(s.engine.store, taskName)
+}
+
+// intArg 读取整数参数（JSON 解码后数字为 float64）
+func intArg(args map[string]interface{}, key string) int {
+    switch v := args[key].(type) {
+    case float64:
+        return int(v)
+    case int:
+        return v
+    case string:
+        n, _ := strconv.Atoi(v)
+        return n
+    }
+    return 0
+}
+
+// paramsArg 读取参数对象，数字和布尔值转换为字符串，由任务的参数定义进一步校验
+func paramsArg(args map[string]interface{}, key string) (map[string]string, error) {
+    raw, exists := args[key]
+    if !exists || raw == nil {
+        return nil, nil
+    }
+    values, ok := raw.(map[string]interface{})
+    if !ok {
+        return nil, fmt.Errorf("%s 必须是对象", key)
+    }
+
+    params := make(map[string]string, len(values))
+    for name, value := range values {
+        switch v := value.(type) {
+        case string:
+            params[name] = v
+        case float64:
+            params[name] = strconv.FormatFloat(v, 'f', -1, 64)
+        case bool:
+            params[name] = strconv.FormatBool(v)
+        default:
+            return nil, fmt.Errorf("参数 %s 的值必须是字符串、数字或布尔值", name)
+        }
+    }
+    return params, nil
+}
+
+// validationMessages 将校验错误拆分为逐条信息
+func validationMessages(err error) []string {
+    errs, ok := err.(config.ValidationErrors)
+    if !ok {
+        return []string{err.Error()}
+    }
+    messages := make([]string, 0, len(errs))
+    for _, e := range errs {
+        messages = append(messages, e.Error())
+    }
+    return messages
+}
+
 func getRepoNames(repos []config.RepoConfig) []string {
     names := make([]string, len(repos))
     for i, repo := range repos {
@@ -726,6 +1514,11 @@ func (s *Server) handleMCP(w http.ResponseWriter, r *http.Request) {
                     "properties": map[string]any{
                         "repo":   map[string]string{"type": "string"},
                         "branch": map[string]string{"type": "string"},
+                        "params": map[string]any{
+                            "type":                 "object",
+                            "description":          "Run parameters defined by the repo's params",
+                            "additionalProperties": map[string]string{"type": "string"},
+                        },
                     },
                 },
             },
@@ -736,6 +1529,11 @@ func (s *Server) handleMCP(w http.ResponseWriter, r *http.Request) {
                     "type": "object",
                     "properties": map[string]any{
                         "task": map[string]string{"type": "string"},
+                        "params": map[string]any{
+                            "type":                 "object",
+                            "description":          "Run parameters defined by the task's params",
+                            "additionalProperties": map[string]string{"type": "string"},
+                        },
                     },
                 },
             },
@@ -757,18 +1555,33 @@ func (s *Server) handleMCP(w http.ResponseWriter, r *http.Request) {
     if r.URL.Path == "/mcp/call" {
         // 执行工具调用
         var req struct {
-            Tool string            `json:"tool"`
-            Args map[string]string `json:"args"`
+            Tool string                 `json:"tool"`
+            Args map[string]interface{} `json:"args"`
         }
         json.NewDecoder(r.Body).Decode(&req)
 
+        params, err := paramsArg(req.Args, "params")
+        if err != nil {
+            http.Error(w, err.Error(), http.StatusBadRequest)
+            return
+        }
+        repo, _ := req.Args["repo"].(string)
+        branch, _ := req.Args["branch"].(string)
+        task, _ := req.Args["task"].(string)
+
         switch req.Tool {
         case "trigger_pipeline":
-            go s.engine.Trigger(req.Args["repo"], req.Args["branch"])
-            fmt.Fprintf(w, "Pipeline triggered for %s", req.Args["repo"])
+            if _, err := s.engine.Trigger(repo, branch, params); err != nil {
+                http.Error(w, err.Error(), http.StatusBadRequest)
+                return
+            }
+            fmt.Fprintf(w, "Pipeline triggered for %s", repo)
         case "trigger_bash_task":
-            go s.engine.TriggerBashTask(req.Args["task"])
-            fmt.Fprintf(w, "Bash task triggered for %s", req.Args["task"])
+            if _, err := s.engine.TriggerBashTask(task, params); err != nil {
+                http.Error(w, err.Error(), http.StatusBadRequest)
+                return
+            }
+            fmt.Fprintf(w, "Bash task triggered for %s", task)
         case "get_build_logs":
             fmt.Fprintf(w, "Logs content...")
         }
@@ -777,15 +1590,21 @@ func (s *Server) handleMCP(w http.ResponseWriter, r *http.Request) {
 
 // handleWebhook 处理webhook请求
 func (s *Server) handleWebhook(w http.ResponseWriter, r *http.Request) {
+    // 兼容性路由没有签名校验，配置了 server.auth_token 时需要携带令牌
+    if !s.authorized(r) {
+        http.Error(w, "Unauthorized", http.StatusUnauthorized)
+        return
+    }
     repo := r.URL.Query().Get("repo")
-    branch := r.URL.Query().Get("branch")
     if repo == "" {
-        repo = "backend-go"
+        http.Error(w, "Missing repo parameter", http.StatusBadRequest)
+        return
     }
-    if branch == "" {
-        branch = "main"
+    // 未指定分支时使用仓库配置的第一个分支
+    if _, err := s.engine.Trigger(repo, r.URL.Query().Get("branch"), nil); err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
     }
-    s.engine.Trigger(repo, branch)
     w.Write([]byte("OK"))
 }
 
@@ -796,21 +1615,22 @@ func (s *Server) handleBashWebhook(w http.ResponseWriter, r *http.Request) {
         http.Error(w, "Missing task parameter", http.StatusBadRequest)
         return
     }
-    s.engine.TriggerBashTask(taskName)
+    s.engine.TriggerBashTask(taskName, nil)
     w.Write([]byte("Bash task triggered"))
 }
 
 // handleConfig 处理配置查看请求
 func (s *Server) handleConfig(w http.ResponseWriter, r *http.Request) {
     w.Header().Set("Content-Type", "application/json")
+    cfg := s.currentConfig()
     summary := map[string]interface{}{
-        "repos_count":      len(s.cfg.Repos),
-        "bash_tasks_count": len(s.cfg.BashTasks),
-        "schedule":         s.cfg.Schedule,
-        "llm_configured":   s.cfg.LLMKey != "",
-        "server":           s.cfg.Server,
+        "repos_count":      len(cfg.Repos),
+        "bash_tasks_count": len(cfg.BashTasks),
+        "schedule":         cfg.Schedule,
+        "llm_configured":   cfg.LLMSettings().Configured(),
+        "server":           cfg.Server,
     }
-    json.NewEncoder(w).Encode(summary)
+    writeJSON(w, summary)
 }
 
 // handleHealth 处理健康检查请求
@@ -833,7 +1653,7 @@ func (s *Server) handleOAuthAuthorize(w http.ResponseWriter, r *http.Request) {
         return
     }
 
-    oauthProvider, exists := s.oauthProviders[provider]
+    oauthProvider, exists := s.oauthProvider(provider)
     if !exists {
         http.Error(w, "Unknown OAuth provider", http.StatusBadRequest)
         return
@@ -858,7 +1678,7 @@ func (s *Server) handleOAuthCallback(w http.ResponseWriter, r *http.Request) {
         return
     }
 
-    oauthProvider, exists := s.oauthProviders[provider]
+    oauthProvider, exists := s.oauthProvider(provider)
     if !exists {
         http.Error(w, "Unknown OAuth provider", http.StatusBadRequest)
         return
@@ -882,6 +1702,12 @@ func (s *Server) handleOAuthCallback(w http.ResponseWriter, r *http.Request) {
 
     log.Printf("✅ OAuth授权成功: %s", provider)
 
+    // 保存令牌，未配置 report.token 的webhook使用它回报提交状态
+    secrets.Register(token.AccessToken)
+    s.mu.Lock()
+    s.oauthTokens[provider] = token
+    s.mu.Unlock()
+
     w.Header().Set("Content-Type", "application/json")
     json.NewEncoder(w).Encode(map[string]interface{}{
         "success":   true,
@@ -899,15 +1725,34 @@ type MCPTool struct {
 }
 
 func main() {
+    // 作为Bash任务的沙箱初始化进程启动时，完成沙箱设置后执行任务命令，不会返回
+    executor.SandboxInit()
+
     // 解析命令行参数
     var (
         configFile = flag.String("config", "config.yaml", "配置文件路径")
         mode       = flag.String("mode", "server", "运行模式: server 或 client")
         host       = flag.String("host", "", "服务器主机地址（覆盖配置文件）")
         port       = flag.Int("port", 0, "服务器端口（覆盖配置文件）")
+        validate   = flag.Bool("validate", false, "校验配置文件后退出")
     )
     flag.Parse()
 
+    // 校验配置文件，存在错误时拒绝启动
+    if _, statErr := os.Stat(*configFile); statErr == nil || *validate {
+        if err := config.ValidateFile(*configFile); err != nil {
+            fmt.Printf("❌ 配置校验失败: %s\n", *configFile)
+            for _, msg := range validationMessages(err) {
+                fmt.Printf("  - %s\n", msg)
+            }
+            os.Exit(1)
+        }
+    }
+    if *validate {
+        fmt.Printf("✅ 配置校验通过: %s\n", *configFile)
+        os.Exit(0)
+    }
+
     // 加载配置
     cfg, err := config.LoadConfig(*configFile)
     if err != nil {
@@ -963,7 +1808,7 @@ func main() {
 
     switch *mode {
     case "server":
-        runServer(cfg)
+        runServer(*configFile, cfg)
     case "client":
         log.Printf("❌ 客户端模式请使用 ./client 可执行文件")
         os.Exit(1)
@@ -973,9 +1818,13 @@ func main() {
     }
 }
 
-func runServer(cfg config.Config) {
+func runServer(configFile string, cfg config.Config) {
     // 创建服务器实例
-    server := NewServer(&cfg)
+    server, err := NewServer(configFile, &cfg)
+    if err != nil {
+        log.Printf("❌ 服务器初始化失败: %v", err)
+        os.Exit(1)
+    }
 
     // 设置信号处理
     sigChan := make(chan os.Signal, 1)
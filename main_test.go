@@ -0,0 +1,42 @@
+package main
+
+import (
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+
+    "lite-cicd/config"
+)
+
+func TestHandleWebhook(t *testing.T) {
+    cfg := config.Config{
+        Server: config.ServerConfig{AuthToken: "test-token"},
+        Repos:  []config.RepoConfig{{Name: "app", URL: "https://example.com/app.git", Branches: []string{"main"}}},
+    }
+    s := &Server{engine: newTestEngine(t, cfg), cfg: &cfg}
+
+    for _, tt := range []struct {
+        name   string
+        query  string
+        token  string
+        status int
+        body   string
+    }{
+        {"缺少令牌", "repo=app", "", http.StatusUnauthorized, "Unauthorized"},
+        {"缺少仓库", "branch=main", "test-token", http.StatusBadRequest, "Missing repo parameter"},
+        {"未配置的仓库", "repo=backend-go", "test-token", http.StatusBadRequest, "未找到仓库配置: backend-go"},
+    } {
+        t.Run(tt.name, func(t *testing.T) {
+            req := httptest.NewRequest(http.MethodGet, "/webhook?"+tt.query, nil)
+            if tt.token != "" {
+                req.Header.Set("Authorization", "Bearer "+tt.token)
+            }
+            rec := httptest.NewRecorder()
+            s.handleWebhook(rec, req)
+            if rec.Code != tt.status || !strings.Contains(rec.Body.String(), tt.body) {
+                t.Errorf("响应不正确: %d %s", rec.Code, rec.Body.String())
+            }
+        })
+    }
+}
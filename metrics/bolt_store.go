@@ -0,0 +1,242 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	bucketRuns        = []byte("runs")         // taskID -> 元数据JSON
+	bucketIndexTime   = []byte("idx_time")     // 开始时间 + taskID
+	bucketIndexTask   = []byte("idx_task")     // 任务名称 + 0x00 + 开始时间 + taskID
+	bucketIndexStatus = []byte("idx_status")   // 执行状态 + 0x00 + 开始时间 + taskID
+	boltBuckets       = [][]byte{bucketRuns, bucketIndexTime, bucketIndexTask, bucketIndexStatus}
+)
+
+// BoltStore 基于 BoltDB 的嵌入式执行记录存储
+// 按任务名称、执行状态和开始时间建立索引；每次操作时打开数据库，
+// 避免长期持有文件锁，使服务器和 smart-ci-metrics 可以同时访问
+type BoltStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+// OpenBoltStore 打开（必要时创建）BoltDB 存储
+func OpenBoltStore(path string) (*BoltStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("创建存储目录失败: %v", err)
+	}
+
+	s := &BoltStore{path: path}
+	err := s.update(func(tx *bolt.Tx) error {
+		for _, name := range boltBuckets {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("初始化存储失败: %v", err)
+	}
+	return s, nil
+}
+
+func (s *BoltStore) update(fn func(tx *bolt.Tx) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	db, err := bolt.Open(s.path, 0644, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return fmt.Errorf("打开存储失败: %v", err)
+	}
+	defer db.Close()
+	return db.Update(fn)
+}
+
+func (s *BoltStore) view(fn func(tx *bolt.Tx) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	db, err := bolt.Open(s.path, 0644, &bolt.Options{Timeout: 5 * time.Second, ReadOnly: true})
+	if err != nil {
+		return fmt.Errorf("打开存储失败: %v", err)
+	}
+	defer db.Close()
+	return db.View(fn)
+}
+
+// Save 保存元数据并更新索引
+func (s *BoltStore) Save(metadata *TaskMetadata) error {
+	if metadata.TaskID == "" {
+		return fmt.Errorf("任务ID不能为空")
+	}
+
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return fmt.Errorf("序列化元数据失败: %v", err)
+	}
+
+	return s.update(func(tx *bolt.Tx) error {
+		runs := tx.Bucket(bucketRuns)
+
+		// 删除旧记录的索引（状态可能从 running 变为 success/failure）
+		if old := runs.Get([]byte(metadata.TaskID)); old != nil {
+			var previous TaskMetadata
+			if err := json.Unmarshal(old, &previous); err == nil {
+				if err := deleteIndexes(tx, &previous); err != nil {
+					return err
+				}
+			}
+		}
+
+		if err := runs.Put([]byte(metadata.TaskID), data); err != nil {
+			return err
+		}
+		return putIndexes(tx, metadata)
+	})
+}
+
+// Get 按任务ID读取元数据
+func (s *BoltStore) Get(taskID string) (*TaskMetadata, error) {
+	var metadata *TaskMetadata
+	err := s.view(func(tx *bolt.Tx) error {
+		data := tx.Bucket(bucketRuns).Get([]byte(taskID))
+		if data == nil {
+			return fmt.Errorf("未找到任务记录: %s", taskID)
+		}
+		metadata = &TaskMetadata{}
+		return json.Unmarshal(data, metadata)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return metadata, nil
+}
+
+// List 通过索引倒序扫描满足条件的记录
+func (s *BoltStore) List(query RunQuery) ([]*TaskMetadata, error) {
+	// 选择最有区分度的索引
+	bucket, prefix := bucketIndexTime, []byte{}
+	if query.TaskName != "" {
+		bucket, prefix = bucketIndexTask, indexPrefix(query.TaskName)
+	} else if query.Status != "" {
+		bucket, prefix = bucketIndexStatus, indexPrefix(query.Status)
+	}
+
+	var result []*TaskMetadata
+	err := s.view(func(tx *bolt.Tx) error {
+		runs := tx.Bucket(bucketRuns)
+		c := tx.Bucket(bucket).Cursor()
+
+		// 定位到时间上限之后的第一个键，再向前遍历
+		upper := append(append([]byte{}, prefix...), encodeTime(query.Until)...)
+		upper = append(upper, 0xff)
+		k, v := c.Seek(upper)
+		if k == nil {
+			k, v = c.Last()
+		} else {
+			k, v = c.Prev()
+		}
+
+		for ; k != nil && bytes.HasPrefix(k, prefix); k, v = c.Prev() {
+			if len(k) < len(prefix)+8 {
+				continue
+			}
+			startTime := decodeTime(k[len(prefix) : len(prefix)+8])
+			if !query.Since.IsZero() && startTime.Before(query.Since) {
+				break
+			}
+
+			data := runs.Get(v)
+			if data == nil {
+				continue
+			}
+			var metadata TaskMetadata
+			if err := json.Unmarshal(data, &metadata); err != nil {
+				continue
+			}
+			if !query.Match(&metadata) {
+				continue
+			}
+
+			result = append(result, &metadata)
+			if query.Limit > 0 && len(result) >= query.Limit {
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Close 每次操作后都会关闭数据库，这里无需处理
+func (s *BoltStore) Close() error {
+	return nil
+}
+
+func putIndexes(tx *bolt.Tx, metadata *TaskMetadata) error {
+	id := []byte(metadata.TaskID)
+	ts := encodeTime(metadata.StartTime)
+
+	if err := tx.Bucket(bucketIndexTime).Put(indexKey(nil, ts, id), id); err != nil {
+		return err
+	}
+	if err := tx.Bucket(bucketIndexTask).Put(indexKey(indexPrefix(metadata.TaskName), ts, id), id); err != nil {
+		return err
+	}
+	return tx.Bucket(bucketIndexStatus).Put(indexKey(indexPrefix(metadata.Status), ts, id), id)
+}
+
+func deleteIndexes(tx *bolt.Tx, metadata *TaskMetadata) error {
+	id := []byte(metadata.TaskID)
+	ts := encodeTime(metadata.StartTime)
+
+	if err := tx.Bucket(bucketIndexTime).Delete(indexKey(nil, ts, id)); err != nil {
+		return err
+	}
+	if err := tx.Bucket(bucketIndexTask).Delete(indexKey(indexPrefix(metadata.TaskName), ts, id)); err != nil {
+		return err
+	}
+	return tx.Bucket(bucketIndexStatus).Delete(indexKey(indexPrefix(metadata.Status), ts, id))
+}
+
+func indexPrefix(value string) []byte {
+	return append([]byte(value), 0x00)
+}
+
+func indexKey(prefix, ts, id []byte) []byte {
+	key := make([]byte, 0, len(prefix)+len(ts)+len(id))
+	key = append(key, prefix...)
+	key = append(key, ts...)
+	return append(key, id...)
+}
+
+// encodeTime 将时间编码为可按字节序比较的8字节大端整数，零值表示最大时间
+func encodeTime(t time.Time) []byte {
+	buf := make([]byte, 8)
+	switch {
+	case t.IsZero():
+		binary.BigEndian.PutUint64(buf, ^uint64(0))
+	case t.Before(time.Unix(0, 0)):
+		binary.BigEndian.PutUint64(buf, 0)
+	default:
+		binary.BigEndian.PutUint64(buf, uint64(t.UnixNano()))
+	}
+	return buf
+}
+
+func decodeTime(buf []byte) time.Time {
+	return time.Unix(0, int64(binary.BigEndian.Uint64(buf)))
+}
@@ -2,6 +2,7 @@ package metrics
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 )
@@ -19,6 +20,78 @@ func FormatDuration(seconds float64) string {
 	}
 }
 
+// FormatParams 按参数名顺序格式化运行参数，如 DRY_RUN=true VERSION=1.2.3
+func FormatParams(params map[string]string) string {
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, name+"="+params[name])
+	}
+	return strings.Join(pairs, " ")
+}
+
+// FormatAI 格式化AI分析记录，如 gpt-3.5-turbo, 1834 tokens（提示 1520 / 生成 314），截断: log(tail)
+func FormatAI(ai *AIMetadata) string {
+	if ai.Error != "" {
+		return "失败: " + ai.Error
+	}
+	text := fmt.Sprintf("%s, %d tokens（提示 %d / 生成 %d）", ai.Model, ai.TotalTokens, ai.PromptTokens, ai.CompletionTokens)
+	var truncated []string
+	for _, file := range ai.Context {
+		if file.Keep != "full" {
+			truncated = append(truncated, fmt.Sprintf("%s(%s)", file.Name, file.Keep))
+		}
+	}
+	if len(truncated) > 0 {
+		text += "，截断: " + strings.Join(truncated, ", ")
+	}
+	return text
+}
+
+// FormatFailureReason 返回失败原因的中文说明，未知原因原样返回
+func FormatFailureReason(reason string) string {
+	switch reason {
+	case FailureClone:
+		return "拉取代码"
+	case FailureBuild:
+		return "构建镜像"
+	case FailureTest:
+		return "测试/命令"
+	case FailureInfra:
+		return "基础设施"
+	default:
+		return reason
+	}
+}
+
+// FormatFailureReasons 按固定顺序格式化失败原因统计，如 测试/命令 3 次, 基础设施 1 次
+func FormatFailureReasons(counts map[string]int) string {
+	var parts []string
+	seen := make(map[string]bool, len(FailureReasons))
+	for _, reason := range FailureReasons {
+		seen[reason] = true
+		if counts[reason] > 0 {
+			parts = append(parts, fmt.Sprintf("%s %d 次", FormatFailureReason(reason), counts[reason]))
+		}
+	}
+	var others []string
+	for reason := range counts {
+		if !seen[reason] {
+			others = append(others, reason)
+		}
+	}
+	sort.Strings(others)
+	for _, reason := range others {
+		parts = append(parts, fmt.Sprintf("%s %d 次", reason, counts[reason]))
+	}
+	return strings.Join(parts, ", ")
+}
+
 // FormatTime 格式化时间
 func FormatTime(t time.Time) string {
 	return t.Format("2006-01-02 15:04:05")
@@ -34,21 +107,36 @@ func DisplayLatestExecution(metadata *TaskMetadata) string {
 	sb.WriteString(fmt.Sprintf("║ 任务名称: %s\n", metadata.TaskName))
 	sb.WriteString(fmt.Sprintf("║ 任务ID: %s\n", metadata.TaskID))
 	sb.WriteString(fmt.Sprintf("║ 任务类型: %s\n", metadata.TaskType))
+	if metadata.Commit != "" {
+		sb.WriteString(fmt.Sprintf("║ 提交: %s\n", metadata.Commit))
+	}
+	if len(metadata.Params) > 0 {
+		sb.WriteString(fmt.Sprintf("║ 运行参数: %s\n", FormatParams(metadata.Params)))
+	}
 	sb.WriteString("╠────────────────────────────────────────────────────────────────\n")
 	sb.WriteString(fmt.Sprintf("║ 开始时间: %s\n", FormatTime(metadata.StartTime)))
 	sb.WriteString(fmt.Sprintf("║ 结束时间: %s\n", FormatTime(metadata.EndTime)))
 	sb.WriteString(fmt.Sprintf("║ 执行时长: %s\n", FormatDuration(metadata.Duration)))
 	sb.WriteString("╠────────────────────────────────────────────────────────────────\n")
 	
-	statusIcon := "✅"
-	if metadata.Status != "success" {
-		statusIcon = "❌"
-	}
-	sb.WriteString(fmt.Sprintf("║ 执行状态: %s %s\n", statusIcon, metadata.Status))
+	sb.WriteString(fmt.Sprintf("║ 执行状态: %s %s\n", StatusIcon(metadata.Status), metadata.Status))
 	
+	if metadata.FailureReason != "" {
+		sb.WriteString(fmt.Sprintf("║ 失败原因: %s\n", FormatFailureReason(metadata.FailureReason)))
+	}
+	if metadata.ExitCode != nil {
+		exitCode := fmt.Sprintf("%d", *metadata.ExitCode)
+		if metadata.OOMKilled {
+			exitCode += "（内存不足被终止）"
+		}
+		sb.WriteString(fmt.Sprintf("║ 退出码: %s\n", exitCode))
+	}
 	if metadata.Error != "" {
 		sb.WriteString(fmt.Sprintf("║ 错误信息: %s\n", metadata.Error))
 	}
+	if metadata.AI != nil {
+		sb.WriteString(fmt.Sprintf("║ AI分析: %s\n", FormatAI(metadata.AI)))
+	}
 	
 	sb.WriteString("╠────────────────────────────────────────────────────────────────\n")
 	sb.WriteString(fmt.Sprintf("║ 任务目录: %s\n", metadata.TaskDir))
@@ -69,10 +157,7 @@ func DisplayExecutionList(executions []*TaskMetadata, taskName string) string {
 	sb.WriteString("╠════════════════════════════════════════════════════════════════\n")
 	
 	for i, exec := range executions {
-		statusIcon := "✅"
-		if exec.Status != "success" {
-			statusIcon = "❌"
-		}
+		statusIcon := StatusIcon(exec.Status)
 		
 		sb.WriteString(fmt.Sprintf("║ %-4d │ %s │ %-9s │ %s  │ %s\n",
 			i+1,
@@ -118,6 +203,12 @@ func DisplayStatistics(stats *TaskStatistics, hours, days int) string {
 	sb.WriteString(fmt.Sprintf("║ 总执行次数: %d 次\n", stats.TotalCount))
 	sb.WriteString(fmt.Sprintf("║ 成功次数: ✅ %d 次\n", stats.SuccessCount))
 	sb.WriteString(fmt.Sprintf("║ 失败次数: ❌ %d 次\n", stats.FailureCount))
+	if len(stats.FailureReasons) > 0 {
+		sb.WriteString(fmt.Sprintf("║ 失败原因: %s\n", FormatFailureReasons(stats.FailureReasons)))
+	}
+	if stats.CancelledCount > 0 {
+		sb.WriteString(fmt.Sprintf("║ 取消次数: ⏹️ %d 次\n", stats.CancelledCount))
+	}
 	sb.WriteString(fmt.Sprintf("║ 成功率: %.2f%%\n", stats.SuccessRate))
 	sb.WriteString("╠────────────────────────────────────────────────────────────────\n")
 	sb.WriteString(fmt.Sprintf("║ 平均执行时长: %s\n", FormatDuration(stats.AvgDuration)))
@@ -126,12 +217,8 @@ func DisplayStatistics(stats *TaskStatistics, hours, days int) string {
 	sb.WriteString("╠────────────────────────────────────────────────────────────────\n")
 	
 	if stats.LastExecution != nil {
-		lastStatusIcon := "✅"
-		if stats.LastExecution.Status != "success" {
-			lastStatusIcon = "❌"
-		}
 		sb.WriteString(fmt.Sprintf("║ 最近一次执行: %s %s\n", 
-			FormatTime(stats.LastExecution.StartTime), lastStatusIcon))
+			FormatTime(stats.LastExecution.StartTime), StatusIcon(stats.LastExecution.Status)))
 	}
 	
 	if stats.FirstExecution != nil {
@@ -164,9 +251,13 @@ func DisplayAllTasksSummary(allMetadata []*TaskMetadata) string {
 		stats := taskStats[taskName]
 		stats.TotalCount++
 		
-		if metadata.Status == "success" {
+		switch metadata.Status {
+		case "success":
 			stats.SuccessCount++
-		} else {
+		case "cancelled":
+			stats.CancelledCount++
+		case "running":
+		default:
 			stats.FailureCount++
 		}
 		
@@ -215,6 +306,20 @@ func DisplayAllTasksSummary(allMetadata []*TaskMetadata) string {
 	return sb.String()
 }
 
+// StatusIcon 返回执行状态对应的图标
+func StatusIcon(status string) string {
+	switch status {
+	case "success":
+		return "✅"
+	case "running":
+		return "🔄"
+	case "cancelled":
+		return "⏹️"
+	default:
+		return "❌"
+	}
+}
+
 // truncateString 截断字符串
 func truncateString(s string, maxLen int) string {
 	if len(s) <= maxLen {
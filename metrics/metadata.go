@@ -18,11 +18,64 @@ type TaskMetadata struct {
 	StartTime  time.Time              `json:"start_time"`   // 开始时间
 	EndTime    time.Time              `json:"end_time"`     // 结束时间
 	Duration   float64                `json:"duration"`     // 执行时长（秒）
-	Status     string                 `json:"status"`       // 执行状态: success/failure
+	Status     string                 `json:"status"`       // 执行状态: running/success/failure/cancelled
 	Error      string                 `json:"error"`        // 错误信息
 	LogFile    string                 `json:"log_file"`     // 日志文件路径
 	TaskDir    string                 `json:"task_dir"`     // 任务目录路径
 	Config     map[string]interface{} `json:"config"`       // 任务配置（可选）
+	Params     map[string]string      `json:"params,omitempty"` // 运行参数
+	Commit     string                 `json:"commit,omitempty"` // 检出的提交SHA（仓库流水线）
+	Steps      []StepMetadata         `json:"steps,omitempty"` // 流水线步骤执行结果
+	AI         *AIMetadata            `json:"ai,omitempty"`    // AI分析记录
+	ExitCode   *int                   `json:"exit_code,omitempty"`      // 命令退出码，命令未执行时为空
+	OOMKilled  bool                   `json:"oom_killed,omitempty"`     // 容器是否因内存不足被终止
+	FailureReason string              `json:"failure_reason,omitempty"` // 失败原因: clone/build/test/infra
+}
+
+// 失败原因，用于区分基础设施问题和任务本身的失败
+const (
+	FailureClone = "clone" // 拉取代码失败
+	FailureBuild = "build" // 构建镜像失败
+	FailureTest  = "test"  // 测试或任务命令失败（退出码非0、超时、内存不足）
+	FailureInfra = "infra" // 基础设施错误：Docker不可用、创建容器失败、密钥注入失败等
+)
+
+// FailureReasons 按显示顺序排列的失败原因
+var FailureReasons = []string{FailureClone, FailureBuild, FailureTest, FailureInfra}
+
+// StepMetadata 流水线步骤执行元数据
+type StepMetadata struct {
+	Stage     string    `json:"stage"`                // 所属阶段
+	Name      string    `json:"name"`                 // 步骤名称
+	Image     string    `json:"image,omitempty"`      // 运行镜像，为空表示宿主机执行
+	Status    string    `json:"status"`               // 执行状态: success/failure/skipped
+	StartTime time.Time `json:"start_time"`           // 开始时间
+	EndTime   time.Time `json:"end_time"`             // 结束时间
+	Duration  float64   `json:"duration"`             // 执行时长（秒）
+	Error     string    `json:"error,omitempty"`      // 错误信息
+	LogFile   string    `json:"log_file"`             // 步骤日志文件路径
+	ExitCode  *int      `json:"exit_code,omitempty"`  // 命令退出码，命令未执行时为空
+	OOMKilled bool      `json:"oom_killed,omitempty"` // 是否因内存不足被终止
+}
+
+// AIMetadata AI分析元数据：使用的模型、token用量和上下文截断情况
+type AIMetadata struct {
+	Model            string          `json:"model"`                 // 使用的模型
+	Budget           int             `json:"budget"`                // 上下文token预算
+	PromptTokens     int             `json:"prompt_tokens"`         // 提示词token数（模型返回）
+	CompletionTokens int             `json:"completion_tokens"`     // 生成内容token数（模型返回）
+	TotalTokens      int             `json:"total_tokens"`          // 总token数（模型返回）
+	Context          []AIContextFile `json:"context,omitempty"`     // 每个上下文文件的保留情况
+	OutputFile       string          `json:"output_file,omitempty"` // 分析结果文件路径
+	Error            string          `json:"error,omitempty"`       // 分析失败时的错误信息
+}
+
+// AIContextFile 上下文文件在提示词中的保留情况，token数为估算值
+type AIContextFile struct {
+	Name       string `json:"name"`        // 上下文名称，log 或文件相对路径
+	Tokens     int    `json:"tokens"`      // 原始内容token数
+	KeptTokens int    `json:"kept_tokens"` // 放入提示词的token数
+	Keep       string `json:"keep"`        // 保留方式: full/tail/head/omitted
 }
 
 // SaveMetadata 保存任务元数据到任务目录
@@ -126,39 +179,32 @@ func FilterMetadataByTimeRange(metadataList []*TaskMetadata, start, end time.Tim
 }
 
 // GetLatestExecution 获取指定任务的最近一次执行记录
-func GetLatestExecution(logDir, taskName string) (*TaskMetadata, error) {
-	allMetadata, err := ListAllMetadata(logDir)
+func GetLatestExecution(store RunStore, taskName string) (*TaskMetadata, error) {
+	executions, err := store.List(RunQuery{TaskName: taskName, Limit: 1})
 	if err != nil {
 		return nil, err
 	}
 
-	filtered := FilterMetadataByTaskName(allMetadata, taskName)
-	if len(filtered) == 0 {
+	if len(executions) == 0 {
 		return nil, fmt.Errorf("未找到任务 '%s' 的执行记录", taskName)
 	}
 
-	return filtered[0], nil
+	return executions[0], nil
 }
 
 // ListExecutions 列出指定任务在时间范围内的执行记录
-func ListExecutions(logDir, taskName string, hours, days int) ([]*TaskMetadata, error) {
-	allMetadata, err := ListAllMetadata(logDir)
-	if err != nil {
-		return nil, err
-	}
-
-	// 过滤任务名称
-	filtered := FilterMetadataByTaskName(allMetadata, taskName)
+func ListExecutions(store RunStore, taskName string, hours, days int) ([]*TaskMetadata, error) {
+	query := RunQuery{TaskName: taskName}
 
-	// 如果指定了时间范围，则进一步过滤
+	// 如果指定了时间范围，则只查询该范围内的记录
 	if hours > 0 || days > 0 {
 		end := time.Now()
 		duration := time.Duration(days*24+hours) * time.Hour
-		start := end.Add(-duration)
-		filtered = FilterMetadataByTimeRange(filtered, start, end)
+		query.Since = end.Add(-duration)
+		query.Until = end
 	}
 
-	return filtered, nil
+	return store.List(query)
 }
 
 // TaskStatistics 任务统计信息
@@ -167,6 +213,8 @@ type TaskStatistics struct {
 	TotalCount     int           `json:"total_count"`     // 总执行次数
 	SuccessCount   int           `json:"success_count"`   // 成功次数
 	FailureCount   int           `json:"failure_count"`   // 失败次数
+	CancelledCount int           `json:"cancelled_count"` // 取消次数
+	FailureReasons map[string]int `json:"failure_reasons,omitempty"` // 按失败原因统计的失败次数
 	SuccessRate    float64       `json:"success_rate"`    // 成功率
 	AvgDuration    float64       `json:"avg_duration"`    // 平均执行时长（秒）
 	MinDuration    float64       `json:"min_duration"`    // 最短执行时长（秒）
@@ -176,8 +224,8 @@ type TaskStatistics struct {
 }
 
 // GetStatistics 获取任务统计信息
-func GetStatistics(logDir, taskName string, hours, days int) (*TaskStatistics, error) {
-	executions, err := ListExecutions(logDir, taskName, hours, days)
+func GetStatistics(store RunStore, taskName string, hours, days int) (*TaskStatistics, error) {
+	executions, err := ListExecutions(store, taskName, hours, days)
 	if err != nil {
 		return nil, err
 	}
@@ -198,10 +246,21 @@ func GetStatistics(logDir, taskName string, hours, days int) (*TaskStatistics, e
 	stats.MaxDuration = executions[0].Duration
 
 	for _, exec := range executions {
-		if exec.Status == "success" {
+		switch exec.Status {
+		case "success":
 			stats.SuccessCount++
-		} else {
+		case "cancelled":
+			stats.CancelledCount++
+		case "running":
+			// 运行中的任务不计入成功或失败
+		default:
 			stats.FailureCount++
+			if exec.FailureReason != "" {
+				if stats.FailureReasons == nil {
+					stats.FailureReasons = make(map[string]int)
+				}
+				stats.FailureReasons[exec.FailureReason]++
+			}
 		}
 
 		totalDuration += exec.Duration
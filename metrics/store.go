@@ -0,0 +1,130 @@
+package metrics
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// RunQuery 执行记录查询条件，零值字段表示不过滤
+type RunQuery struct {
+	TaskName string    // 任务名称
+	Status   string    // 执行状态
+	Since    time.Time // 开始时间下限（包含）
+	Until    time.Time // 开始时间上限（包含）
+	Limit    int       // 最多返回条数，0表示不限制
+}
+
+// RunStore 执行记录存储接口
+// List 返回的记录按开始时间倒序排列
+type RunStore interface {
+	Save(metadata *TaskMetadata) error
+	Get(taskID string) (*TaskMetadata, error)
+	List(query RunQuery) ([]*TaskMetadata, error)
+	Close() error
+}
+
+// 支持的存储类型
+const (
+	StoreTypeJSON = "json" // 每个任务目录下的 metadata.json
+	StoreTypeBolt = "bolt" // 嵌入式 BoltDB 文件，带索引
+)
+
+// OpenStore 根据存储类型打开执行记录存储
+// path 仅对 bolt 存储有效，为空时默认使用 <logDir>/runs.db
+func OpenStore(storeType, path, logDir string) (RunStore, error) {
+	switch storeType {
+	case "", StoreTypeJSON:
+		return NewJSONStore(logDir), nil
+	case StoreTypeBolt:
+		if path == "" {
+			path = filepath.Join(logDir, "runs.db")
+		}
+		return OpenBoltStore(path)
+	default:
+		return nil, fmt.Errorf("未知的存储类型: %s", storeType)
+	}
+}
+
+// Match 判断元数据是否满足查询条件（不考虑 Limit）
+func (q RunQuery) Match(metadata *TaskMetadata) bool {
+	if q.TaskName != "" && metadata.TaskName != q.TaskName {
+		return false
+	}
+	if q.Status != "" && metadata.Status != q.Status {
+		return false
+	}
+	if !q.Since.IsZero() && metadata.StartTime.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && metadata.StartTime.After(q.Until) {
+		return false
+	}
+	return true
+}
+
+// JSONStore 基于任务目录 metadata.json 的存储，每次查询都会扫描日志目录
+type JSONStore struct {
+	logDir string
+}
+
+// NewJSONStore 创建JSON文件存储
+func NewJSONStore(logDir string) *JSONStore {
+	return &JSONStore{logDir: logDir}
+}
+
+// Save 保存元数据到任务目录
+func (s *JSONStore) Save(metadata *TaskMetadata) error {
+	return SaveMetadata(metadata)
+}
+
+// Get 按任务ID读取元数据
+func (s *JSONStore) Get(taskID string) (*TaskMetadata, error) {
+	if taskID == "" || strings.ContainsAny(taskID, `/\`) || taskID == ".." {
+		return nil, fmt.Errorf("无效的任务ID: %s", taskID)
+	}
+	return LoadMetadata(filepath.Join(s.logDir, taskID))
+}
+
+// List 扫描日志目录并按条件过滤
+func (s *JSONStore) List(query RunQuery) ([]*TaskMetadata, error) {
+	allMetadata, err := ListAllMetadata(s.logDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var filtered []*TaskMetadata
+	for _, metadata := range allMetadata {
+		if !query.Match(metadata) {
+			continue
+		}
+		filtered = append(filtered, metadata)
+		if query.Limit > 0 && len(filtered) >= query.Limit {
+			break
+		}
+	}
+	return filtered, nil
+}
+
+// Close JSON存储无需释放资源
+func (s *JSONStore) Close() error {
+	return nil
+}
+
+// ImportLogDir 将日志目录中已有的 metadata.json 导入到指定存储，返回导入条数
+func ImportLogDir(store RunStore, logDir string) (int, error) {
+	allMetadata, err := ListAllMetadata(logDir)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, metadata := range allMetadata {
+		if err := store.Save(metadata); err != nil {
+			return count, fmt.Errorf("导入任务 %s 失败: %v", metadata.TaskID, err)
+		}
+		count++
+	}
+	return count, nil
+}
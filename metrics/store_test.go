@@ -0,0 +1,155 @@
+package metrics
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// seedRuns 在日志目录中写入测试用的执行记录
+func seedRuns(t *testing.T, logDir string) []*TaskMetadata {
+	base := time.Date(2024, 12, 1, 8, 0, 0, 0, time.UTC)
+	var runs []*TaskMetadata
+	for i := 0; i < 6; i++ {
+		taskID := fmt.Sprintf("run-%d", i)
+		taskDir := filepath.Join(logDir, taskID)
+		if err := os.MkdirAll(taskDir, 0755); err != nil {
+			t.Fatalf("创建任务目录失败: %v", err)
+		}
+
+		status := "success"
+		if i%3 == 0 {
+			status = "failure"
+		}
+		taskName := "backup"
+		if i%2 == 1 {
+			taskName = "cleanup"
+		}
+
+		metadata := &TaskMetadata{
+			TaskID:    taskID,
+			TaskName:  taskName,
+			TaskType:  "bash",
+			StartTime: base.Add(time.Duration(i) * time.Hour),
+			EndTime:   base.Add(time.Duration(i)*time.Hour + time.Minute),
+			Duration:  60,
+			Status:    status,
+			TaskDir:   taskDir,
+		}
+		if err := SaveMetadata(metadata); err != nil {
+			t.Fatalf("保存元数据失败: %v", err)
+		}
+		runs = append(runs, metadata)
+	}
+	return runs
+}
+
+func testRunStore(t *testing.T, store RunStore) {
+	base := time.Date(2024, 12, 1, 8, 0, 0, 0, time.UTC)
+
+	all, err := store.List(RunQuery{})
+	if err != nil {
+		t.Fatalf("查询失败: %v", err)
+	}
+	if len(all) != 6 {
+		t.Fatalf("应该有6条记录，实际有%d条", len(all))
+	}
+	if all[0].TaskID != "run-5" || all[5].TaskID != "run-0" {
+		t.Errorf("记录未按开始时间倒序排列: %s ... %s", all[0].TaskID, all[5].TaskID)
+	}
+
+	backups, _ := store.List(RunQuery{TaskName: "backup"})
+	if len(backups) != 3 {
+		t.Errorf("backup 应该有3条记录，实际有%d条", len(backups))
+	}
+
+	failures, _ := store.List(RunQuery{Status: "failure"})
+	if len(failures) != 2 {
+		t.Errorf("failure 应该有2条记录，实际有%d条", len(failures))
+	}
+
+	ranged, _ := store.List(RunQuery{Since: base.Add(2 * time.Hour), Until: base.Add(4 * time.Hour)})
+	if len(ranged) != 3 {
+		t.Errorf("时间范围内应该有3条记录，实际有%d条", len(ranged))
+	}
+
+	latest, _ := store.List(RunQuery{TaskName: "cleanup", Limit: 1})
+	if len(latest) != 1 || latest[0].TaskID != "run-5" {
+		t.Errorf("最近一次 cleanup 记录不正确: %v", latest)
+	}
+
+	// 更新状态后索引应同步更新
+	run, err := store.Get("run-1")
+	if err != nil {
+		t.Fatalf("读取记录失败: %v", err)
+	}
+	run.Status = "failure"
+	if err := store.Save(run); err != nil {
+		t.Fatalf("更新记录失败: %v", err)
+	}
+	failures, _ = store.List(RunQuery{Status: "failure"})
+	if len(failures) != 3 {
+		t.Errorf("更新后 failure 应该有3条记录，实际有%d条", len(failures))
+	}
+	successes, _ := store.List(RunQuery{Status: "success", TaskName: "cleanup"})
+	if len(successes) != 1 {
+		t.Errorf("更新后 cleanup 成功记录应该有1条，实际有%d条", len(successes))
+	}
+}
+
+func TestJSONStore(t *testing.T) {
+	logDir := t.TempDir()
+	seedRuns(t, logDir)
+	testRunStore(t, NewJSONStore(logDir))
+}
+
+func TestBoltStore(t *testing.T) {
+	logDir := t.TempDir()
+	seedRuns(t, logDir)
+
+	store, err := OpenStore(StoreTypeBolt, "", logDir)
+	if err != nil {
+		t.Fatalf("打开bolt存储失败: %v", err)
+	}
+	defer store.Close()
+
+	count, err := ImportLogDir(store, logDir)
+	if err != nil {
+		t.Fatalf("导入执行记录失败: %v", err)
+	}
+	if count != 6 {
+		t.Fatalf("应该导入6条记录，实际导入了%d条", count)
+	}
+
+	testRunStore(t, store)
+}
+
+func TestGetStatistics_FailureReasons(t *testing.T) {
+	logDir := t.TempDir()
+	runs := seedRuns(t, logDir)
+	// run-0、run-3 失败：一次测试失败、一次基础设施问题
+	runs[0].FailureReason = FailureTest
+	runs[3].FailureReason = FailureInfra
+	for _, run := range []*TaskMetadata{runs[0], runs[3]} {
+		if err := SaveMetadata(run); err != nil {
+			t.Fatalf("保存元数据失败: %v", err)
+		}
+	}
+
+	stats, err := GetStatistics(NewJSONStore(logDir), "backup", 0, 0)
+	if err != nil {
+		t.Fatalf("统计失败: %v", err)
+	}
+	if stats.FailureReasons[FailureTest] != 1 || stats.FailureReasons[FailureInfra] != 0 {
+		t.Errorf("backup 的失败原因统计不正确: %v", stats.FailureReasons)
+	}
+	stats, _ = GetStatistics(NewJSONStore(logDir), "cleanup", 0, 0)
+	if stats.FailureReasons[FailureInfra] != 1 {
+		t.Errorf("cleanup 的失败原因统计不正确: %v", stats.FailureReasons)
+	}
+	if text := FormatFailureReasons(map[string]int{FailureInfra: 1, FailureTest: 3}); text != "测试/命令 3 次, 基础设施 1 次" {
+		t.Errorf("失败原因格式化不正确: %s", text)
+	}
+}
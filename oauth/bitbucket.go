@@ -0,0 +1,179 @@
+package oauth
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	bitbucketAuthURL     = "https://bitbucket.org/site/oauth2/authorize"
+	bitbucketTokenURL    = "https://bitbucket.org/site/oauth2/access_token"
+	bitbucketUserInfoURL = "https://api.bitbucket.org/2.0/user"
+)
+
+// BitbucketProvider Bitbucket OAuth提供商，支持 Bitbucket Cloud 和 Bitbucket Server / Data Center
+type BitbucketProvider struct {
+	BaseProvider
+	server bool // 配置了实例地址时为 Bitbucket Server
+}
+
+// BitbucketUser Bitbucket用户信息，Bitbucket Server 只返回用户名
+type BitbucketUser struct {
+	UUID        string `json:"uuid"`
+	AccountID   string `json:"account_id"`
+	Username    string `json:"username"`
+	Nickname    string `json:"nickname"`
+	DisplayName string `json:"display_name"`
+}
+
+// NewBitbucketProvider 创建Bitbucket OAuth提供商
+// baseURL 为空时使用 Bitbucket Cloud，否则为 Bitbucket Server 实例地址
+func NewBitbucketProvider(baseURL, clientID, clientSecret, redirectURL string, scopes []string) *BitbucketProvider {
+	provider := &BitbucketProvider{
+		BaseProvider: BaseProvider{
+			Config: Config{
+				ClientID:     clientID,
+				ClientSecret: clientSecret,
+				RedirectURL:  redirectURL,
+				Scopes:       scopes,
+			},
+			AuthURL:     bitbucketAuthURL,
+			TokenURL:    bitbucketTokenURL,
+			UserInfoURL: bitbucketUserInfoURL,
+		},
+	}
+
+	if baseURL != "" {
+		baseURL = strings.TrimSuffix(baseURL, "/")
+		provider.server = true
+		provider.AuthURL = baseURL + "/rest/oauth2/latest/authorize"
+		provider.TokenURL = baseURL + "/rest/oauth2/latest/token"
+		// Bitbucket Server 没有当前用户的REST接口，whoami 以纯文本返回用户名
+		provider.UserInfoURL = baseURL + "/plugins/servlet/applinks/whoami"
+	}
+
+	return provider
+}
+
+// GetAuthURL 获取Bitbucket授权URL，Bitbucket Cloud 的权限在 OAuth consumer 中配置
+func (b *BitbucketProvider) GetAuthURL(state string) string {
+	params := url.Values{}
+	params.Set("client_id", b.Config.ClientID)
+	params.Set("redirect_uri", b.Config.RedirectURL)
+	params.Set("response_type", "code")
+	params.Set("state", state)
+	if len(b.Config.Scopes) > 0 {
+		params.Set("scope", strings.Join(b.Config.Scopes, " "))
+	}
+
+	return fmt.Sprintf("%s?%s", b.AuthURL, params.Encode())
+}
+
+// ExchangeToken 用授权码换取访问令牌
+func (b *BitbucketProvider) ExchangeToken(ctx context.Context, code string) (*Token, error) {
+	params := url.Values{}
+	params.Set("code", code)
+	params.Set("grant_type", "authorization_code")
+	params.Set("redirect_uri", b.Config.RedirectURL)
+
+	return b.requestToken(ctx, params)
+}
+
+// RefreshToken 刷新访问令牌，Bitbucket Cloud 的访问令牌1小时过期
+func (b *BitbucketProvider) RefreshToken(ctx context.Context, refreshToken string) (*Token, error) {
+	params := url.Values{}
+	params.Set("refresh_token", refreshToken)
+	params.Set("grant_type", "refresh_token")
+
+	return b.requestToken(ctx, params)
+}
+
+// requestToken 请求令牌接口
+// Bitbucket Cloud 要求客户端凭据使用 Basic 认证，Bitbucket Server 使用表单参数
+func (b *BitbucketProvider) requestToken(ctx context.Context, params url.Values) (*Token, error) {
+	if b.server {
+		params.Set("client_id", b.Config.ClientID)
+		params.Set("client_secret", b.Config.ClientSecret)
+		return b.exchangeToken(ctx, b.TokenURL, params)
+	}
+
+	credentials := base64.StdEncoding.EncodeToString([]byte(b.Config.ClientID + ":" + b.Config.ClientSecret))
+	data, err := doRequest(ctx, "POST", b.TokenURL,
+		strings.NewReader(params.Encode()),
+		map[string]string{
+			"Authorization": "Basic " + credentials,
+			"Content-Type":  "application/x-www-form-urlencoded",
+			"Accept":        "application/json",
+		})
+	if err != nil {
+		return nil, fmt.Errorf("获取令牌失败: %w", err)
+	}
+
+	var token Token
+	if err := json.Unmarshal(data, &token); err != nil {
+		return nil, fmt.Errorf("解析令牌失败: %w", err)
+	}
+
+	return &token, nil
+}
+
+// GetUserInfo 获取Bitbucket用户信息
+func (b *BitbucketProvider) GetUserInfo(ctx context.Context, accessToken string) (interface{}, error) {
+	if !b.server {
+		var user BitbucketUser
+		if err := b.getUserInfo(ctx, accessToken, &user); err != nil {
+			return nil, err
+		}
+		return &user, nil
+	}
+
+	data, err := doRequest(ctx, "GET", b.UserInfoURL, nil, map[string]string{
+		"Authorization": "Bearer " + accessToken,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("获取用户信息失败: %w", err)
+	}
+	username := strings.TrimSpace(string(data))
+	if username == "" {
+		return nil, fmt.Errorf("获取用户信息失败: 未登录")
+	}
+	return &BitbucketUser{Username: username, DisplayName: username}, nil
+}
+
+// ValidateWebhook 验证Bitbucket webhook签名
+// Bitbucket Cloud 和 Server 都在 X-Hub-Signature 中发送 sha256=<请求体的HMAC-SHA256十六进制值>
+func (b *BitbucketProvider) ValidateWebhook(r *http.Request, secret string) error {
+	signature := r.Header.Get("X-Hub-Signature")
+	if signature == "" {
+		return fmt.Errorf("缺少webhook签名")
+	}
+	if !strings.HasPrefix(signature, "sha256=") {
+		return fmt.Errorf("不支持的签名算法: %s", strings.SplitN(signature, "=", 2)[0])
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return fmt.Errorf("读取请求体失败: %w", err)
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	expectedMAC := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	if !hmac.Equal([]byte(signature), []byte(expectedMAC)) {
+		return fmt.Errorf("webhook签名验证失败")
+	}
+
+	return nil
+}
@@ -0,0 +1,98 @@
+package oauth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBitbucketProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/site/oauth2/access_token":
+			if id, secret, ok := r.BasicAuth(); !ok || id != "client" || secret != "secret" {
+				http.Error(w, `{"error":"invalid_client"}`, http.StatusUnauthorized)
+				return
+			}
+			r.ParseForm()
+			json.NewEncoder(w).Encode(Token{AccessToken: "cloud-" + r.Form.Get("grant_type")})
+		case "/rest/oauth2/latest/token":
+			r.ParseForm()
+			if r.Form.Get("client_secret") != "secret" {
+				http.Error(w, `{"error":"invalid_client"}`, http.StatusUnauthorized)
+				return
+			}
+			json.NewEncoder(w).Encode(Token{AccessToken: "server-" + r.Form.Get("grant_type")})
+		case "/plugins/servlet/applinks/whoami":
+			if r.Header.Get("Authorization") != "Bearer server-authorization_code" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte("dev\n"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	ctx := context.Background()
+
+	t.Run("Cloud使用Basic认证", func(t *testing.T) {
+		provider := NewBitbucketProvider("", "client", "secret", "http://localhost/oauth/callback", nil)
+		if !strings.HasPrefix(provider.GetAuthURL("state-1"), bitbucketAuthURL+"?") {
+			t.Fatalf("授权URL不正确: %s", provider.GetAuthURL("state-1"))
+		}
+		provider.TokenURL = server.URL + "/site/oauth2/access_token"
+
+		token, err := provider.ExchangeToken(ctx, "code-1")
+		if err != nil || token.AccessToken != "cloud-authorization_code" {
+			t.Fatalf("令牌交换结果不正确: %+v, %v", token, err)
+		}
+		if token, err = provider.RefreshToken(ctx, "refresh-1"); err != nil || token.AccessToken != "cloud-refresh_token" {
+			t.Fatalf("令牌刷新结果不正确: %+v, %v", token, err)
+		}
+	})
+
+	t.Run("Server实例", func(t *testing.T) {
+		provider := NewBitbucketProvider(server.URL+"/", "client", "secret", "http://localhost/oauth/callback", []string{"REPO_READ"})
+		token, err := provider.ExchangeToken(ctx, "code-1")
+		if err != nil || token.AccessToken != "server-authorization_code" {
+			t.Fatalf("令牌交换结果不正确: %+v, %v", token, err)
+		}
+		user, err := provider.GetUserInfo(ctx, token.AccessToken)
+		if err != nil || user.(*BitbucketUser).Username != "dev" {
+			t.Fatalf("用户信息不正确: %+v, %v", user, err)
+		}
+		if _, err := provider.GetUserInfo(ctx, "bad-token"); err == nil {
+			t.Error("无效令牌应该返回错误")
+		}
+	})
+
+	t.Run("webhook签名", func(t *testing.T) {
+		provider := NewBitbucketProvider("", "client", "secret", "", nil)
+		body := `{"push":{"changes":[]}}`
+		mac := hmac.New(sha256.New, []byte("hook-secret"))
+		mac.Write([]byte(body))
+		valid := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+		for signature, ok := range map[string]bool{
+			valid:                        true,
+			"sha256=" + "00" + valid[9:]: false,
+			"sha1=abc":                   false,
+			"":                           false,
+		} {
+			req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(body))
+			if signature != "" {
+				req.Header.Set("X-Hub-Signature", signature)
+			}
+			if err := provider.ValidateWebhook(req, "hook-secret"); (err == nil) != ok {
+				t.Errorf("签名 %q 验证结果不正确: %v", signature, err)
+			}
+		}
+	})
+}
@@ -0,0 +1,127 @@
+package oauth
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// DefaultGiteaURL gitea.com 地址，自建实例和 Forgejo 通过 base_url 配置
+const DefaultGiteaURL = "https://gitea.com"
+
+// GiteaProvider Gitea / Forgejo OAuth提供商
+type GiteaProvider struct {
+	BaseProvider
+}
+
+// GiteaUser Gitea用户信息
+type GiteaUser struct {
+	ID        int    `json:"id"`
+	Login     string `json:"login"`
+	FullName  string `json:"full_name"`
+	Email     string `json:"email"`
+	AvatarURL string `json:"avatar_url"`
+}
+
+// NewGiteaProvider 创建Gitea OAuth提供商，baseURL 为实例地址，为空时使用 gitea.com
+func NewGiteaProvider(baseURL, clientID, clientSecret, redirectURL string, scopes []string) *GiteaProvider {
+	if baseURL == "" {
+		baseURL = DefaultGiteaURL
+	}
+	baseURL = strings.TrimSuffix(baseURL, "/")
+
+	return &GiteaProvider{
+		BaseProvider: BaseProvider{
+			Config: Config{
+				ClientID:     clientID,
+				ClientSecret: clientSecret,
+				RedirectURL:  redirectURL,
+				Scopes:       scopes,
+			},
+			AuthURL:     baseURL + "/login/oauth/authorize",
+			TokenURL:    baseURL + "/login/oauth/access_token",
+			UserInfoURL: baseURL + "/api/v1/user",
+		},
+	}
+}
+
+// GetAuthURL 获取Gitea授权URL
+func (g *GiteaProvider) GetAuthURL(state string) string {
+	params := url.Values{}
+	params.Set("client_id", g.Config.ClientID)
+	params.Set("redirect_uri", g.Config.RedirectURL)
+	params.Set("response_type", "code")
+	params.Set("state", state)
+	if len(g.Config.Scopes) > 0 {
+		params.Set("scope", strings.Join(g.Config.Scopes, " "))
+	}
+
+	return fmt.Sprintf("%s?%s", g.AuthURL, params.Encode())
+}
+
+// ExchangeToken 用授权码换取访问令牌
+func (g *GiteaProvider) ExchangeToken(ctx context.Context, code string) (*Token, error) {
+	params := url.Values{}
+	params.Set("client_id", g.Config.ClientID)
+	params.Set("client_secret", g.Config.ClientSecret)
+	params.Set("code", code)
+	params.Set("grant_type", "authorization_code")
+	params.Set("redirect_uri", g.Config.RedirectURL)
+
+	return g.exchangeToken(ctx, g.TokenURL, params)
+}
+
+// RefreshToken 刷新访问令牌
+func (g *GiteaProvider) RefreshToken(ctx context.Context, refreshToken string) (*Token, error) {
+	params := url.Values{}
+	params.Set("client_id", g.Config.ClientID)
+	params.Set("client_secret", g.Config.ClientSecret)
+	params.Set("refresh_token", refreshToken)
+	params.Set("grant_type", "refresh_token")
+
+	return g.exchangeToken(ctx, g.TokenURL, params)
+}
+
+// GetUserInfo 获取Gitea用户信息
+func (g *GiteaProvider) GetUserInfo(ctx context.Context, accessToken string) (interface{}, error) {
+	var user GiteaUser
+	if err := g.getUserInfo(ctx, accessToken, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// ValidateWebhook 验证Gitea webhook签名
+// X-Gitea-Signature 为请求体的 HMAC-SHA256 十六进制值（不带 sha256= 前缀），Forgejo 另外发送 X-Forgejo-Signature
+func (g *GiteaProvider) ValidateWebhook(r *http.Request, secret string) error {
+	signature := r.Header.Get("X-Gitea-Signature")
+	if signature == "" {
+		signature = r.Header.Get("X-Forgejo-Signature")
+	}
+	if signature == "" {
+		return fmt.Errorf("缺少webhook签名")
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return fmt.Errorf("读取请求体失败: %w", err)
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	expectedMAC := hex.EncodeToString(mac.Sum(nil))
+
+	if !hmac.Equal([]byte(strings.ToLower(signature)), []byte(expectedMAC)) {
+		return fmt.Errorf("webhook签名验证失败")
+	}
+
+	return nil
+}
@@ -0,0 +1,54 @@
+package oauth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGiteaProvider(t *testing.T) {
+	provider := NewGiteaProvider("https://git.example.com/", "client", "secret", "http://localhost/oauth/callback", nil)
+
+	t.Run("实例地址", func(t *testing.T) {
+		if provider.TokenURL != "https://git.example.com/login/oauth/access_token" || provider.UserInfoURL != "https://git.example.com/api/v1/user" {
+			t.Fatalf("实例地址不正确: %s %s", provider.TokenURL, provider.UserInfoURL)
+		}
+		if authURL := provider.GetAuthURL("state-1"); !strings.HasPrefix(authURL, "https://git.example.com/login/oauth/authorize?") {
+			t.Fatalf("授权URL不正确: %s", authURL)
+		}
+	})
+
+	t.Run("webhook签名", func(t *testing.T) {
+		body := `{"ref":"refs/heads/main"}`
+		mac := hmac.New(sha256.New, []byte("hook-secret"))
+		mac.Write([]byte(body))
+		valid := hex.EncodeToString(mac.Sum(nil))
+
+		cases := []struct {
+			header, signature string
+			ok                bool
+		}{
+			{"X-Gitea-Signature", valid, true},
+			{"X-Forgejo-Signature", valid, true},
+			{"X-Gitea-Signature", "sha256=" + valid, false},
+			{"X-Gitea-Signature", "", false},
+		}
+		for _, c := range cases {
+			req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(body))
+			if c.signature != "" {
+				req.Header.Set(c.header, c.signature)
+			}
+			if err := provider.ValidateWebhook(req, "hook-secret"); (err == nil) != c.ok {
+				t.Errorf("%s=%q 验证结果不正确: %v", c.header, c.signature, err)
+			}
+			if data, _ := io.ReadAll(req.Body); c.ok && string(data) != body {
+				t.Errorf("验证后请求体应该可以再次读取，实际: %q", data)
+			}
+		}
+	})
+}
@@ -0,0 +1,110 @@
+package oauth
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// DefaultGitLabURL GitLab.com 地址，自建实例通过 base_url 配置
+const DefaultGitLabURL = "https://gitlab.com"
+
+// GitLabProvider GitLab OAuth提供商，支持自建实例
+type GitLabProvider struct {
+	BaseProvider
+}
+
+// GitLabUser GitLab用户信息
+type GitLabUser struct {
+	ID        int    `json:"id"`
+	Username  string `json:"username"`
+	Name      string `json:"name"`
+	Email     string `json:"email"`
+	AvatarURL string `json:"avatar_url"`
+	WebURL    string `json:"web_url"`
+}
+
+// NewGitLabProvider 创建GitLab OAuth提供商，baseURL 为空时使用 gitlab.com
+func NewGitLabProvider(baseURL, clientID, clientSecret, redirectURL string, scopes []string) *GitLabProvider {
+	if baseURL == "" {
+		baseURL = DefaultGitLabURL
+	}
+	baseURL = strings.TrimSuffix(baseURL, "/")
+
+	return &GitLabProvider{
+		BaseProvider: BaseProvider{
+			Config: Config{
+				ClientID:     clientID,
+				ClientSecret: clientSecret,
+				RedirectURL:  redirectURL,
+				Scopes:       scopes,
+			},
+			AuthURL:     baseURL + "/oauth/authorize",
+			TokenURL:    baseURL + "/oauth/token",
+			UserInfoURL: baseURL + "/api/v4/user",
+		},
+	}
+}
+
+// GetAuthURL 获取GitLab授权URL
+func (g *GitLabProvider) GetAuthURL(state string) string {
+	params := url.Values{}
+	params.Set("client_id", g.Config.ClientID)
+	params.Set("redirect_uri", g.Config.RedirectURL)
+	params.Set("response_type", "code")
+	params.Set("scope", strings.Join(g.Config.Scopes, " "))
+	params.Set("state", state)
+
+	return fmt.Sprintf("%s?%s", g.AuthURL, params.Encode())
+}
+
+// ExchangeToken 用授权码换取访问令牌
+func (g *GitLabProvider) ExchangeToken(ctx context.Context, code string) (*Token, error) {
+	params := url.Values{}
+	params.Set("client_id", g.Config.ClientID)
+	params.Set("client_secret", g.Config.ClientSecret)
+	params.Set("code", code)
+	params.Set("grant_type", "authorization_code")
+	params.Set("redirect_uri", g.Config.RedirectURL)
+
+	return g.exchangeToken(ctx, g.TokenURL, params)
+}
+
+// RefreshToken 刷新访问令牌，GitLab的访问令牌默认2小时过期
+func (g *GitLabProvider) RefreshToken(ctx context.Context, refreshToken string) (*Token, error) {
+	params := url.Values{}
+	params.Set("client_id", g.Config.ClientID)
+	params.Set("client_secret", g.Config.ClientSecret)
+	params.Set("refresh_token", refreshToken)
+	params.Set("grant_type", "refresh_token")
+	params.Set("redirect_uri", g.Config.RedirectURL)
+
+	return g.exchangeToken(ctx, g.TokenURL, params)
+}
+
+// GetUserInfo 获取GitLab用户信息
+func (g *GitLabProvider) GetUserInfo(ctx context.Context, accessToken string) (interface{}, error) {
+	var user GitLabUser
+	if err := g.getUserInfo(ctx, accessToken, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// ValidateWebhook 验证GitLab webhook令牌
+// GitLab 不对请求体签名，而是在 X-Gitlab-Token 中原样发送配置的 Secret token
+func (g *GitLabProvider) ValidateWebhook(r *http.Request, secret string) error {
+	token := r.Header.Get("X-Gitlab-Token")
+	if token == "" {
+		return fmt.Errorf("缺少webhook令牌")
+	}
+
+	if subtle.ConstantTimeCompare([]byte(token), []byte(secret)) != 1 {
+		return fmt.Errorf("webhook令牌验证失败")
+	}
+
+	return nil
+}
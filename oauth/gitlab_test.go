@@ -0,0 +1,98 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGitLabProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/oauth/token":
+			r.ParseForm()
+			if r.Form.Get("client_secret") != "secret" {
+				http.Error(w, `{"error":"invalid_client"}`, http.StatusUnauthorized)
+				return
+			}
+			switch r.Form.Get("grant_type") {
+			case "authorization_code":
+				if r.Form.Get("code") != "code-1" {
+					http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+					return
+				}
+				json.NewEncoder(w).Encode(Token{AccessToken: "access-1", RefreshToken: "refresh-1", ExpiresIn: 7200})
+			case "refresh_token":
+				json.NewEncoder(w).Encode(Token{AccessToken: "access-2", RefreshToken: "refresh-2", ExpiresIn: 7200})
+			}
+		case "/api/v4/user":
+			if r.Header.Get("Authorization") != "Bearer access-1" {
+				http.Error(w, `{"message":"401 Unauthorized"}`, http.StatusUnauthorized)
+				return
+			}
+			json.NewEncoder(w).Encode(GitLabUser{ID: 7, Username: "dev"})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	provider := NewGitLabProvider(server.URL+"/", "client", "secret", "http://localhost/oauth/callback", []string{"read_user", "api"})
+	ctx := context.Background()
+
+	t.Run("授权URL", func(t *testing.T) {
+		authURL := provider.GetAuthURL("state-1")
+		for _, want := range []string{server.URL + "/oauth/authorize?", "response_type=code", "scope=read_user+api", "state=state-1"} {
+			if !strings.Contains(authURL, want) {
+				t.Errorf("授权URL缺少 %q: %s", want, authURL)
+			}
+		}
+	})
+
+	t.Run("令牌交换与刷新", func(t *testing.T) {
+		token, err := provider.ExchangeToken(ctx, "code-1")
+		if err != nil {
+			t.Fatalf("令牌交换失败: %v", err)
+		}
+		if token.AccessToken != "access-1" || token.RefreshToken != "refresh-1" {
+			t.Fatalf("令牌不正确: %+v", token)
+		}
+
+		token, err = provider.RefreshToken(ctx, token.RefreshToken)
+		if err != nil {
+			t.Fatalf("刷新令牌失败: %v", err)
+		}
+		if token.AccessToken != "access-2" {
+			t.Fatalf("刷新后的令牌不正确: %+v", token)
+		}
+
+		if _, err := provider.ExchangeToken(ctx, "bad-code"); err == nil {
+			t.Fatal("无效的授权码应该交换失败")
+		}
+	})
+
+	t.Run("用户信息", func(t *testing.T) {
+		user, err := provider.GetUserInfo(ctx, "access-1")
+		if err != nil {
+			t.Fatalf("获取用户信息失败: %v", err)
+		}
+		if u := user.(*GitLabUser); u.Username != "dev" {
+			t.Fatalf("用户信息不正确: %+v", u)
+		}
+	})
+
+	t.Run("webhook令牌", func(t *testing.T) {
+		for token, valid := range map[string]bool{"hook-secret": true, "wrong": false, "": false} {
+			req := httptest.NewRequest(http.MethodPost, "/webhook", nil)
+			if token != "" {
+				req.Header.Set("X-Gitlab-Token", token)
+			}
+			if err := provider.ValidateWebhook(req, "hook-secret"); (err == nil) != valid {
+				t.Errorf("令牌 %q 验证结果不正确: %v", token, err)
+			}
+		}
+	})
+}
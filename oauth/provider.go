@@ -7,6 +7,7 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"strings"
 )
 
 // Provider OAuth提供商通用接口
@@ -82,15 +83,16 @@ func doRequest(ctx context.Context, method, url string, body io.Reader, headers
 	return data, nil
 }
 
-// exchangeToken 通用令牌交换实现
+// exchangeToken 通用令牌交换实现，以表单提交 params
 func (bp *BaseProvider) exchangeToken(ctx context.Context, tokenURL string, params url.Values) (*Token, error) {
-	data, err := doRequest(ctx, "POST", tokenURL, 
-		nil, // POST form使用params
+	data, err := doRequest(ctx, "POST", tokenURL,
+		strings.NewReader(params.Encode()),
 		map[string]string{
 			"Content-Type": "application/x-www-form-urlencoded",
+			"Accept":       "application/json",
 		})
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("获取令牌失败: %w", err)
 	}
 
 	var token Token
@@ -101,3 +103,17 @@ func (bp *BaseProvider) exchangeToken(ctx context.Context, tokenURL string, para
 	return &token, nil
 }
 
+// getUserInfo 使用访问令牌请求用户信息接口并解析到 user
+func (bp *BaseProvider) getUserInfo(ctx context.Context, accessToken string, user interface{}) error {
+	data, err := doRequest(ctx, "GET", bp.UserInfoURL, nil, map[string]string{
+		"Authorization": "Bearer " + accessToken,
+		"Accept":        "application/json",
+	})
+	if err != nil {
+		return fmt.Errorf("获取用户信息失败: %w", err)
+	}
+	if err := json.Unmarshal(data, user); err != nil {
+		return fmt.Errorf("解析用户信息失败: %w", err)
+	}
+	return nil
+}
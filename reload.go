@@ -0,0 +1,268 @@
+package main
+
+import (
+    "context"
+    "fmt"
+    "log"
+    "net/http"
+    "reflect"
+
+    "lite-cicd/config"
+)
+
+// Reload 重新加载配置文件，校验通过后按差异应用到运行中的服务器
+// 加载或校验失败时返回错误，当前配置保持不变
+func (s *Server) Reload() ([]string, error) {
+    s.reloadMu.Lock()
+    defer s.reloadMu.Unlock()
+
+    // 校验同时确保文件存在：LoadConfig 在文件不存在时返回默认配置，不能用它覆盖当前配置
+    if err := config.ValidateFile(s.configFile); err != nil {
+        return nil, fmt.Errorf("配置校验失败: %v", err)
+    }
+    newCfg, err := config.LoadConfig(s.configFile)
+    if err != nil {
+        return nil, fmt.Errorf("加载配置文件失败: %v", err)
+    }
+    // 使用当前的密钥管理器解析 secret:// 引用，密钥文件的内容变化无需重启即可生效
+    if err := s.engine.secrets.ResolveConfig(&newCfg); err != nil {
+        return nil, err
+    }
+
+    oldCfg := s.currentConfig()
+    var changes []string
+
+    // 监听地址和TLS在运行时无法更换，沿用当前值
+    if newCfg.Server.Host != oldCfg.Server.Host || newCfg.Server.Port != oldCfg.Server.Port ||
+        newCfg.Server.TLS != oldCfg.Server.TLS {
+        changes = append(changes, "服务器监听地址和TLS配置需重启后生效")
+    }
+    newCfg.Server.Host = oldCfg.Server.Host
+    newCfg.Server.Port = oldCfg.Server.Port
+    newCfg.Server.TLS = oldCfg.Server.TLS
+
+    providers := buildOAuthProviders(newCfg.OAuth)
+    handlers := s.buildWebhookHandlers(newCfg.Webhooks, providers)
+    changes = append(changes, diffOAuth(oldCfg.OAuth, newCfg.OAuth)...)
+    changes = append(changes, diffWebhooks(oldCfg.Webhooks, newCfg.Webhooks)...)
+
+    s.mu.Lock()
+    s.cfg = &newCfg
+    s.oauthProviders = providers
+    s.webhookHandlers = handlers
+    s.mu.Unlock()
+
+    changes = append(changes, s.engine.ApplyConfig(newCfg)...)
+
+    // 监听开关变化
+    if newCfg.Server.WatchConfig && s.stopWatch == nil {
+        s.startConfigWatcher()
+    } else if !newCfg.Server.WatchConfig && s.stopWatch != nil {
+        s.stopWatch()
+        s.stopWatch = nil
+    }
+
+    for _, change := range changes {
+        log.Printf("🔄 配置变更: %s", change)
+    }
+    log.Printf("✅ 配置已重新加载: %s", s.configFile)
+    return changes, nil
+}
+
+// startConfigWatcher 启动配置文件监听，文件变化时自动重新加载
+func (s *Server) startConfigWatcher() {
+    ctx, cancel := context.WithCancel(context.Background())
+    s.stopWatch = cancel
+
+    go config.Watch(ctx, s.configFile, config.DefaultWatchInterval, func() {
+        log.Printf("📝 检测到配置文件变化: %s", s.configFile)
+        if _, err := s.Reload(); err != nil {
+            log.Printf("❌ 配置自动重新加载失败，继续使用当前配置: %v", err)
+        }
+    })
+    log.Printf("👀 已开启配置文件监听: %s", s.configFile)
+}
+
+// handleConfiguredWebhook 按请求路径分发到当前配置的Webhook处理器
+func (s *Server) handleConfiguredWebhook(w http.ResponseWriter, r *http.Request) {
+    s.mu.RLock()
+    handler, exists := s.webhookHandlers[r.URL.Path]
+    s.mu.RUnlock()
+
+    if !exists {
+        http.NotFound(w, r)
+        return
+    }
+    handler.ServeHTTP(w, r)
+}
+
+// ApplyConfig 应用新配置：按差异增删或重新调度cron任务，调整运行队列并发数
+// 返回变更说明
+func (e *Engine) ApplyConfig(newCfg config.Config) []string {
+    e.mu.Lock()
+    defer e.mu.Unlock()
+
+    oldCfg := e.cfg
+    e.cfg = newCfg
+    var changes []string
+
+    // 全局仓库调度，仓库列表在触发时读取，只有调度表达式变化时需要重新注册
+    if newCfg.Schedule != oldCfg.Schedule {
+        e.cron.Remove(e.repoEntry)
+        e.scheduleRepos()
+        changes = append(changes, fmt.Sprintf("全局调度: %s -> %s", oldCfg.Schedule, newCfg.Schedule))
+    }
+    changes = append(changes, diffNames("仓库", getRepoNames(oldCfg.Repos), getRepoNames(newCfg.Repos))...)
+
+    oldTasks := make(map[string]config.BashTaskConfig)
+    for _, task := range oldCfg.BashTasks {
+        oldTasks[task.Name] = task
+    }
+    newTasks := make(map[string]config.BashTaskConfig)
+    for _, task := range newCfg.BashTasks {
+        newTasks[task.Name] = task
+    }
+
+    // 已注册调度的任务：删除、取消调度或重新调度
+    for name, entryID := range e.taskEntries {
+        task, exists := newTasks[name]
+        switch {
+        case !exists:
+            e.cron.Remove(entryID)
+            delete(e.taskEntries, name)
+            changes = append(changes, fmt.Sprintf("移除Bash任务: %s", name))
+        case task.Schedule == "":
+            e.cron.Remove(entryID)
+            delete(e.taskEntries, name)
+            changes = append(changes, fmt.Sprintf("取消调度: %s", name))
+        case task.Schedule != oldTasks[name].Schedule:
+            e.cron.Remove(entryID)
+            delete(e.taskEntries, name)
+            if err := e.scheduleBashTask(task); err != nil {
+                changes = append(changes, fmt.Sprintf("重新调度失败: %s, 错误: %v", name, err))
+                continue
+            }
+            changes = append(changes, fmt.Sprintf("重新调度: %s (%s)", name, task.Schedule))
+        }
+    }
+
+    // 新增的任务，以及原来没有调度、新配置了调度的任务；被 stop 命令停止的任务保持停止
+    for _, task := range newCfg.BashTasks {
+        if old, existed := oldTasks[task.Name]; existed {
+            if old.Schedule != "" || task.Schedule == "" || e.stoppedTasks[task.Name] {
+                continue
+            }
+            if err := e.scheduleBashTask(task); err != nil {
+                changes = append(changes, fmt.Sprintf("开始调度失败: %s, 错误: %v", task.Name, err))
+                continue
+            }
+            changes = append(changes, fmt.Sprintf("开始调度: %s (%s)", task.Name, task.Schedule))
+            continue
+        }
+        if task.Schedule == "" {
+            changes = append(changes, fmt.Sprintf("新增Bash任务: %s", task.Name))
+            continue
+        }
+        if err := e.scheduleBashTask(task); err != nil {
+            changes = append(changes, fmt.Sprintf("新增Bash任务调度失败: %s, 错误: %v", task.Name, err))
+            continue
+        }
+        changes = append(changes, fmt.Sprintf("新增Bash任务: %s (%s)", task.Name, task.Schedule))
+    }
+    for name, task := range oldTasks {
+        if _, exists := newTasks[name]; exists {
+            continue
+        }
+        // 已注册调度的任务在上面处理
+        if task.Schedule == "" || e.stoppedTasks[name] {
+            changes = append(changes, fmt.Sprintf("移除Bash任务: %s", name))
+        }
+        delete(e.stoppedTasks, name)
+    }
+
+    if newCfg.Server.MaxConcurrency != oldCfg.Server.MaxConcurrency {
+        e.queue.SetMaxConcurrency(newCfg.Server.MaxConcurrency)
+        changes = append(changes, fmt.Sprintf("最大并发数: %d -> %d", oldCfg.Server.MaxConcurrency, newCfg.Server.MaxConcurrency))
+    }
+
+    // 容器策略在触发运行时读取，之后的运行立即生效
+    if !reflect.DeepEqual(newCfg.ContainerPolicy, oldCfg.ContainerPolicy) {
+        changes = append(changes, "容器策略已更新")
+    }
+
+    // 以下配置在引擎创建时使用，需重启后生效
+    if newCfg.Store != oldCfg.Store {
+        changes = append(changes, "执行记录存储配置需重启后生效")
+    }
+    if !reflect.DeepEqual(newCfg.LLMSettings(), oldCfg.LLMSettings()) {
+        changes = append(changes, "大模型配置需重启后生效")
+    }
+    if !reflect.DeepEqual(newCfg.Secrets, oldCfg.Secrets) {
+        changes = append(changes, "密钥提供者配置需重启后生效")
+    }
+
+    return changes
+}
+
+// diffOAuth 比较OAuth提供商配置
+func diffOAuth(oldConfigs, newConfigs []config.OAuthConfig) []string {
+    oldByName := make(map[string]config.OAuthConfig)
+    var oldNames, newNames []string
+    for _, c := range oldConfigs {
+        oldByName[c.Name] = c
+        oldNames = append(oldNames, c.Name)
+    }
+
+    var changes []string
+    for _, c := range newConfigs {
+        newNames = append(newNames, c.Name)
+        if old, exists := oldByName[c.Name]; exists && !reflect.DeepEqual(old, c) {
+            changes = append(changes, fmt.Sprintf("更新OAuth提供商: %s", c.Name))
+        }
+    }
+    return append(diffNames("OAuth提供商", oldNames, newNames), changes...)
+}
+
+// diffWebhooks 比较Webhook配置，按路径匹配
+func diffWebhooks(oldConfigs, newConfigs []config.WebhookConfig) []string {
+    oldByPath := make(map[string]config.WebhookConfig)
+    var oldPaths, newPaths []string
+    for _, c := range oldConfigs {
+        oldByPath[c.Path] = c
+        oldPaths = append(oldPaths, c.Path)
+    }
+
+    var changes []string
+    for _, c := range newConfigs {
+        newPaths = append(newPaths, c.Path)
+        if old, exists := oldByPath[c.Path]; exists && !reflect.DeepEqual(old, c) {
+            changes = append(changes, fmt.Sprintf("更新Webhook: %s", c.Path))
+        }
+    }
+    return append(diffNames("Webhook", oldPaths, newPaths), changes...)
+}
+
+// diffNames 比较名称列表，返回新增和移除的说明
+func diffNames(kind string, oldNames, newNames []string) []string {
+    oldSet := make(map[string]bool)
+    for _, name := range oldNames {
+        oldSet[name] = true
+    }
+    newSet := make(map[string]bool)
+    for _, name := range newNames {
+        newSet[name] = true
+    }
+
+    var changes []string
+    for _, name := range newNames {
+        if !oldSet[name] {
+            changes = append(changes, fmt.Sprintf("新增%s: %s", kind, name))
+        }
+    }
+    for _, name := range oldNames {
+        if !newSet[name] {
+            changes = append(changes, fmt.Sprintf("移除%s: %s", kind, name))
+        }
+    }
+    return changes
+}
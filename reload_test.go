@@ -0,0 +1,150 @@
+package main
+
+import (
+    "strings"
+    "testing"
+    "time"
+
+    cron "github.com/robfig/cron/v3"
+
+    "lite-cicd/config"
+    "lite-cicd/core"
+)
+
+// newTestEngine 创建只包含调度相关字段的引擎，按配置注册周期性任务但不启动调度器
+func newTestEngine(t *testing.T, cfg config.Config) *Engine {
+    e := &Engine{
+        cfg:          cfg,
+        cron:         cron.New(),
+        taskEntries:  make(map[string]cron.EntryID),
+        stoppedTasks: make(map[string]bool),
+    }
+    for _, task := range cfg.BashTasks {
+        if task.Schedule == "" {
+            continue
+        }
+        if err := e.scheduleBashTask(task); err != nil {
+            t.Fatalf("注册任务失败: %v", err)
+        }
+    }
+    return e
+}
+
+func bashTasks(tasks ...config.BashTaskConfig) config.Config {
+    return config.Config{BashTasks: tasks}
+}
+
+func TestEngineApplyConfig(t *testing.T) {
+    t.Run("新增调度", func(t *testing.T) {
+        e := newTestEngine(t, bashTasks(config.BashTaskConfig{Name: "backup", Command: "true"}))
+        changes := e.ApplyConfig(bashTasks(config.BashTaskConfig{Name: "backup", Command: "true", Schedule: "0 * * * *"}))
+
+        if _, exists := e.taskEntries["backup"]; !exists {
+            t.Fatalf("新配置了调度的任务应该被注册，变更: %v", changes)
+        }
+        if !strings.Contains(strings.Join(changes, "\n"), "开始调度: backup (0 * * * *)") {
+            t.Errorf("变更说明不正确: %v", changes)
+        }
+    })
+
+    t.Run("重新调度", func(t *testing.T) {
+        e := newTestEngine(t, bashTasks(config.BashTaskConfig{Name: "backup", Command: "true", Schedule: "0 * * * *"}))
+        oldEntry := e.taskEntries["backup"]
+        changes := e.ApplyConfig(bashTasks(config.BashTaskConfig{Name: "backup", Command: "true", Schedule: "30 * * * *"}))
+
+        entry, exists := e.taskEntries["backup"]
+        if !exists || entry == oldEntry {
+            t.Fatalf("调度变化的任务应该重新注册，变更: %v", changes)
+        }
+        if len(e.cron.Entries()) != 1 {
+            t.Errorf("旧的调度应该被移除，实际有 %d 个调度", len(e.cron.Entries()))
+        }
+    })
+
+    t.Run("移除和取消调度", func(t *testing.T) {
+        e := newTestEngine(t, bashTasks(
+            config.BashTaskConfig{Name: "backup", Command: "true", Schedule: "0 * * * *"},
+            config.BashTaskConfig{Name: "cleanup", Command: "true", Schedule: "0 0 * * *"},
+        ))
+        changes := e.ApplyConfig(bashTasks(config.BashTaskConfig{Name: "cleanup", Command: "true"}))
+
+        if len(e.taskEntries) != 0 || len(e.cron.Entries()) != 0 {
+            t.Fatalf("所有调度都应该被移除，剩余: %v", e.taskEntries)
+        }
+        text := strings.Join(changes, "\n")
+        if !strings.Contains(text, "移除Bash任务: backup") || !strings.Contains(text, "取消调度: cleanup") {
+            t.Errorf("变更说明不正确: %v", changes)
+        }
+    })
+
+    t.Run("停止的任务保持停止", func(t *testing.T) {
+        e := newTestEngine(t, bashTasks(config.BashTaskConfig{Name: "backup", Command: "true", Schedule: "0 * * * *"}))
+        if err := e.StopBashTask("backup"); err != nil {
+            t.Fatalf("停止任务失败: %v", err)
+        }
+        e.ApplyConfig(bashTasks(config.BashTaskConfig{Name: "backup", Command: "true", Schedule: "30 * * * *"}))
+        if _, exists := e.taskEntries["backup"]; exists {
+            t.Fatalf("被停止的任务不应该在重新加载后恢复调度")
+        }
+
+        // 重新启动后使用新的调度
+        if err := e.StartBashTask("backup"); err != nil {
+            t.Fatalf("启动任务失败: %v", err)
+        }
+        if e.stoppedTasks["backup"] {
+            t.Errorf("启动后不应再记为已停止")
+        }
+    })
+
+    t.Run("移除停止的任务", func(t *testing.T) {
+        e := newTestEngine(t, bashTasks(config.BashTaskConfig{Name: "backup", Command: "true", Schedule: "0 * * * *"}))
+        if err := e.StopBashTask("backup"); err != nil {
+            t.Fatalf("停止任务失败: %v", err)
+        }
+        changes := e.ApplyConfig(bashTasks())
+        if len(e.stoppedTasks) != 0 {
+            t.Errorf("移除的任务不应再记为已停止: %v", e.stoppedTasks)
+        }
+        if !strings.Contains(strings.Join(changes, "\n"), "移除Bash任务: backup") {
+            t.Errorf("变更说明不正确: %v", changes)
+        }
+
+        // 同名任务重新加入配置时正常调度
+        e.ApplyConfig(bashTasks(config.BashTaskConfig{Name: "backup", Command: "true", Schedule: "0 * * * *"}))
+        if _, exists := e.taskEntries["backup"]; !exists {
+            t.Errorf("重新加入的任务应该被调度")
+        }
+    })
+}
+
+func TestServerStop_WaitsForCronJob(t *testing.T) {
+    e := newTestEngine(t, config.Config{})
+    e.queue = core.NewRunQueue(1)
+    started := make(chan struct{})
+    finished := make(chan struct{})
+    // 定时任务触发时读取当前配置，需要获取 e.mu
+    if _, err := e.cron.AddFunc("@every 1s", func() {
+        close(started)
+        time.Sleep(100 * time.Millisecond)
+        e.currentConfig()
+        close(finished)
+    }); err != nil {
+        t.Fatalf("注册任务失败: %v", err)
+    }
+    e.cron.Start()
+    <-started
+
+    s := &Server{engine: e}
+    done := make(chan error, 1)
+    go func() { done <- s.Stop() }()
+    select {
+    case <-done:
+    case <-time.After(5 * time.Second):
+        t.Fatalf("停止服务器时被正在执行的定时任务阻塞")
+    }
+    select {
+    case <-finished:
+    default:
+        t.Errorf("停止服务器前定时任务应该已经完成")
+    }
+}
@@ -0,0 +1,79 @@
+package reporter
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// 评论正文的最大长度，GitHub 限制为65536个字符
+const maxCommentBody = 60000
+
+// Comment PR上的置顶评论，同一 Key 的评论只保留一条，再次发布时更新已有评论
+type Comment struct {
+	Repo       string // 仓库全名，GitLab 为项目路径
+	Number     int    // PR编号，GitLab 为合并请求的 iid
+	Key        string // 评论标识，以隐藏标记写在评论开头
+	Body       string // Markdown 正文
+	UpdateOnly bool   // 只更新已有评论，不存在时不创建
+}
+
+// marker 评论开头的隐藏标记，用于查找已有评论
+func (c Comment) marker() string {
+	return fmt.Sprintf("<!-- smart-ci:%s -->", c.Key)
+}
+
+// content 带隐藏标记的评论正文
+func (c Comment) content() string {
+	return c.marker() + "\n" + truncate(c.Body, maxCommentBody)
+}
+
+// RunSummary 评论中展示的运行结果
+type RunSummary struct {
+	Context    string        // 状态名称
+	State      State         // 最终状态
+	RunID      string        // 运行ID
+	Commit     string        // 提交SHA
+	Duration   time.Duration // 运行耗时
+	TargetURL  string        // 运行详情链接，可以为空
+	FailedStep string        // 失败的流水线步骤，如 test/unit
+	Error      string        // 错误信息
+	LogTail    string        // 日志末尾
+	Analysis   string        // AI分析结果
+}
+
+// FormatComment 将运行结果格式化为评论正文
+func FormatComment(s RunSummary) string {
+	var b strings.Builder
+
+	title := map[State]string{
+		StateSuccess:   "✅ %s 运行成功",
+		StateFailure:   "❌ %s 运行失败",
+		StateCancelled: "⏹️ %s 运行已取消",
+	}[s.State]
+	if title == "" {
+		title = "⏳ %s 运行中"
+	}
+	fmt.Fprintf(&b, "### "+title+"\n\n", s.Context)
+
+	runID := "`" + s.RunID + "`"
+	if s.TargetURL != "" {
+		runID = fmt.Sprintf("[%s](%s)", s.RunID, s.TargetURL)
+	}
+	fmt.Fprintf(&b, "| 运行ID | 提交 | 耗时 |\n|--------|------|------|\n| %s | `%.7s` | %s |\n", runID, s.Commit, s.Duration.Round(time.Second))
+
+	if s.FailedStep != "" {
+		fmt.Fprintf(&b, "\n**失败步骤:** `%s`\n", s.FailedStep)
+	}
+	if s.Error != "" {
+		fmt.Fprintf(&b, "\n**错误:** %s\n", strings.TrimSpace(s.Error))
+	}
+	if s.LogTail != "" {
+		lines := strings.Count(s.LogTail, "\n") + 1
+		fmt.Fprintf(&b, "\n<details><summary>日志末尾 %d 行</summary>\n\n````\n%s\n````\n\n</details>\n", lines, s.LogTail)
+	}
+	if s.Analysis != "" {
+		fmt.Fprintf(&b, "\n#### 🤖 AI 分析\n\n%s\n", strings.TrimSpace(s.Analysis))
+	}
+	return b.String()
+}
@@ -0,0 +1,56 @@
+package reporter
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// DefaultGiteaURL gitea.com 地址
+const DefaultGiteaURL = "https://gitea.com"
+
+// giteaReporter 通过 Commit Status API 回报 Gitea / Forgejo 提交状态
+type giteaReporter struct {
+	baseURL string
+	client  *apiClient
+}
+
+func newGiteaReporter(baseURL string, client *apiClient) *giteaReporter {
+	if baseURL == "" {
+		baseURL = DefaultGiteaURL
+	}
+	return &giteaReporter{baseURL: strings.TrimSuffix(baseURL, "/"), client: client}
+}
+
+// ReportStatus 回报提交状态，Gitea 没有取消状态，使用 error
+func (g *giteaReporter) ReportStatus(ctx context.Context, status Status) error {
+	state := string(status.State)
+	if status.State == StateCancelled {
+		state = "error"
+	}
+	endpoint := fmt.Sprintf("%s/api/v1/repos/%s/statuses/%s", g.baseURL, status.Repo, status.Commit)
+	body := map[string]string{
+		"state":       state,
+		"context":     status.Context,
+		"description": truncate(status.Description, maxDescription),
+	}
+	if status.TargetURL != "" {
+		body["target_url"] = status.TargetURL
+	}
+	err := g.client.do(ctx, "POST", endpoint, body, nil)
+	if err != nil {
+		return fmt.Errorf("回报Gitea提交状态失败: %w", err)
+	}
+	return nil
+}
+
+// UpsertComment 发布或更新PR评论，PR评论使用 Issues 接口
+func (g *giteaReporter) UpsertComment(ctx context.Context, comment Comment) error {
+	repoURL := fmt.Sprintf("%s/api/v1/repos/%s", g.baseURL, comment.Repo)
+	return g.client.upsertComment(ctx, commentAPI{
+		list:         fmt.Sprintf("%s/issues/%d/comments", repoURL, comment.Number),
+		create:       fmt.Sprintf("%s/issues/%d/comments", repoURL, comment.Number),
+		update:       func(id int64) string { return fmt.Sprintf("%s/issues/comments/%d", repoURL, id) },
+		updateMethod: "PATCH",
+	}, comment)
+}
@@ -0,0 +1,129 @@
+package reporter
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultGitHubAPI GitHub API 地址，GitHub Enterprise 为 https://<host>/api/v3
+const DefaultGitHubAPI = "https://api.github.com"
+
+// githubReporter 通过 Statuses API 或 Checks API 回报GitHub提交状态
+type githubReporter struct {
+	baseURL string
+	checks  bool
+	client  *apiClient
+
+	mu        sync.Mutex
+	checkRuns map[string]int64 // 仓库、提交和名称对应的 check run ID，结束时更新同一个 check run
+}
+
+func newGitHubReporter(baseURL, api string, client *apiClient) (*githubReporter, error) {
+	if baseURL == "" {
+		baseURL = DefaultGitHubAPI
+	}
+	if api != "" && api != "statuses" && api != "checks" {
+		return nil, fmt.Errorf("未知的GitHub接口 %q，可选: statuses, checks", api)
+	}
+	client.headers = map[string]string{
+		"Accept":               "application/vnd.github+json",
+		"X-GitHub-Api-Version": "2022-11-28",
+	}
+	return &githubReporter{
+		baseURL:   strings.TrimSuffix(baseURL, "/"),
+		checks:    api == "checks",
+		client:    client,
+		checkRuns: make(map[string]int64),
+	}, nil
+}
+
+// ReportStatus 回报提交状态
+func (g *githubReporter) ReportStatus(ctx context.Context, status Status) error {
+	if g.checks {
+		return g.reportCheck(ctx, status)
+	}
+
+	// Statuses API 没有取消状态，使用 error
+	state := string(status.State)
+	if status.State == StateCancelled {
+		state = "error"
+	}
+	endpoint := fmt.Sprintf("%s/repos/%s/statuses/%s", g.baseURL, status.Repo, status.Commit)
+	body := map[string]string{
+		"state":       state,
+		"context":     status.Context,
+		"description": truncate(status.Description, maxDescription),
+	}
+	if status.TargetURL != "" {
+		body["target_url"] = status.TargetURL
+	}
+	err := g.client.do(ctx, "POST", endpoint, body, nil)
+	if err != nil {
+		return fmt.Errorf("回报GitHub提交状态失败: %w", err)
+	}
+	return nil
+}
+
+// UpsertComment 发布或更新PR评论，PR评论使用 Issues 接口
+func (g *githubReporter) UpsertComment(ctx context.Context, comment Comment) error {
+	repoURL := fmt.Sprintf("%s/repos/%s", g.baseURL, comment.Repo)
+	return g.client.upsertComment(ctx, commentAPI{
+		list:         fmt.Sprintf("%s/issues/%d/comments?per_page=100", repoURL, comment.Number),
+		create:       fmt.Sprintf("%s/issues/%d/comments", repoURL, comment.Number),
+		update:       func(id int64) string { return fmt.Sprintf("%s/issues/comments/%d", repoURL, id) },
+		updateMethod: "PATCH",
+	}, comment)
+}
+
+// reportCheck 通过 Checks API 回报，pending 时创建 check run，结束时更新为 completed
+// Checks API 只接受 GitHub App 的安装令牌
+func (g *githubReporter) reportCheck(ctx context.Context, status Status) error {
+	key := status.Repo + "@" + status.Commit + "/" + status.Context
+	body := map[string]interface{}{
+		"name":     status.Context,
+		"head_sha": status.Commit,
+		"output": map[string]string{
+			"title":   truncate(status.Description, maxDescription),
+			"summary": status.Description,
+		},
+	}
+	if status.TargetURL != "" {
+		body["details_url"] = status.TargetURL
+	}
+	if status.State == StatePending {
+		body["status"] = "in_progress"
+	} else {
+		body["status"] = "completed"
+		body["conclusion"] = string(status.State)
+		body["completed_at"] = time.Now().UTC().Format(time.RFC3339)
+	}
+
+	g.mu.Lock()
+	id, exists := g.checkRuns[key]
+	g.mu.Unlock()
+
+	var run struct {
+		ID int64 `json:"id"`
+	}
+	var err error
+	if exists {
+		err = g.client.do(ctx, "PATCH", fmt.Sprintf("%s/repos/%s/check-runs/%d", g.baseURL, status.Repo, id), body, &run)
+	} else {
+		err = g.client.do(ctx, "POST", fmt.Sprintf("%s/repos/%s/check-runs", g.baseURL, status.Repo), body, &run)
+	}
+	if err != nil {
+		return fmt.Errorf("回报GitHub检查失败: %w", err)
+	}
+
+	g.mu.Lock()
+	if status.State == StatePending {
+		g.checkRuns[key] = run.ID
+	} else {
+		delete(g.checkRuns, key)
+	}
+	g.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,61 @@
+package reporter
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// DefaultGitLabURL GitLab.com 地址
+const DefaultGitLabURL = "https://gitlab.com"
+
+// gitlabStates 提交状态对应的GitLab状态
+var gitlabStates = map[State]string{
+	StatePending:   "pending",
+	StateSuccess:   "success",
+	StateFailure:   "failed",
+	StateCancelled: "canceled",
+}
+
+// gitlabReporter 通过 Commit Status API 回报GitLab提交状态
+type gitlabReporter struct {
+	baseURL string
+	client  *apiClient
+}
+
+func newGitLabReporter(baseURL string, client *apiClient) *gitlabReporter {
+	if baseURL == "" {
+		baseURL = DefaultGitLabURL
+	}
+	return &gitlabReporter{baseURL: strings.TrimSuffix(baseURL, "/"), client: client}
+}
+
+// ReportStatus 回报提交状态，项目使用 URL 编码的完整路径
+func (g *gitlabReporter) ReportStatus(ctx context.Context, status Status) error {
+	endpoint := fmt.Sprintf("%s/api/v4/projects/%s/statuses/%s", g.baseURL, url.PathEscape(status.Repo), status.Commit)
+	body := map[string]string{
+		"state":       gitlabStates[status.State],
+		"name":        status.Context,
+		"description": truncate(status.Description, maxDescription),
+	}
+	if status.TargetURL != "" {
+		body["target_url"] = status.TargetURL
+	}
+	err := g.client.do(ctx, "POST", endpoint, body, nil)
+	if err != nil {
+		return fmt.Errorf("回报GitLab提交状态失败: %w", err)
+	}
+	return nil
+}
+
+// UpsertComment 发布或更新合并请求评论（note）
+func (g *gitlabReporter) UpsertComment(ctx context.Context, comment Comment) error {
+	notesURL := fmt.Sprintf("%s/api/v4/projects/%s/merge_requests/%d/notes", g.baseURL, url.PathEscape(comment.Repo), comment.Number)
+	return g.client.upsertComment(ctx, commentAPI{
+		list:         notesURL + "?per_page=100&sort=asc",
+		create:       notesURL,
+		update:       func(id int64) string { return fmt.Sprintf("%s/%d", notesURL, id) },
+		updateMethod: "PUT",
+	}, comment)
+}
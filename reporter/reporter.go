@@ -0,0 +1,176 @@
+package reporter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// State 提交状态
+type State string
+
+// 回报的提交状态，各平台的接口使用各自的取值
+const (
+	StatePending   State = "pending"   // 运行已加入队列或正在执行
+	StateSuccess   State = "success"   // 运行成功
+	StateFailure   State = "failure"   // 运行失败
+	StateCancelled State = "cancelled" // 运行被取消
+)
+
+// 状态描述的最大长度，GitHub 限制为140个字符
+const maxDescription = 140
+
+// Status 一次提交状态回报
+type Status struct {
+	Repo        string // 仓库全名，如 owner/repo，GitLab 为项目路径
+	Commit      string // 提交SHA
+	State       State
+	Context     string // 状态名称，同一提交上同名的状态会被覆盖
+	Description string // 状态描述
+	TargetURL   string // 运行详情链接，可以为空
+}
+
+// Reporter 向代码托管平台回报提交状态和PR评论
+type Reporter interface {
+	// ReportStatus 回报提交状态
+	ReportStatus(ctx context.Context, status Status) error
+
+	// UpsertComment 发布PR评论，已存在同一 Key 的评论时更新该评论
+	UpsertComment(ctx context.Context, comment Comment) error
+}
+
+// Config 回报器配置
+type Config struct {
+	Provider string // 平台：github, gitlab, gitea, forgejo
+	BaseURL  string // GitHub 为 API 地址，默认 https://api.github.com；GitLab 和 Gitea 为实例地址
+	Token    string // 访问令牌
+	API      string // GitHub 使用的接口：statuses（默认）或 checks
+}
+
+// Providers 支持回报提交状态的平台
+var Providers = []string{"github", "gitlab", "gitea", "forgejo"}
+
+// New 按平台创建回报器
+func New(cfg Config) (Reporter, error) {
+	if cfg.Token == "" {
+		return nil, fmt.Errorf("缺少访问令牌")
+	}
+	client := &apiClient{
+		token:  cfg.Token,
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+
+	switch cfg.Provider {
+	case "github":
+		return newGitHubReporter(cfg.BaseURL, cfg.API, client)
+	case "gitlab":
+		return newGitLabReporter(cfg.BaseURL, client), nil
+	case "gitea", "forgejo":
+		return newGiteaReporter(cfg.BaseURL, client), nil
+	default:
+		return nil, fmt.Errorf("不支持回报提交状态的平台: %s", cfg.Provider)
+	}
+}
+
+// truncate 截断状态描述
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
+
+// apiClient 平台接口的HTTP客户端
+type apiClient struct {
+	token   string
+	headers map[string]string // 平台要求的额外请求头
+	client  *http.Client
+}
+
+// commentAPI 平台的评论接口
+type commentAPI struct {
+	list         string             // 列出PR评论
+	create       string             // 发布评论
+	update       func(int64) string // 按评论ID更新评论
+	updateMethod string             // 更新评论的请求方法
+}
+
+// upsertComment 查找带隐藏标记的评论并更新，不存在时发布新评论
+// 只查找第一页（最多100条），置顶评论通常在PR开始时就已发布
+func (c *apiClient) upsertComment(ctx context.Context, api commentAPI, comment Comment) error {
+	var existing []struct {
+		ID   int64  `json:"id"`
+		Body string `json:"body"`
+	}
+	if err := c.do(ctx, "GET", api.list, nil, &existing); err != nil {
+		return fmt.Errorf("读取PR评论失败: %w", err)
+	}
+
+	body := map[string]string{"body": comment.content()}
+	for _, e := range existing {
+		if strings.Contains(e.Body, comment.marker()) {
+			if err := c.do(ctx, api.updateMethod, api.update(e.ID), body, nil); err != nil {
+				return fmt.Errorf("更新PR评论失败: %w", err)
+			}
+			return nil
+		}
+	}
+	if comment.UpdateOnly {
+		return nil
+	}
+	if err := c.do(ctx, "POST", api.create, body, nil); err != nil {
+		return fmt.Errorf("发布PR评论失败: %w", err)
+	}
+	return nil
+}
+
+// do 以JSON发送请求，out 不为 nil 时解析响应
+func (c *apiClient) do(ctx context.Context, method, url string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range c.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, truncate(string(data), 200))
+	}
+	if out != nil {
+		if err := json.Unmarshal(data, out); err != nil {
+			return fmt.Errorf("解析响应失败: %w", err)
+		}
+	}
+	return nil
+}
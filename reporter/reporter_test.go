@@ -0,0 +1,226 @@
+package reporter
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// request 测试服务器收到的请求
+type request struct {
+	Method string
+	Path   string
+	Auth   string
+	Body   map[string]interface{}
+}
+
+// newStandIn 创建记录请求的平台接口替身，响应固定的 check run ID
+func newStandIn(t *testing.T) (*httptest.Server, func() []request) {
+	var mu sync.Mutex
+	var requests []request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		req := request{Method: r.Method, Path: r.URL.EscapedPath(), Auth: r.Header.Get("Authorization")}
+		json.Unmarshal(data, &req.Body)
+		mu.Lock()
+		requests = append(requests, req)
+		mu.Unlock()
+		if r.Header.Get("Authorization") != "Bearer token-1" {
+			http.Error(w, `{"message":"Bad credentials"}`, http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"id": 42}`))
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []request {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]request(nil), requests...)
+	}
+}
+
+func TestReporters(t *testing.T) {
+	ctx := context.Background()
+	status := Status{
+		Repo:        "group/sub/backend",
+		Commit:      "abc123",
+		Context:     "smart-ci/backend",
+		Description: "运行失败: " + strings.Repeat("错", 200),
+		TargetURL:   "https://ci.example.com/api/logs/stream?run_id=1",
+	}
+
+	cases := []struct {
+		provider, path, state, nameKey string
+	}{
+		{"github", "/repos/group/sub/backend/statuses/abc123", "failure", "context"},
+		{"gitlab", "/api/v4/projects/group%2Fsub%2Fbackend/statuses/abc123", "failed", "name"},
+		{"gitea", "/api/v1/repos/group/sub/backend/statuses/abc123", "failure", "context"},
+	}
+	for _, c := range cases {
+		t.Run(c.provider, func(t *testing.T) {
+			server, requests := newStandIn(t)
+			rep, err := New(Config{Provider: c.provider, BaseURL: server.URL, Token: "token-1"})
+			if err != nil {
+				t.Fatalf("创建回报器失败: %v", err)
+			}
+
+			s := status
+			s.State = StateFailure
+			if err := rep.ReportStatus(ctx, s); err != nil {
+				t.Fatalf("回报失败: %v", err)
+			}
+			got := requests()
+			if len(got) != 1 || got[0].Method != "POST" || got[0].Path != c.path {
+				t.Fatalf("请求不正确: %+v", got)
+			}
+			body := got[0].Body
+			if body["state"] != c.state || body[c.nameKey] != s.Context || body["target_url"] != s.TargetURL {
+				t.Errorf("请求体不正确: %v", body)
+			}
+			if n := len([]rune(body["description"].(string))); n != maxDescription {
+				t.Errorf("描述应该截断为 %d 个字符，实际 %d", maxDescription, n)
+			}
+		})
+	}
+
+	t.Run("GitHub Checks", func(t *testing.T) {
+		server, requests := newStandIn(t)
+		rep, err := New(Config{Provider: "github", BaseURL: server.URL, Token: "token-1", API: "checks"})
+		if err != nil {
+			t.Fatalf("创建回报器失败: %v", err)
+		}
+
+		s := status
+		s.State = StatePending
+		if err := rep.ReportStatus(ctx, s); err != nil {
+			t.Fatalf("回报失败: %v", err)
+		}
+		s.State = StateSuccess
+		if err := rep.ReportStatus(ctx, s); err != nil {
+			t.Fatalf("回报失败: %v", err)
+		}
+
+		got := requests()
+		if len(got) != 2 {
+			t.Fatalf("请求数量不正确: %+v", got)
+		}
+		if got[0].Method != "POST" || got[0].Path != "/repos/group/sub/backend/check-runs" || got[0].Body["status"] != "in_progress" {
+			t.Errorf("应该创建进行中的 check run: %+v", got[0])
+		}
+		if got[1].Method != "PATCH" || got[1].Path != "/repos/group/sub/backend/check-runs/42" || got[1].Body["conclusion"] != "success" {
+			t.Errorf("应该将同一个 check run 更新为完成: %+v", got[1])
+		}
+	})
+
+	t.Run("错误", func(t *testing.T) {
+		server, _ := newStandIn(t)
+		rep, _ := New(Config{Provider: "gitea", BaseURL: server.URL, Token: "bad-token"})
+		if err := rep.ReportStatus(ctx, status); err == nil || !strings.Contains(err.Error(), "HTTP 401") {
+			t.Errorf("令牌无效时应该返回错误: %v", err)
+		}
+		if _, err := New(Config{Provider: "bitbucket", Token: "token-1"}); err == nil {
+			t.Error("不支持的平台应该返回错误")
+		}
+		if _, err := New(Config{Provider: "github"}); err == nil {
+			t.Error("缺少令牌时应该返回错误")
+		}
+	})
+}
+
+func TestUpsertComment(t *testing.T) {
+	ctx := context.Background()
+	var mu sync.Mutex
+	comments := map[int64]string{1: "其他评论"}
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests = append(requests, r.Method+" "+r.URL.EscapedPath())
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		switch {
+		case r.Method == "GET":
+			list := []map[string]interface{}{}
+			for id, text := range comments {
+				list = append(list, map[string]interface{}{"id": id, "body": text})
+			}
+			json.NewEncoder(w).Encode(list)
+		case r.Method == "POST":
+			comments[int64(len(comments)+1)] = body["body"]
+			w.Write([]byte(`{}`))
+		case r.Method == "PUT" && r.URL.EscapedPath() == "/api/v4/projects/group%2Fbackend/merge_requests/3/notes/2":
+			comments[2] = body["body"]
+			w.Write([]byte(`{}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	rep, err := New(Config{Provider: "gitlab", BaseURL: server.URL, Token: "token-1"})
+	if err != nil {
+		t.Fatalf("创建回报器失败: %v", err)
+	}
+	comment := Comment{Repo: "group/backend", Number: 3, Key: "smart-ci/backend", Body: "第一次运行", UpdateOnly: true}
+
+	t.Run("只更新时不创建评论", func(t *testing.T) {
+		if err := rep.UpsertComment(ctx, comment); err != nil {
+			t.Fatalf("发布评论失败: %v", err)
+		}
+		if len(comments) != 1 {
+			t.Errorf("不应该创建评论: %v", comments)
+		}
+	})
+
+	t.Run("创建后更新同一条评论", func(t *testing.T) {
+		comment.UpdateOnly = false
+		if err := rep.UpsertComment(ctx, comment); err != nil {
+			t.Fatalf("发布评论失败: %v", err)
+		}
+		comment.Body = "第二次运行"
+		if err := rep.UpsertComment(ctx, comment); err != nil {
+			t.Fatalf("更新评论失败: %v", err)
+		}
+
+		if len(comments) != 2 {
+			t.Fatalf("应该只有一条置顶评论: %v", comments)
+		}
+		if !strings.HasPrefix(comments[2], "<!-- smart-ci:smart-ci/backend -->") || !strings.Contains(comments[2], "第二次运行") {
+			t.Errorf("评论内容不正确: %q", comments[2])
+		}
+		if last := requests[len(requests)-1]; !strings.HasPrefix(last, "PUT ") {
+			t.Errorf("第二次应该更新已有评论，实际请求: %v", requests)
+		}
+	})
+}
+
+func TestFormatComment(t *testing.T) {
+	body := FormatComment(RunSummary{
+		Context:    "smart-ci/backend",
+		State:      StateFailure,
+		RunID:      "run-1",
+		Commit:     "abcdef1234567890",
+		TargetURL:  "https://ci.example.com/run-1",
+		FailedStep: "test/unit",
+		Error:      "流水线失败",
+		LogTail:    "line1\nline2",
+		Analysis:   "缺少依赖",
+	})
+	for _, want := range []string{
+		"❌ smart-ci/backend 运行失败",
+		"[run-1](https://ci.example.com/run-1)",
+		"`abcdef1`",
+		"**失败步骤:** `test/unit`",
+		"日志末尾 2 行",
+		"#### 🤖 AI 分析\n\n缺少依赖",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("评论缺少 %q:\n%s", want, body)
+		}
+	}
+}
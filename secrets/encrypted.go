@@ -0,0 +1,192 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// DefaultMasterKeyEnv 保存主密钥的默认环境变量
+const DefaultMasterKeyEnv = "SMARTCI_MASTER_KEY"
+
+// encryptedFile 加密文件格式：密钥名称明文保存，值使用 AES-256-GCM 加密，
+// 密钥名称作为附加认证数据，防止密文在不同名称之间被替换
+type encryptedFile struct {
+	Version int               `json:"version"`
+	Secrets map[string]string `json:"secrets"` // 名称 -> base64(nonce + 密文)
+}
+
+// EncryptedFileProvider 使用主密钥加密的本地密钥文件
+// 主密钥从环境变量读取，经 SHA-256 派生为 AES-256 密钥；每次读取都重新加载文件，修改后无需重启
+type EncryptedFileProvider struct {
+	path   string
+	keyEnv string
+	mu     sync.Mutex
+}
+
+func NewEncryptedFileProvider(path, keyEnv string) *EncryptedFileProvider {
+	if keyEnv == "" {
+		keyEnv = DefaultMasterKeyEnv
+	}
+	return &EncryptedFileProvider{path: path, keyEnv: keyEnv}
+}
+
+func (p *EncryptedFileProvider) Name() string {
+	return "encrypted"
+}
+
+func (p *EncryptedFileProvider) Get(name string) (string, bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	file, err := p.load()
+	if err != nil {
+		return "", false, err
+	}
+	sealed, found := file.Secrets[name]
+	if !found {
+		return "", false, nil
+	}
+
+	gcm, err := p.cipher()
+	if err != nil {
+		return "", false, err
+	}
+	value, err := open(gcm, name, sealed)
+	if err != nil {
+		return "", false, err
+	}
+	return value, true, nil
+}
+
+// Set 加密保存密钥，已存在时覆盖
+func (p *EncryptedFileProvider) Set(name, value string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	file, err := p.load()
+	if err != nil {
+		return err
+	}
+	gcm, err := p.cipher()
+	if err != nil {
+		return err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("生成随机数失败: %v", err)
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(value), []byte(name))
+	file.Secrets[name] = base64.StdEncoding.EncodeToString(sealed)
+	return p.save(file)
+}
+
+// Delete 删除密钥，返回密钥是否存在
+func (p *EncryptedFileProvider) Delete(name string) (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	file, err := p.load()
+	if err != nil {
+		return false, err
+	}
+	if _, found := file.Secrets[name]; !found {
+		return false, nil
+	}
+	delete(file.Secrets, name)
+	return true, p.save(file)
+}
+
+// List 返回所有密钥名称，不需要主密钥
+func (p *EncryptedFileProvider) List() ([]string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	file, err := p.load()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(file.Secrets))
+	for name := range file.Secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// hiddenEnv 主密钥不继承到任务环境
+func (p *EncryptedFileProvider) hiddenEnv(key string) bool {
+	return key == p.keyEnv
+}
+
+func (p *EncryptedFileProvider) cipher() (cipher.AEAD, error) {
+	masterKey := os.Getenv(p.keyEnv)
+	if masterKey == "" {
+		return nil, fmt.Errorf("未设置主密钥环境变量 %s", p.keyEnv)
+	}
+	key := sha256.Sum256([]byte(masterKey))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// load 读取密钥文件，文件不存在时返回空文件
+func (p *EncryptedFileProvider) load() (*encryptedFile, error) {
+	file := &encryptedFile{Version: 1, Secrets: make(map[string]string)}
+	data, err := os.ReadFile(p.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return file, nil
+		}
+		return nil, fmt.Errorf("读取密钥文件失败: %v", err)
+	}
+	if err := json.Unmarshal(data, file); err != nil {
+		return nil, fmt.Errorf("解析密钥文件失败: %v", err)
+	}
+	if file.Secrets == nil {
+		file.Secrets = make(map[string]string)
+	}
+	return file, nil
+}
+
+// save 先写临时文件再重命名，避免写入中断损坏密钥文件
+func (p *EncryptedFileProvider) save(file *encryptedFile) error {
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(p.path); dir != "" {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return fmt.Errorf("创建密钥目录失败: %v", err)
+		}
+	}
+	tmp := p.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("写入密钥文件失败: %v", err)
+	}
+	return os.Rename(tmp, p.path)
+}
+
+func open(gcm cipher.AEAD, name, sealed string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil || len(data) < gcm.NonceSize() {
+		return "", fmt.Errorf("密钥 %s 格式错误", name)
+	}
+	nonce, ciphertext := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, []byte(name))
+	if err != nil {
+		return "", fmt.Errorf("解密密钥 %s 失败，主密钥可能不正确", name)
+	}
+	return string(plaintext), nil
+}
@@ -0,0 +1,43 @@
+package secrets
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultFileDir file 提供者默认的密钥目录，与 Docker/Kubernetes secrets 的挂载位置一致
+const DefaultFileDir = "/run/secrets"
+
+// FileProvider 从目录读取密钥，每个文件一个密钥，文件名即密钥名称
+type FileProvider struct {
+	dir string
+}
+
+func NewFileProvider(dir string) *FileProvider {
+	if dir == "" {
+		dir = DefaultFileDir
+	}
+	return &FileProvider{dir: dir}
+}
+
+func (p *FileProvider) Name() string {
+	return "file"
+}
+
+func (p *FileProvider) Get(name string) (string, bool, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) || name == "." || name == ".." {
+		return "", false, fmt.Errorf("密钥名称不合法: %q", name)
+	}
+
+	data, err := os.ReadFile(filepath.Join(p.dir, name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+	// 去掉文件末尾的换行
+	return strings.TrimRight(string(data), "\r\n"), true, nil
+}
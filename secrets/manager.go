@@ -0,0 +1,198 @@
+package secrets
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"lite-cicd/config"
+)
+
+// RefPrefix 配置文件中引用密钥的前缀，如 secret: "secret://GITHUB_WEBHOOK_SECRET"
+const RefPrefix = "secret://"
+
+// Provider 密钥提供者
+type Provider interface {
+	// Name 提供者名称，用于日志和错误信息
+	Name() string
+	// Get 读取密钥，不存在时 found 为 false
+	Get(name string) (value string, found bool, err error)
+}
+
+// envFilter 由提供者实现，返回不应继承到任务环境中的环境变量（如主密钥、密钥变量）
+type envFilter interface {
+	hiddenEnv(key string) bool
+}
+
+// Manager 按顺序在多个提供者中查找密钥，读取到的值自动登记用于日志脱敏
+type Manager struct {
+	providers []Provider
+}
+
+// NewManager 根据配置创建密钥管理器，未配置提供者时使用默认的 env 提供者
+func NewManager(cfg config.SecretsConfig) (*Manager, error) {
+	m := &Manager{}
+	if len(cfg.Providers) == 0 {
+		m.providers = append(m.providers, NewEnvProvider(""))
+		return m, nil
+	}
+
+	for i, providerCfg := range cfg.Providers {
+		switch providerCfg.Type {
+		case "encrypted":
+			if providerCfg.Path == "" {
+				return nil, fmt.Errorf("密钥提供者[%d]: encrypted 类型必须配置 path", i)
+			}
+			m.providers = append(m.providers, NewEncryptedFileProvider(providerCfg.Path, providerCfg.KeyEnv))
+		case "env":
+			m.providers = append(m.providers, NewEnvProvider(providerCfg.Prefix))
+		case "file":
+			m.providers = append(m.providers, NewFileProvider(providerCfg.Path))
+		default:
+			return nil, fmt.Errorf("密钥提供者[%d]: 未知类型 %q", i, providerCfg.Type)
+		}
+	}
+	return m, nil
+}
+
+// NewManagerWithProviders 使用指定的提供者创建密钥管理器
+func NewManagerWithProviders(providers ...Provider) *Manager {
+	return &Manager{providers: providers}
+}
+
+// Get 按提供者顺序读取密钥，读取成功后登记用于脱敏
+func (m *Manager) Get(name string) (string, error) {
+	for _, provider := range m.providers {
+		value, found, err := provider.Get(name)
+		if err != nil {
+			return "", fmt.Errorf("读取密钥 %s 失败 [%s]: %v", name, provider.Name(), err)
+		}
+		if found {
+			Register(value)
+			return value, nil
+		}
+	}
+	return "", fmt.Errorf("未找到密钥: %s", name)
+}
+
+// Resolve 读取一组密钥
+func (m *Manager) Resolve(names []string) (map[string]string, error) {
+	values := make(map[string]string, len(names))
+	var missing []string
+	for _, name := range names {
+		value, err := m.Get(name)
+		if err != nil {
+			missing = append(missing, err.Error())
+			continue
+		}
+		values[name] = value
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("%s", strings.Join(missing, "; "))
+	}
+	return values, nil
+}
+
+// Env 生成任务的环境变量：base 中去掉主密钥和密钥变量后，追加 names 指定的密钥
+// base 为空时只返回密钥（用于容器环境）
+func (m *Manager) Env(base []string, names []string) ([]string, error) {
+	values, err := m.Resolve(names)
+	if err != nil {
+		return nil, err
+	}
+
+	env := m.FilterEnv(base)
+	for _, name := range names {
+		env = append(env, name+"="+values[name])
+	}
+	return env, nil
+}
+
+// FilterEnv 返回去掉主密钥和密钥变量后的环境变量，不修改 env
+func (m *Manager) FilterEnv(env []string) []string {
+	filtered := make([]string, 0, len(env))
+	for _, kv := range env {
+		key, _, _ := strings.Cut(kv, "=")
+		if !m.hidden(key) {
+			filtered = append(filtered, kv)
+		}
+	}
+	return filtered
+}
+
+func (m *Manager) hidden(key string) bool {
+	for _, provider := range m.providers {
+		if f, ok := provider.(envFilter); ok && f.hiddenEnv(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolveRef 解析 secret://NAME 引用，其他值原样返回
+func (m *Manager) ResolveRef(value string) (string, error) {
+	if !strings.HasPrefix(value, RefPrefix) {
+		return value, nil
+	}
+	return m.Get(strings.TrimPrefix(value, RefPrefix))
+}
+
+// ResolveConfig 解析配置中敏感字段的 secret:// 引用，并将这些字段的值登记用于脱敏
+// 包括 server.auth_token、oauth[].client_secret、webhooks[].secret、webhooks[].report.token、llm_key 和 llm.api_key
+func (m *Manager) ResolveConfig(cfg *config.Config) error {
+	var errs []string
+	resolve := func(field string, value *string) {
+		resolved, err := m.ResolveRef(*value)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", field, err))
+			return
+		}
+		*value = resolved
+		Register(resolved)
+	}
+
+	resolve("server.auth_token", &cfg.Server.AuthToken)
+	resolve("llm_key", &cfg.LLMKey)
+	resolve("llm.api_key", &cfg.LLM.APIKey)
+	for i := range cfg.OAuth {
+		resolve(fmt.Sprintf("oauth[%d].client_secret", i), &cfg.OAuth[i].ClientSecret)
+	}
+	for i := range cfg.Webhooks {
+		resolve(fmt.Sprintf("webhooks[%d].secret", i), &cfg.Webhooks[i].Secret)
+		resolve(fmt.Sprintf("webhooks[%d].report.token", i), &cfg.Webhooks[i].Report.Token)
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("解析密钥引用失败: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// DefaultEnvPrefix env 提供者默认的环境变量前缀
+const DefaultEnvPrefix = "SMARTCI_SECRET_"
+
+// EnvProvider 从带前缀的环境变量读取密钥，如 SMARTCI_SECRET_DEPLOY_TOKEN
+type EnvProvider struct {
+	prefix string
+}
+
+func NewEnvProvider(prefix string) *EnvProvider {
+	if prefix == "" {
+		prefix = DefaultEnvPrefix
+	}
+	return &EnvProvider{prefix: prefix}
+}
+
+func (p *EnvProvider) Name() string {
+	return "env"
+}
+
+func (p *EnvProvider) Get(name string) (string, bool, error) {
+	value, found := os.LookupEnv(p.prefix + name)
+	return value, found, nil
+}
+
+// hiddenEnv 带前缀的密钥变量不继承到任务环境，只按任务配置注入
+func (p *EnvProvider) hiddenEnv(key string) bool {
+	return strings.HasPrefix(key, p.prefix)
+}
@@ -0,0 +1,155 @@
+package secrets
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Redacted 替换密钥值的文本
+const Redacted = "***"
+
+// minMaskLength 短于该长度的值不脱敏，避免把日志中的常见短字符串全部替换掉
+const minMaskLength = 4
+
+// maxPendingBytes 脱敏写入器缓冲的最大未换行内容，超过后直接输出
+const maxPendingBytes = 64 * 1024
+
+// registry 全局登记的密钥值
+var registry = &maskRegistry{values: make(map[string]struct{})}
+
+type maskRegistry struct {
+	mu       sync.RWMutex
+	values   map[string]struct{}
+	replacer *strings.Replacer
+}
+
+// Register 登记需要脱敏的值，同时登记多行值的每一行和 JSON 转义后的形式
+func Register(value string) {
+	if len(value) < minMaskLength {
+		return
+	}
+
+	candidates := []string{value}
+	if strings.Contains(value, "\n") {
+		for _, line := range strings.Split(value, "\n") {
+			candidates = append(candidates, strings.TrimRight(line, "\r"))
+		}
+	}
+	if escaped, err := json.Marshal(value); err == nil {
+		candidates = append(candidates, string(escaped[1:len(escaped)-1]))
+	}
+
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	changed := false
+	for _, candidate := range candidates {
+		if len(candidate) < minMaskLength {
+			continue
+		}
+		if _, exists := registry.values[candidate]; !exists {
+			registry.values[candidate] = struct{}{}
+			changed = true
+		}
+	}
+	if changed {
+		registry.rebuild()
+	}
+}
+
+// rebuild 按长度从长到短生成替换器，保证包含关系的密钥优先替换较长的值
+func (r *maskRegistry) rebuild() {
+	values := make([]string, 0, len(r.values))
+	for value := range r.values {
+		values = append(values, value)
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if len(values[i]) != len(values[j]) {
+			return len(values[i]) > len(values[j])
+		}
+		return values[i] < values[j]
+	})
+
+	pairs := make([]string, 0, len(values)*2)
+	for _, value := range values {
+		pairs = append(pairs, value, Redacted)
+	}
+	r.replacer = strings.NewReplacer(pairs...)
+}
+
+func (r *maskRegistry) current() *strings.Replacer {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.replacer
+}
+
+// Mask 将文本中已登记的密钥值替换为 ***
+func Mask(s string) string {
+	replacer := registry.current()
+	if replacer == nil {
+		return s
+	}
+	return replacer.Replace(s)
+}
+
+// MaskBytes 与 Mask 相同，用于字节内容
+func MaskBytes(data []byte) []byte {
+	replacer := registry.current()
+	if replacer == nil {
+		return data
+	}
+	return []byte(replacer.Replace(string(data)))
+}
+
+// MaskingWriter 对写入的内容按行脱敏后再写入底层 Writer
+// 不完整的行会缓冲到换行或 Flush 时输出，避免密钥被拆分到两次写入中而漏掉
+type MaskingWriter struct {
+	mu      sync.Mutex
+	w       io.Writer
+	pending []byte
+}
+
+func NewMaskingWriter(w io.Writer) *MaskingWriter {
+	return &MaskingWriter{w: w}
+}
+
+func (m *MaskingWriter) Write(p []byte) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// 没有登记任何密钥时直接写入，保持日志实时性
+	if len(m.pending) == 0 && registry.current() == nil {
+		return m.w.Write(p)
+	}
+
+	m.pending = append(m.pending, p...)
+	end := bytes.LastIndexByte(m.pending, '\n') + 1
+	if end == 0 && len(m.pending) < maxPendingBytes {
+		return len(p), nil
+	}
+	if end == 0 {
+		end = len(m.pending)
+	}
+
+	if _, err := m.w.Write(MaskBytes(m.pending[:end])); err != nil {
+		return 0, err
+	}
+	m.pending = append(m.pending[:0], m.pending[end:]...)
+	return len(p), nil
+}
+
+// Flush 输出缓冲中未换行的内容
+func (m *MaskingWriter) Flush() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.pending) == 0 {
+		return nil
+	}
+	_, err := m.w.Write(MaskBytes(m.pending))
+	m.pending = m.pending[:0]
+	return err
+}
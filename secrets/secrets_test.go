@@ -0,0 +1,164 @@
+package secrets
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"lite-cicd/config"
+)
+
+func TestEncryptedFileProvider(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.enc")
+	t.Setenv("SMARTCI_TEST_MASTER_KEY", "correct horse battery staple")
+
+	provider := NewEncryptedFileProvider(path, "SMARTCI_TEST_MASTER_KEY")
+	if err := provider.Set("DEPLOY_TOKEN", "tok-1234567890"); err != nil {
+		t.Fatalf("保存密钥失败: %v", err)
+	}
+
+	data, _ := os.ReadFile(path)
+	if bytes.Contains(data, []byte("tok-1234567890")) {
+		t.Fatal("密钥文件中不应包含明文")
+	}
+
+	value, found, err := provider.Get("DEPLOY_TOKEN")
+	if err != nil || !found || value != "tok-1234567890" {
+		t.Fatalf("读取密钥失败: value=%q found=%v err=%v", value, found, err)
+	}
+	if _, found, _ := provider.Get("MISSING"); found {
+		t.Error("不存在的密钥不应被找到")
+	}
+	if names, _ := provider.List(); len(names) != 1 || names[0] != "DEPLOY_TOKEN" {
+		t.Errorf("密钥列表不正确: %v", names)
+	}
+
+	t.Run("主密钥错误", func(t *testing.T) {
+		t.Setenv("SMARTCI_TEST_MASTER_KEY", "wrong key")
+		if _, _, err := provider.Get("DEPLOY_TOKEN"); err == nil {
+			t.Error("主密钥错误时应该解密失败")
+		}
+	})
+
+	t.Run("未设置主密钥", func(t *testing.T) {
+		t.Setenv("SMARTCI_TEST_MASTER_KEY", "")
+		if _, _, err := provider.Get("DEPLOY_TOKEN"); err == nil || !strings.Contains(err.Error(), "SMARTCI_TEST_MASTER_KEY") {
+			t.Errorf("应该提示未设置主密钥，实际: %v", err)
+		}
+	})
+
+	if found, err := provider.Delete("DEPLOY_TOKEN"); err != nil || !found {
+		t.Fatalf("删除密钥失败: found=%v err=%v", found, err)
+	}
+	if _, found, _ := provider.Get("DEPLOY_TOKEN"); found {
+		t.Error("删除后不应再读取到密钥")
+	}
+}
+
+func TestManager(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "DB_PASSWORD"), []byte("file-pass-0001\n"), 0600)
+	t.Setenv("SMARTCI_SECRET_DB_PASSWORD", "env-pass-0002")
+	t.Setenv("SMARTCI_SECRET_API_KEY", "env-key-0003")
+
+	manager, err := NewManager(config.SecretsConfig{Providers: []config.SecretProviderConfig{
+		{Type: "file", Path: dir},
+		{Type: "env"},
+	}})
+	if err != nil {
+		t.Fatalf("创建密钥管理器失败: %v", err)
+	}
+
+	t.Run("按提供者顺序查找", func(t *testing.T) {
+		values, err := manager.Resolve([]string{"DB_PASSWORD", "API_KEY"})
+		if err != nil {
+			t.Fatalf("读取密钥失败: %v", err)
+		}
+		if values["DB_PASSWORD"] != "file-pass-0001" || values["API_KEY"] != "env-key-0003" {
+			t.Errorf("密钥值不正确: %v", values)
+		}
+		if _, err := manager.Resolve([]string{"MISSING"}); err == nil || !strings.Contains(err.Error(), "MISSING") {
+			t.Errorf("缺少密钥时应该报错，实际: %v", err)
+		}
+	})
+
+	t.Run("只注入指定的密钥", func(t *testing.T) {
+		base := []string{"PATH=/usr/bin", "SMARTCI_SECRET_API_KEY=env-key-0003"}
+		env, err := manager.Env(base, []string{"DB_PASSWORD"})
+		if err != nil {
+			t.Fatalf("生成环境变量失败: %v", err)
+		}
+		got := strings.Join(env, ",")
+		if got != "PATH=/usr/bin,DB_PASSWORD=file-pass-0001" {
+			t.Errorf("环境变量不正确: %s", got)
+		}
+	})
+
+	t.Run("解析配置引用", func(t *testing.T) {
+		cfg := config.Config{
+			LLMKey: "secret://API_KEY",
+			Webhooks: []config.WebhookConfig{
+				{Secret: "plain-webhook-secret", Report: config.ReportConfig{Token: "secret://DB_PASSWORD"}},
+				{Report: config.ReportConfig{Token: "plain-status-token"}},
+			},
+		}
+		if err := manager.ResolveConfig(&cfg); err != nil {
+			t.Fatalf("解析配置引用失败: %v", err)
+		}
+		if cfg.LLMKey != "env-key-0003" {
+			t.Errorf("llm_key 解析结果不正确: %q", cfg.LLMKey)
+		}
+		if cfg.Webhooks[0].Report.Token != "file-pass-0001" {
+			t.Errorf("webhooks[].report.token 解析结果不正确: %q", cfg.Webhooks[0].Report.Token)
+		}
+		if Mask("hook plain-webhook-secret") != "hook ***" || Mask("Bearer plain-status-token") != "Bearer ***" {
+			t.Error("配置中的敏感字段应该登记脱敏")
+		}
+
+		cfg.Server.AuthToken = "secret://MISSING"
+		if err := manager.ResolveConfig(&cfg); err == nil || !strings.Contains(err.Error(), "server.auth_token") {
+			t.Errorf("引用不存在的密钥应该报错，实际: %v", err)
+		}
+	})
+}
+
+func TestMask(t *testing.T) {
+	Register("abc")
+	Register("s3cr3t-value")
+	Register("line-one\nline-two")
+
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"token=s3cr3t-value;", "token=***;"},
+		{"abc is too short", "abc is too short"},
+		{"key: line-two", "key: ***"},
+		{`{"v":"line-one\nline-two"}`, `{"v":"***"}`},
+	}
+	for _, tt := range tests {
+		if got := Mask(tt.input); got != tt.want {
+			t.Errorf("Mask(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestMaskingWriter(t *testing.T) {
+	Register("split-secret-42")
+
+	var buf bytes.Buffer
+	w := NewMaskingWriter(&buf)
+	// 密钥被拆分到多次写入中
+	w.Write([]byte("login split-sec"))
+	w.Write([]byte("ret-42 ok\nnext split-"))
+	if got := buf.String(); got != "login *** ok\n" {
+		t.Errorf("完整的行应该脱敏后输出，实际: %q", got)
+	}
+	w.Write([]byte("secret-42"))
+	w.Flush()
+	if got := buf.String(); got != "login *** ok\nnext ***" {
+		t.Errorf("Flush 后应该输出剩余内容，实际: %q", got)
+	}
+}
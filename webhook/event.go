@@ -0,0 +1,199 @@
+package webhook
+
+import "strings"
+
+// 注入动作执行环境的事件变量
+const (
+	EnvEvent  = "SMARTCI_EVENT"
+	EnvBranch = "SMARTCI_BRANCH"
+	EnvCommit = "SMARTCI_COMMIT"
+	EnvRepo   = "SMARTCI_REPO"
+)
+
+// Event 一次webhook事件
+type Event struct {
+	Type    string                 // 事件类型，如 push、pull_request
+	Branch  string                 // 推送的分支，PR事件为目标分支
+	Commit  string                 // 推送后的提交，PR事件为源分支的最新提交
+	Repo    string                 // 仓库全名，如 user/repo
+	Number  int                    // PR编号，GitLab 为合并请求的 iid，非PR事件为0
+	Payload map[string]interface{} // 解析后的payload，作为动作模板的数据
+	Raw     []byte                 // 原始请求体
+	Webhook string                 // 接收事件的webhook名称
+}
+
+func newEvent(eventType string, payload map[string]interface{}, raw []byte) *Event {
+	return &Event{
+		Type:    eventType,
+		Branch:  extractBranch(payload),
+		Commit:  extractCommit(payload),
+		Repo:    extractRepoFullName(payload),
+		Number:  extractNumber(payload),
+		Payload: payload,
+		Raw:     raw,
+	}
+}
+
+// Env 返回注入动作执行环境的事件变量
+func (e *Event) Env() map[string]string {
+	return map[string]string{
+		EnvEvent:  e.Type,
+		EnvBranch: e.Branch,
+		EnvCommit: e.Commit,
+		EnvRepo:   e.Repo,
+	}
+}
+
+// Deleted 是否为删除分支的推送事件，此时没有可检出的提交
+func (e *Event) Deleted() bool {
+	if deleted, ok := e.Payload["deleted"].(bool); ok && deleted {
+		return true
+	}
+	// Bitbucket Cloud: 删除分支的变更 closed 为 true
+	if closed, ok := bitbucketChange(e.Payload)["closed"].(bool); ok && closed {
+		return true
+	}
+	return e.Commit != "" && strings.Trim(e.Commit, "0") == ""
+}
+
+// MatchRepo 判断仓库地址是否为事件中的仓库，忽略协议、用户信息、.git 后缀和大小写
+// 支持 https 和 ssh（git@host:owner/repo）两种地址形式
+func (e *Event) MatchRepo(url string) bool {
+	target := normalizeRepoURL(url)
+	if target == "" {
+		return false
+	}
+	candidates := [][]string{
+		{"repository", "clone_url"},
+		{"repository", "ssh_url"},
+		{"repository", "html_url"},
+		{"repository", "git_http_url"},
+		{"repository", "git_ssh_url"},
+		{"project", "git_http_url"},
+		{"project", "git_ssh_url"},
+		{"project", "web_url"},
+		{"repository", "links", "html", "href"},
+	}
+	for _, keys := range candidates {
+		if normalizeRepoURL(lookupString(e.Payload, keys...)) == target {
+			return true
+		}
+	}
+	// Bitbucket Server: repository.links.clone 为 http 和 ssh 地址列表
+	clones, _ := lookupValue(e.Payload, "repository", "links", "clone").([]interface{})
+	for _, clone := range clones {
+		if link, ok := clone.(map[string]interface{}); ok && normalizeRepoURL(lookupString(link, "href")) == target {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeRepoURL 将仓库地址规范化为 host/owner/repo 形式
+func normalizeRepoURL(url string) string {
+	url = strings.ToLower(strings.TrimSpace(url))
+	if url == "" {
+		return ""
+	}
+	if i := strings.Index(url, "://"); i >= 0 {
+		url = url[i+3:]
+	} else if i := strings.Index(url, ":"); i >= 0 {
+		// scp 形式：git@host:owner/repo.git
+		url = url[:i] + "/" + url[i+1:]
+	}
+	if i := strings.Index(url, "@"); i >= 0 && i < strings.Index(url+"/", "/") {
+		url = url[i+1:]
+	}
+	url = strings.TrimSuffix(strings.TrimSuffix(url, "/"), ".git")
+	return url
+}
+
+// extractCommit 从payload中提取提交SHA
+func extractCommit(payload map[string]interface{}) string {
+	// Pull Request: pull_request.head.sha
+	if sha := lookupString(payload, "pull_request", "head", "sha"); sha != "" {
+		return sha
+	}
+	// GitLab Merge Request: object_attributes.last_commit.id
+	if sha := lookupString(payload, "object_attributes", "last_commit", "id"); sha != "" {
+		return sha
+	}
+	// Push: after 为推送后的提交，GitLab 另有 checkout_sha
+	if sha := lookupString(payload, "checkout_sha"); sha != "" {
+		return sha
+	}
+	if sha := lookupString(payload, "after"); sha != "" {
+		return sha
+	}
+	if sha := lookupString(payload, "head_commit", "id"); sha != "" {
+		return sha
+	}
+	// Bitbucket Pull Request: pullrequest.source.commit.hash（Cloud，只有12位短SHA）或 pullRequest.fromRef.latestCommit（Server）
+	if sha := lookupString(payload, "pullrequest", "source", "commit", "hash"); sha != "" {
+		return sha
+	}
+	if sha := lookupString(payload, "pullRequest", "fromRef", "latestCommit"); sha != "" {
+		return sha
+	}
+	// Bitbucket 推送: push.changes[].new.target.hash（Cloud）或 changes[].toHash（Server）
+	change := bitbucketChange(payload)
+	if sha := lookupString(change, "new", "target", "hash"); sha != "" {
+		return sha
+	}
+	return lookupString(change, "toHash")
+}
+
+// extractNumber 从payload中提取PR编号
+func extractNumber(payload map[string]interface{}) int {
+	// GitLab 的流水线等事件也有 object_attributes.iid，只取合并请求的
+	if lookupString(payload, "object_kind") == "merge_request" {
+		if n, ok := lookupValue(payload, "object_attributes", "iid").(float64); ok {
+			return int(n)
+		}
+	}
+	for _, keys := range [][]string{
+		{"pull_request", "number"},
+		{"pullrequest", "id"}, // Bitbucket Cloud
+		{"pullRequest", "id"}, // Bitbucket Server
+	} {
+		if n, ok := lookupValue(payload, keys...).(float64); ok {
+			return int(n)
+		}
+	}
+	return 0
+}
+
+// extractRepoFullName 从payload中提取仓库全名，没有全名时返回仓库名
+func extractRepoFullName(payload map[string]interface{}) string {
+	if fullName := lookupString(payload, "repository", "full_name"); fullName != "" {
+		return fullName
+	}
+	// GitLab: project.path_with_namespace
+	if fullName := lookupString(payload, "project", "path_with_namespace"); fullName != "" {
+		return fullName
+	}
+	// Bitbucket Server: repository.project.key/repository.slug
+	if key, slug := lookupString(payload, "repository", "project", "key"), lookupString(payload, "repository", "slug"); key != "" && slug != "" {
+		return key + "/" + slug
+	}
+	return extractRepo(payload)
+}
+
+// lookupString 按键路径读取嵌套的字符串字段，不存在时返回空字符串
+func lookupString(payload map[string]interface{}, keys ...string) string {
+	s, _ := lookupValue(payload, keys...).(string)
+	return s
+}
+
+// lookupValue 按键路径读取嵌套字段，不存在时返回 nil
+func lookupValue(payload map[string]interface{}, keys ...string) interface{} {
+	var value interface{} = payload
+	for _, key := range keys {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = m[key]
+	}
+	return value
+}
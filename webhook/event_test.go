@@ -0,0 +1,78 @@
+package webhook
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestEvent(t *testing.T) {
+	raw := []byte(`{
+		"ref": "refs/heads/feature",
+		"after": "0000000000000000000000000000000000000000",
+		"deleted": true,
+		"repository": {
+			"name": "backend",
+			"full_name": "User/backend",
+			"clone_url": "https://github.com/User/backend.git",
+			"ssh_url": "git@github.com:User/backend.git"
+		}
+	}`)
+	var payload map[string]interface{}
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		t.Fatalf("解析payload失败: %v", err)
+	}
+	event := newEvent("push", payload, raw)
+
+	t.Run("匹配仓库地址", func(t *testing.T) {
+		for _, url := range []string{
+			"https://github.com/user/backend",
+			"https://token@github.com/User/backend.git",
+			"git@github.com:user/backend.git",
+			"ssh://git@github.com/user/backend",
+		} {
+			if !event.MatchRepo(url) {
+				t.Errorf("地址应该匹配: %s", url)
+			}
+		}
+		for _, url := range []string{"https://github.com/user/frontend", "https://gitlab.com/user/backend", ""} {
+			if event.MatchRepo(url) {
+				t.Errorf("地址不应该匹配: %s", url)
+			}
+		}
+	})
+
+	t.Run("删除分支", func(t *testing.T) {
+		if !event.Deleted() {
+			t.Error("删除分支的推送应该被识别")
+		}
+		if event.Branch != "feature" || event.Repo != "User/backend" {
+			t.Errorf("分支或仓库不正确: %s %s", event.Branch, event.Repo)
+		}
+	})
+}
+
+func TestEvent_Bitbucket(t *testing.T) {
+	raw := []byte(`{
+		"push": {"changes": [{"new": null, "old": {"type": "branch", "name": "feature"}, "closed": true}]},
+		"repository": {
+			"name": "backend",
+			"slug": "backend",
+			"links": {"clone": [
+				{"name": "http", "href": "https://bitbucket.example.com/scm/prj/backend.git"},
+				{"name": "ssh", "href": "ssh://git@bitbucket.example.com:7999/prj/backend.git"}
+			]}
+		}
+	}`)
+	var payload map[string]interface{}
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		t.Fatalf("解析payload失败: %v", err)
+	}
+	event := newEvent("push", payload, raw)
+
+	if !event.Deleted() || event.Branch != "feature" {
+		t.Errorf("删除分支的推送应该被识别: %+v", event)
+	}
+	if !event.MatchRepo("https://bitbucket.example.com/scm/PRJ/backend") {
+		t.Error("应该匹配 links.clone 中的地址")
+	}
+}
@@ -1,6 +1,7 @@
 package webhook
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -17,11 +18,14 @@ import (
 type Handler struct {
 	config    config.WebhookConfig
 	provider  oauth.Provider
-	executor  func(ctx context.Context, action config.WebhookAction, payload interface{}) error
+	executor  ActionExecutor
 }
 
+// ActionExecutor 执行webhook动作
+type ActionExecutor func(ctx context.Context, action config.WebhookAction, event *Event) error
+
 // NewHandler 创建webhook处理器
-func NewHandler(cfg config.WebhookConfig, provider oauth.Provider, executor func(ctx context.Context, action config.WebhookAction, payload interface{}) error) *Handler {
+func NewHandler(cfg config.WebhookConfig, provider oauth.Provider, executor ActionExecutor) *Handler {
 	return &Handler{
 		config:   cfg,
 		provider: provider,
@@ -31,15 +35,6 @@ func NewHandler(cfg config.WebhookConfig, provider oauth.Provider, executor func
 
 // ServeHTTP 处理webhook请求
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	// 验证签名
-	if h.config.Secret != "" && h.provider != nil {
-		if err := h.provider.ValidateWebhook(r, h.config.Secret); err != nil {
-			log.Printf("❌ Webhook签名验证失败: %v", err)
-			http.Error(w, "Invalid signature", http.StatusUnauthorized)
-			return
-		}
-	}
-
 	// 读取请求体
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
@@ -48,6 +43,16 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// 验证签名，签名验证会读取请求体，验证前重置
+	if h.config.Secret != "" && h.provider != nil {
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		if err := h.provider.ValidateWebhook(r, h.config.Secret); err != nil {
+			log.Printf("❌ Webhook签名验证失败: %v", err)
+			http.Error(w, "Invalid signature", http.StatusUnauthorized)
+			return
+		}
+	}
+
 	// 解析payload
 	var payload map[string]interface{}
 	if err := json.Unmarshal(body, &payload); err != nil {
@@ -56,15 +61,16 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 获取事件类型
-	event := r.Header.Get("X-GitHub-Event")
-	if event == "" {
-		event = r.Header.Get("X-Gitlab-Event")
-	}
-	if event == "" {
-		event = r.Header.Get("X-Gitea-Event")
+	// Bitbucket 的PR动作在事件键中（如 pullrequest:created），补充到payload供动作过滤和模板使用
+	if action := bitbucketAction(r.Header.Get("X-Event-Key")); action != "" {
+		if _, ok := payload["action"]; !ok {
+			payload["action"] = action
+		}
 	}
 
+	// 获取事件类型
+	event := eventType(r)
+
 	log.Printf("📥 收到webhook: %s, 事件: %s", h.config.Name, event)
 
 	// 检查事件过滤
@@ -76,10 +82,12 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 执行动作
+	evt := newEvent(event, payload, body)
+	evt.Webhook = h.config.Name
 	go func() {
 		ctx := context.Background()
 		for _, action := range h.config.Actions {
-			if err := h.executor(ctx, action, payload); err != nil {
+			if err := h.executor(ctx, action, evt); err != nil {
 				log.Printf("❌ 执行webhook动作失败: %v", err)
 			}
 		}
@@ -89,13 +97,45 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("Webhook processed"))
 }
 
+// eventType 从请求头读取事件类型并规范化
+func eventType(r *http.Request) string {
+	event := r.Header.Get("X-GitHub-Event")
+	if event == "" {
+		event = r.Header.Get("X-Gitlab-Event")
+	}
+	if event == "" {
+		event = r.Header.Get("X-Gitea-Event")
+	}
+	if event == "" {
+		event = r.Header.Get("X-Forgejo-Event")
+	}
+	if event == "" {
+		event = r.Header.Get("X-Gogs-Event")
+	}
+	if event == "" {
+		event = r.Header.Get("X-Event-Key")
+	}
+	return normalizeEvent(event)
+}
+
+// normalizeEvent 将事件名规范化为小写下划线形式
+// GitLab 的 Push Hook、Tag Push Hook、Merge Request Hook 分别对应 push、tag_push、merge_request
+// Bitbucket 的 repo:push、repo:refs_changed 对应 push，PR事件键对应 pull_request
+func normalizeEvent(event string) string {
+	if strings.Contains(event, ":") {
+		return bitbucketEvent(event)
+	}
+	event = strings.TrimSuffix(strings.TrimSpace(event), " Hook")
+	return strings.ToLower(strings.ReplaceAll(event, " ", "_"))
+}
+
 // shouldProcess 判断是否应该处理该webhook
 func (h *Handler) shouldProcess(event string, payload map[string]interface{}) bool {
 	// 检查事件类型
 	if len(h.config.Events) > 0 {
 		found := false
 		for _, e := range h.config.Events {
-			if strings.EqualFold(e, event) {
+			if e == "*" || normalizeEvent(e) == event {
 				found = true
 				break
 			}
@@ -145,7 +185,7 @@ func (h *Handler) shouldProcess(event string, payload map[string]interface{}) bo
 		if action != "" {
 			found := false
 			for _, a := range h.config.Filters.Actions {
-				if a == action {
+				if a == action || a == normalizeAction(payload, action) {
 					found = true
 					break
 				}
@@ -177,7 +217,21 @@ func extractBranch(payload map[string]interface{}) string {
 		}
 	}
 
-	return ""
+	// GitLab Merge Request: object_attributes.target_branch
+	if branch := lookupString(payload, "object_attributes", "target_branch"); branch != "" {
+		return branch
+	}
+
+	// Bitbucket Pull Request: pullrequest.destination.branch.name（Cloud）或 pullRequest.toRef.displayId（Server）
+	if branch := lookupString(payload, "pullrequest", "destination", "branch", "name"); branch != "" {
+		return branch
+	}
+	if branch := lookupString(payload, "pullRequest", "toRef", "displayId"); branch != "" {
+		return branch
+	}
+
+	// Bitbucket 推送: push.changes[].new.name（Cloud）或 changes[].refId（Server）
+	return bitbucketBranch(payload)
 }
 
 // extractRepo 从payload中提取仓库名
@@ -190,7 +244,8 @@ func extractRepo(payload map[string]interface{}) string {
 			return fullName
 		}
 	}
-	return ""
+	// GitLab: project.name
+	return lookupString(payload, "project", "name")
 }
 
 // extractAction 从payload中提取动作类型
@@ -198,7 +253,123 @@ func extractAction(payload map[string]interface{}) string {
 	if action, ok := payload["action"].(string); ok {
 		return action
 	}
-	return ""
+	// GitLab Merge Request: object_attributes.action
+	return lookupString(payload, "object_attributes", "action")
+}
+
+// gitlabActions GitLab合并请求动作对应的GitHub动作名，过滤条件可以使用任意一种写法
+var gitlabActions = map[string]string{
+	"open":   "opened",
+	"reopen": "reopened",
+	"close":  "closed",
+	"merge":  "closed",
+}
+
+// normalizeAction 返回动作对应的GitHub动作名
+// GitLab 的 update 只有推送了新提交（带 oldrev）时才对应 synchronize；Gitea 的 synchronized 对应 synchronize
+// Bitbucket 的动作见 bitbucketActions
+func normalizeAction(payload map[string]interface{}, action string) string {
+	_, cloud := payload["pullrequest"]
+	_, server := payload["pullRequest"]
+	if cloud || server {
+		if normalized, ok := bitbucketActions[action]; ok {
+			return normalized
+		}
+		return action
+	}
+	if _, ok := payload["object_attributes"]; !ok {
+		if action == "synchronized" {
+			return "synchronize"
+		}
+		return action
+	}
+	if action == "update" {
+		if lookupString(payload, "object_attributes", "oldrev") != "" {
+			return "synchronize"
+		}
+		return "edited"
+	}
+	if normalized, ok := gitlabActions[action]; ok {
+		return normalized
+	}
+	return action
+}
+
+// bitbucketActions Bitbucket PR事件键中的动作对应的GitHub动作名，过滤条件可以使用任意一种写法
+// Cloud: created、updated、fulfilled、rejected；Server: opened、from_ref_updated、modified、merged、declined、deleted
+var bitbucketActions = map[string]string{
+	"created":          "opened",
+	"updated":          "synchronize",
+	"fulfilled":        "closed",
+	"rejected":         "closed",
+	"opened":           "opened",
+	"from_ref_updated": "synchronize",
+	"modified":         "edited",
+	"merged":           "closed",
+	"declined":         "closed",
+	"deleted":          "closed",
+}
+
+// bitbucketEvent 规范化Bitbucket事件键，评论、审批等其他事件键保持原样
+func bitbucketEvent(key string) string {
+	key = strings.ToLower(strings.TrimSpace(key))
+	if key == "repo:push" || key == "repo:refs_changed" {
+		return "push"
+	}
+	if bitbucketAction(key) != "" {
+		return "pull_request"
+	}
+	return key
+}
+
+// bitbucketAction 返回Bitbucket PR事件键中的动作，如 pullrequest:created 返回 created，不是PR事件时返回空字符串
+func bitbucketAction(key string) string {
+	prefix, action, ok := strings.Cut(strings.ToLower(key), ":")
+	if !ok || (prefix != "pullrequest" && prefix != "pr") {
+		return ""
+	}
+	if _, ok := bitbucketActions[action]; !ok {
+		return ""
+	}
+	return action
+}
+
+// bitbucketChange 返回Bitbucket推送事件的第一个引用变更，Cloud 为 push.changes[0]，Server 为 changes[0]
+// 一次推送多个分支时只处理第一个
+func bitbucketChange(payload map[string]interface{}) map[string]interface{} {
+	changes, ok := payload["changes"].([]interface{})
+	if push, isMap := payload["push"].(map[string]interface{}); isMap {
+		changes, ok = push["changes"].([]interface{})
+	}
+	if !ok || len(changes) == 0 {
+		return nil
+	}
+	change, _ := changes[0].(map[string]interface{})
+	return change
+}
+
+// bitbucketBranch 从Bitbucket推送事件中提取分支名，标签推送返回空字符串
+func bitbucketBranch(payload map[string]interface{}) string {
+	change := bitbucketChange(payload)
+	if change == nil {
+		return ""
+	}
+	// Server: refId 为完整引用名
+	if ref := lookupString(change, "refId"); ref != "" {
+		if strings.HasPrefix(ref, "refs/heads/") {
+			return strings.TrimPrefix(ref, "refs/heads/")
+		}
+		return ""
+	}
+	// Cloud: 删除分支时 new 为 null，使用 old
+	ref, ok := change["new"].(map[string]interface{})
+	if !ok {
+		ref, _ = change["old"].(map[string]interface{})
+	}
+	if lookupString(ref, "type") != "branch" {
+		return ""
+	}
+	return lookupString(ref, "name")
 }
 
 // GitHubPushPayload GitHub push事件payload
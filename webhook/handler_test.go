@@ -0,0 +1,239 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"lite-cicd/config"
+	"lite-cicd/oauth"
+)
+
+func TestHandler(t *testing.T) {
+	const secret = "webhook-secret"
+	body := `{"ref":"refs/heads/main","after":"abc123","repository":{"name":"repo","full_name":"user/repo"}}`
+
+	events := make(chan *Event, 1)
+	handler := NewHandler(config.WebhookConfig{
+		Name:    "push",
+		Secret:  secret,
+		Events:  []string{"push"},
+		Actions: []config.WebhookAction{{Type: "command", Command: "true"}},
+	}, oauth.NewGitHubProvider("", "", "", nil), func(ctx context.Context, action config.WebhookAction, event *Event) error {
+		events <- event
+		return nil
+	})
+
+	send := func(signature string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/webhook/push", strings.NewReader(body))
+		req.Header.Set("X-GitHub-Event", "push")
+		req.Header.Set("X-Hub-Signature-256", signature)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("签名验证后解析事件", func(t *testing.T) {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte(body))
+		rec := send("sha256=" + hex.EncodeToString(mac.Sum(nil)))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("状态码应为200，实际: %d %s", rec.Code, rec.Body.String())
+		}
+
+		select {
+		case event := <-events:
+			want := map[string]string{EnvEvent: "push", EnvBranch: "main", EnvCommit: "abc123", EnvRepo: "user/repo"}
+			for name, value := range want {
+				if event.Env()[name] != value {
+					t.Errorf("%s: got %q, want %q", name, event.Env()[name], value)
+				}
+			}
+			if string(event.Raw) != body {
+				t.Errorf("原始请求体不正确: %s", event.Raw)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("动作未执行")
+		}
+	})
+
+	t.Run("签名错误", func(t *testing.T) {
+		if rec := send("sha256=invalid"); rec.Code != http.StatusUnauthorized {
+			t.Fatalf("状态码应为401，实际: %d", rec.Code)
+		}
+	})
+}
+
+func TestShouldProcess_GitLab(t *testing.T) {
+	handler := NewHandler(config.WebhookConfig{
+		Events: []string{"push", "Merge Request Hook"},
+		Filters: config.WebhookFilter{
+			Branches: []string{"main"},
+			Repos:    []string{"backend"},
+			Actions:  []string{"opened", "synchronize"},
+		},
+	}, nil, nil)
+
+	cases := []struct {
+		name   string
+		header string
+		body   string
+		want   bool
+	}{
+		{"推送", "Push Hook", `{"ref":"refs/heads/main","checkout_sha":"abc","project":{"name":"backend","path_with_namespace":"group/backend"}}`, true},
+		{"其他分支", "Push Hook", `{"ref":"refs/heads/dev","project":{"name":"backend"}}`, false},
+		{"其他仓库", "Push Hook", `{"ref":"refs/heads/main","project":{"name":"frontend"}}`, false},
+		{"打开合并请求", "Merge Request Hook", `{"object_attributes":{"action":"open","target_branch":"main"},"project":{"name":"backend"}}`, true},
+		{"合并请求新提交", "Merge Request Hook", `{"object_attributes":{"action":"update","oldrev":"abc","target_branch":"main"},"project":{"name":"backend"}}`, true},
+		{"合并请求编辑", "Merge Request Hook", `{"object_attributes":{"action":"update","target_branch":"main"},"project":{"name":"backend"}}`, false},
+		{"标签推送", "Tag Push Hook", `{"ref":"refs/tags/v1.0","project":{"name":"backend"}}`, false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/webhook", nil)
+			req.Header.Set("X-Gitlab-Event", c.header)
+			var payload map[string]interface{}
+			if err := json.Unmarshal([]byte(c.body), &payload); err != nil {
+				t.Fatalf("解析payload失败: %v", err)
+			}
+			if got := handler.shouldProcess(eventType(req), payload); got != c.want {
+				t.Errorf("got %v, want %v", got, c.want)
+			}
+		})
+	}
+
+	event := newEvent("push", map[string]interface{}{
+		"ref":          "refs/heads/main",
+		"checkout_sha": "abc",
+		"project":      map[string]interface{}{"path_with_namespace": "group/backend"},
+	}, nil)
+	if event.Commit != "abc" || event.Repo != "group/backend" {
+		t.Errorf("GitLab事件的提交或仓库不正确: %s %s", event.Commit, event.Repo)
+	}
+}
+
+func TestShouldProcess_Gitea(t *testing.T) {
+	handler := NewHandler(config.WebhookConfig{
+		Events: []string{"pull_request"},
+		Filters: config.WebhookFilter{
+			Branches: []string{"main"},
+			Repos:    []string{"backend"},
+			Actions:  []string{"synchronize"},
+		},
+	}, nil, nil)
+
+	body := `{
+		"action": "synchronized",
+		"number": 7,
+		"pull_request": {"number": 7, "base": {"ref": "main"}, "head": {"ref": "feature", "sha": "def456"}},
+		"repository": {"name": "backend", "full_name": "org/backend", "clone_url": "https://git.example.com/org/backend.git"}
+	}`
+	var payload map[string]interface{}
+	if err := json.Unmarshal([]byte(body), &payload); err != nil {
+		t.Fatalf("解析payload失败: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/webhook", nil)
+	req.Header.Set("X-Gitea-Event", "pull_request")
+	if !handler.shouldProcess(eventType(req), payload) {
+		t.Error("Gitea 的 synchronized 动作应该匹配 synchronize 过滤条件")
+	}
+
+	event := newEvent(eventType(req), payload, []byte(body))
+	if event.Branch != "main" || event.Commit != "def456" || event.Number != 7 || !event.MatchRepo("https://git.example.com/org/backend") {
+		t.Errorf("Gitea事件解析不正确: %+v", event)
+	}
+}
+
+func TestShouldProcess_Bitbucket(t *testing.T) {
+	handler := NewHandler(config.WebhookConfig{
+		Events: []string{"push", "pull_request"},
+		Filters: config.WebhookFilter{
+			Branches: []string{"main"},
+			Repos:    []string{"backend"},
+			Actions:  []string{"opened", "synchronize"},
+		},
+	}, nil, nil)
+
+	cases := []struct {
+		name, key, body string
+		process         bool
+		commit, repo    string
+	}{
+		{
+			name: "Cloud推送",
+			key:  "repo:push",
+			body: `{"push": {"changes": [{"new": {"type": "branch", "name": "main", "target": {"hash": "abc123"}}}]},
+				"repository": {"name": "backend", "full_name": "team/backend"}}`,
+			process: true, commit: "abc123", repo: "team/backend",
+		},
+		{
+			name: "Cloud推送其他分支",
+			key:  "repo:push",
+			body: `{"push": {"changes": [{"new": {"type": "branch", "name": "dev", "target": {"hash": "abc123"}}}]},
+				"repository": {"name": "backend"}}`,
+		},
+		{
+			name: "Cloud创建PR",
+			key:  "pullrequest:created",
+			body: `{"pullrequest": {"destination": {"branch": {"name": "main"}}, "source": {"commit": {"hash": "def456"}}},
+				"repository": {"name": "backend", "full_name": "team/backend"}}`,
+			process: true, commit: "def456", repo: "team/backend",
+		},
+		{
+			name: "Cloud合并PR",
+			key:  "pullrequest:fulfilled",
+			body: `{"pullrequest": {"destination": {"branch": {"name": "main"}}}, "repository": {"name": "backend"}}`,
+		},
+		{
+			name: "Server推送",
+			key:  "repo:refs_changed",
+			body: `{"changes": [{"refId": "refs/heads/main", "toHash": "789abc", "type": "UPDATE"}],
+				"repository": {"name": "backend", "slug": "backend", "project": {"key": "PRJ"}}}`,
+			process: true, commit: "789abc", repo: "PRJ/backend",
+		},
+		{
+			name: "Server更新PR",
+			key:  "pr:from_ref_updated",
+			body: `{"pullRequest": {"toRef": {"displayId": "main"}, "fromRef": {"latestCommit": "fed987"}},
+				"repository": {"name": "backend", "slug": "backend", "project": {"key": "PRJ"}}}`,
+			process: true, commit: "fed987", repo: "PRJ/backend",
+		},
+		{
+			name: "PR评论",
+			key:  "pullrequest:comment_created",
+			body: `{"pullrequest": {"destination": {"branch": {"name": "main"}}}, "repository": {"name": "backend"}}`,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var payload map[string]interface{}
+			if err := json.Unmarshal([]byte(c.body), &payload); err != nil {
+				t.Fatalf("解析payload失败: %v", err)
+			}
+			if action := bitbucketAction(c.key); action != "" {
+				payload["action"] = action
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/webhook", nil)
+			req.Header.Set("X-Event-Key", c.key)
+			if got := handler.shouldProcess(eventType(req), payload); got != c.process {
+				t.Fatalf("过滤结果不正确: 期望 %v，实际 %v", c.process, got)
+			}
+			if c.process {
+				event := newEvent(eventType(req), payload, []byte(c.body))
+				if event.Branch != "main" || event.Commit != c.commit || event.Repo != c.repo {
+					t.Errorf("事件解析不正确: %+v", event)
+				}
+			}
+		})
+	}
+}